@@ -0,0 +1,76 @@
+package zk_asset
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/kysee/zkp/zk-asset/prover"
+	"github.com/kysee/zkp/zk-asset/types"
+	"github.com/kysee/zkp/zk-asset/verifier"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxBuilder(t *testing.T) {
+	sender := newFundedWallet(t, 40)
+	receiver := prover.NewWallet(verifier.NewLocalChain())
+	amt, fee := uint256.NewInt(25), uint256.NewInt(2)
+	memo := []byte("thanks for the coffee")
+
+	notes := sender.UnspentNotes()
+	inputs, root, depth, err := prover.GetNoteInputs(sender.Chain(), []*types.Note{notes[0].ToNoteOf(sender.PublicKey())})
+	require.NoError(t, err)
+
+	//
+	// 1. the builder knows only the proof authorizing key.
+	b := prover.NewTxBuilder(sender.ProofAuthorizingKey())
+	require.NoError(t, b.AddInput(inputs[0]))
+	b.SetAnchor(root, depth)
+	require.NoError(t, b.AddOutput(receiver.Address, amt, memo))
+	require.Error(t, b.AddOutput(receiver.Address, amt, nil))
+	b.SetFee(fee)
+
+	// the note of others can not be added.
+	other := prover.NewWallet(verifier.NewLocalChain())
+	require.Error(t, b.AddInput(&prover.NoteInput{Note: notes[0].ToNoteOf(other.PublicKey())}))
+
+	utx, err := b.Build()
+	require.NoError(t, err)
+	require.Empty(t, utx.Tx.ProofBytes)
+	require.Empty(t, utx.Tx.SpendAuthSig)
+
+	//
+	// 2. the prover gets the serialized witness.
+	bzWtn, err := json.Marshal(utx.Witness)
+	require.NoError(t, err)
+	var wtn types.ZKWitness
+	require.NoError(t, json.Unmarshal(bzWtn, &wtn))
+	utx.Tx.ProofBytes, err = prover.Prove(&wtn, prKey, css)
+	require.NoError(t, err)
+
+	//
+	// 3. the signer holds the spending key.
+	require.Error(t, prover.SignZKTx(utx.Tx, other.PrivateKey, utx.Witness.Alpha))
+	require.NoError(t, prover.SignZKTx(utx.Tx, sender.PrivateKey, utx.Witness.Alpha))
+
+	// the tx without the valid signature is rejected.
+	tamperedTx := *utx.Tx
+	tamperedTx.SpendAuthSig = append([]byte(nil), utx.Tx.SpendAuthSig...)
+	tamperedTx.SpendAuthSig[len(tamperedTx.SpendAuthSig)-1] ^= 0x01
+	require.ErrorIs(t, verifier.VerifyZKTx(&tamperedTx), types.ErrWrongSpendAuthSig)
+	tamperedTx.SpendAuthSig = nil
+	require.ErrorIs(t, verifier.VerifyZKTx(&tamperedTx), types.ErrWrongSpendAuthSig)
+
+	require.NoError(t, verifier.VerifyZKTx(utx.Tx))
+
+	_, err = sender.SyncSharedNotes()
+	require.NoError(t, err)
+	require.EqualValues(t, 40-25-2, sender.GetBalance().Uint64())
+
+	_, err = receiver.SyncSharedNotes()
+	require.NoError(t, err)
+	require.Equal(t, amt, receiver.GetBalance())
+	received, err := receiver.FetchMemo(receiver.UnspentNotes()[0])
+	require.NoError(t, err)
+	require.Equal(t, memo, received)
+}
@@ -0,0 +1,72 @@
+package zk_asset
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kysee/zkp/zk-asset/verifier"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompactBlock(t *testing.T) {
+	txCnt := verifier.GetZKTxCount()
+	blk := verifier.GetCompactBlock(0, txCnt)
+	require.Len(t, blk.Txs, txCnt)
+	require.Equal(t, 0, blk.FromCommitmentIdx)
+
+	// the outputs of the compact txs are the leaves of the note commitment tree in order.
+	pos := blk.FromCommitmentIdx
+	for i, ctx := range blk.Txs {
+		require.Equal(t, i, ctx.TxIdx)
+
+		zktx := verifier.GetZKTx(i)
+		require.Equal(t, len(zktx.Nullifiers), len(ctx.Nullifiers))
+		for j, nf := range zktx.Nullifiers {
+			require.Equal(t, nf, ctx.Nullifiers[j])
+		}
+		for _, out := range ctx.Outputs {
+			require.Equal(t, verifier.GetNoteCommitment(pos), out.Commitment)
+
+			sn := verifier.GetSecretNote(pos)
+			require.Equal(t, sn[:32], out.EphemeralKey)
+			require.Equal(t, sn[32:32+len(out.CiphertextPrefix)], out.CiphertextPrefix)
+			pos++
+		}
+
+		// a compact tx is smaller than the full one.
+		bzCompact, err := json.Marshal(ctx)
+		require.NoError(t, err)
+		bzFull, err := json.Marshal(zktx)
+		require.NoError(t, err)
+		require.Less(t, len(bzCompact), len(bzFull))
+	}
+	require.Equal(t, verifier.GetNoteCommitmentsCount(), pos)
+
+	// a block in the middle of the ledger
+	sub := verifier.GetCompactBlock(3, 5)
+	require.Equal(t, 3, sub.FromTxIdx)
+	require.Equal(t, blk.Txs[3:5], sub.Txs)
+	outs := 0
+	for _, ctx := range blk.Txs[:3] {
+		outs += len(ctx.Outputs)
+	}
+	require.Equal(t, verifier.GetCompactBlock(0, 3).FromCommitmentIdx+outs, sub.FromCommitmentIdx)
+
+	// an empty block at the end of the ledger
+	end := verifier.GetCompactBlock(txCnt, txCnt+10)
+	require.Empty(t, end.Txs)
+	require.Equal(t, verifier.GetNoteCommitmentsCount(), end.FromCommitmentIdx)
+}
+
+func TestCompactBlock_FetchMemo(t *testing.T) {
+	w := wallets[4]
+
+	wn := w.UnspentNotes()[0]
+	// the note found in a compact block has no memo yet.
+	require.Nil(t, wn.Memo)
+
+	memo, err := w.FetchMemo(wn)
+	require.NoError(t, err)
+	require.NotNil(t, memo)
+	require.Equal(t, memo, wn.Memo)
+}
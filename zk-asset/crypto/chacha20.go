@@ -3,6 +3,7 @@ package crypto
 import (
 	"fmt"
 
+	"golang.org/x/crypto/chacha20"
 	"golang.org/x/crypto/chacha20poly1305"
 )
 
@@ -65,3 +66,33 @@ func ChaCha20Poly1305_Decrypt(key, nonce, ciphertext, additionalData []byte) ([]
 	}
 	return plaintext, nil
 }
+
+// ChaCha20_DecryptPrefix decrypts only the first `n` bytes of a ciphertext made by `ChaCha20Poly1305_Encrypt`
+// WITHOUT authenticating it.
+//
+// It is much cheaper than the full decryption, so it is used to reject ciphertexts encrypted with other keys early.
+// The returned plaintext must not be trusted until the full ciphertext is authenticated
+// or the plaintext is checked against a commitment.
+func ChaCha20_DecryptPrefix(key, nonce, ciphertext []byte, n int) ([]byte, error) {
+	if len(key) != chacha20poly1305.KeySize {
+		return nil, fmt.Errorf("invalid key size: must be %d bytes", chacha20poly1305.KeySize)
+	}
+	if len(nonce) != chacha20poly1305.NonceSize {
+		return nil, fmt.Errorf("invalid nonce size: must be %d bytes", chacha20poly1305.NonceSize)
+	}
+	if n > len(ciphertext) {
+		n = len(ciphertext)
+	}
+
+	s, err := chacha20.NewUnauthenticatedCipher(key, nonce)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ChaCha20 cipher: %w", err)
+	}
+	// ChaCha20-Poly1305 uses the first block (counter 0) for the Poly1305 key
+	// and encrypts the plaintext from counter 1.
+	s.SetCounter(1)
+
+	plaintext := make([]byte, n)
+	s.XORKeyStream(plaintext, ciphertext[:n])
+	return plaintext, nil
+}
@@ -30,3 +30,21 @@ func Test_Encrypt(t *testing.T) {
 
 	require.Equal(t, m, dec)
 }
+
+func Test_DecryptPrefix(t *testing.T) {
+	m := []byte("hello, this message is longer than one chacha20 block of 64 bytes.")
+
+	saplingKDF, err := SaplingKDF(make([]byte, 32), 44)
+	require.NoError(t, err)
+	encKey := saplingKDF[:32]
+	nonce := saplingKDF[32:44]
+
+	enc, err := ChaCha20Poly1305_Encrypt(encKey, nonce, m, nil)
+	require.NoError(t, err)
+
+	for _, n := range []int{1, 5, 64, 65, len(m)} {
+		dec, err := ChaCha20_DecryptPrefix(encKey, nonce, enc, n)
+		require.NoError(t, err)
+		require.Equal(t, m[:n], dec)
+	}
+}
@@ -0,0 +1,193 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	tedwards "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+	"github.com/consensys/gnark-crypto/signature"
+	"github.com/tyler-smith/go-bip39"
+)
+
+//
+// Mnemonic (BIP-39)
+
+// MnemonicEntropyBits is the entropy size of a new mnemonic; 256 bits make 24 words.
+const MnemonicEntropyBits = 256
+
+// NewMnemonic generates a new BIP-39 mnemonic of 24 words.
+func NewMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(MnemonicEntropyBits)
+	if err != nil {
+		return "", err
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+// MnemonicToSeed validates `mnemonic` and returns its 64 bytes BIP-39 seed.
+// `passphrase` is the optional BIP-39 passphrase, not the one of a wallet file.
+func MnemonicToSeed(mnemonic, passphrase string) ([]byte, error) {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	return bip39.NewSeedWithErrorChecking(mnemonic, passphrase)
+}
+
+//
+// Hierarchical deterministic keys (ZIP-32-like, hardened only)
+//
+// master:  I = HMAC-SHA512("ZkAsset_HD_Seed", seed)
+// child i: I = HMAC-SHA512(chainCode, 0x00 || sk || ser32(i + 2^31))
+// sk = I[:32], chainCode = I[32:]
+//
+// The Jubjub key of an extended key:
+// scalar = int(HMAC-SHA512("ZkAsset_HD_Key", 0x00 || sk)) mod order
+// random source of the signing = HMAC-SHA512("ZkAsset_HD_Key", 0x01 || sk)[:32]
+//
+// A public key cannot derive the child public keys, so every index is hardened.
+
+// HardenedKeyStart is the first hardened child index.
+const HardenedKeyStart uint32 = 0x80000000
+
+// The account path is m/HDPurpose'/HDCoinType'/account'.
+const (
+	HDPurpose  uint32 = 32
+	HDCoinType uint32 = 1
+)
+
+var (
+	hdMasterKeyDomain = []byte("ZkAsset_HD_Seed")
+	hdSignerDomain    = []byte("ZkAsset_HD_Key")
+)
+
+// ExtendedKey is a node of the key tree.
+type ExtendedKey struct {
+	sk        [32]byte
+	chainCode [32]byte
+	depth     uint8
+	index     uint32
+}
+
+// NewMasterKey returns the root of the key tree of `seed`.
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, fmt.Errorf("wrong seed size: %d", len(seed))
+	}
+	return newExtendedKey(hdMasterKeyDomain, seed, 0, 0), nil
+}
+
+func newExtendedKey(key, data []byte, depth uint8, index uint32) *ExtendedKey {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+	I := mac.Sum(nil)
+
+	k := &ExtendedKey{depth: depth, index: index}
+	copy(k.sk[:], I[:32])
+	copy(k.chainCode[:], I[32:])
+	return k
+}
+
+// Child derives the hardened child key at `index`.
+// `index` may be given with or without `HardenedKeyStart`.
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	if k.depth == 255 {
+		return nil, errors.New("the key tree is too deep")
+	}
+	index |= HardenedKeyStart
+
+	data := make([]byte, 0, 1+32+4)
+	data = append(data, 0x00)
+	data = append(data, k.sk[:]...)
+	data = binary.BigEndian.AppendUint32(data, index)
+	return newExtendedKey(k.chainCode[:], data, k.depth+1, index), nil
+}
+
+// Derive derives the descendant key at `path` such as "m/32'/1'/0'".
+func (k *ExtendedKey) Derive(path string) (*ExtendedKey, error) {
+	indices, err := ParseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+	ret := k
+	for _, i := range indices {
+		if ret, err = ret.Child(i); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+func (k *ExtendedKey) Depth() uint8 {
+	return k.depth
+}
+
+// Index returns the child index of the key including `HardenedKeyStart`. It is 0 for the master key.
+func (k *ExtendedKey) Index() uint32 {
+	return k.index
+}
+
+// Signer returns the Jubjub private key of the extended key.
+func (k *ExtendedKey) Signer() (signature.Signer, error) {
+	curve := tedwards.GetEdwardsCurve()
+	scalar := new(big.Int).SetBytes(k.expand(0x00))
+	scalar.Mod(scalar, &curve.Order)
+	if scalar.Sign() == 0 {
+		return nil, errors.New("the extended key derives the zero scalar")
+	}
+	return KeyFromScalar(scalar, k.expand(0x01)[:32])
+}
+
+func (k *ExtendedKey) expand(tag byte) []byte {
+	mac := hmac.New(sha512.New, hdSignerDomain)
+	mac.Write([]byte{tag})
+	mac.Write(k.sk[:])
+	return mac.Sum(nil)
+}
+
+// ParseDerivationPath parses a path such as "m/32'/1'/0'".
+// Every index should be hardened; the index without a hardened mark is rejected.
+func ParseDerivationPath(path string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimSpace(path), "/")
+	if len(parts) == 0 || parts[0] != "m" {
+		return nil, fmt.Errorf("wrong derivation path: %s", path)
+	}
+
+	var indices []uint32
+	for _, p := range parts[1:] {
+		_p, hardened := strings.CutSuffix(p, "'")
+		if !hardened {
+			_p, hardened = strings.CutSuffix(p, "h")
+		}
+		if !hardened {
+			return nil, fmt.Errorf("not hardened index in the derivation path: %s", path)
+		}
+		i, err := strconv.ParseUint(_p, 10, 32)
+		if err != nil || uint32(i) >= HardenedKeyStart {
+			return nil, fmt.Errorf("wrong index in the derivation path: %s", path)
+		}
+		indices = append(indices, uint32(i)|HardenedKeyStart)
+	}
+	return indices, nil
+}
+
+// AccountPath returns the derivation path of `account`.
+func AccountPath(account uint32) string {
+	return fmt.Sprintf("m/%d'/%d'/%d'", HDPurpose, HDCoinType, account)
+}
+
+// AccountKey derives the Jubjub private key of `account` from `seed`.
+func AccountKey(seed []byte, account uint32) (signature.Signer, error) {
+	master, err := NewMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	k, err := master.Derive(AccountPath(account))
+	if err != nil {
+		return nil, err
+	}
+	return k.Signer()
+}
@@ -0,0 +1,106 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMnemonic(t *testing.T) {
+	mnemonic, err := NewMnemonic()
+	require.NoError(t, err)
+	require.Len(t, strings.Fields(mnemonic), 24)
+
+	seed0, err := MnemonicToSeed(mnemonic, "")
+	require.NoError(t, err)
+	require.Len(t, seed0, 64)
+
+	// the extra spaces are ignored.
+	seed1, err := MnemonicToSeed("  "+strings.ReplaceAll(mnemonic, " ", "   ")+"\n", "")
+	require.NoError(t, err)
+	require.Equal(t, seed0, seed1)
+
+	// the passphrase makes a different seed.
+	seed2, err := MnemonicToSeed(mnemonic, "passphrase")
+	require.NoError(t, err)
+	require.NotEqual(t, seed0, seed2)
+
+	// a wrong checksum
+	words := strings.Fields(mnemonic)
+	words[0], words[1] = words[1], words[0]
+	if words[0] != words[1] {
+		_, err = MnemonicToSeed(strings.Join(words, " "), "")
+		require.Error(t, err)
+	}
+	_, err = MnemonicToSeed("not a mnemonic", "")
+	require.Error(t, err)
+
+	// the test vector of BIP-39
+	seed, err := MnemonicToSeed("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "TREZOR")
+	require.NoError(t, err)
+	require.Equal(t,
+		"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		hex.EncodeToString(seed))
+}
+
+func TestAccountKey(t *testing.T) {
+	seed, err := MnemonicToSeed("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "")
+	require.NoError(t, err)
+
+	master, err := NewMasterKey(seed)
+	require.NoError(t, err)
+
+	var pubs []string
+	for account := uint32(0); account < 3; account++ {
+		k0, err := AccountKey(seed, account)
+		require.NoError(t, err)
+		// deterministic
+		k1, err := AccountKey(seed, account)
+		require.NoError(t, err)
+		require.Equal(t, k0.Bytes(), k1.Bytes())
+
+		// same as the step by step derivation
+		ek := master
+		for _, i := range []uint32{HDPurpose, HDCoinType, account} {
+			ek, err = ek.Child(i)
+			require.NoError(t, err)
+		}
+		require.EqualValues(t, 3, ek.Depth())
+		require.Equal(t, account|HardenedKeyStart, ek.Index())
+		k2, err := ek.Signer()
+		require.NoError(t, err)
+		require.Equal(t, k0.Bytes(), k2.Bytes())
+
+		// the derived key can be restored by KeyFromBytes.
+		k3, err := KeyFromBytes(k0.Bytes())
+		require.NoError(t, err)
+		require.Equal(t, k0.Public().Bytes(), k3.Public().Bytes())
+
+		pubs = append(pubs, hex.EncodeToString(k0.Public().Bytes()))
+	}
+	require.NotEqual(t, pubs[0], pubs[1])
+	require.NotEqual(t, pubs[1], pubs[2])
+
+	// another seed makes another key.
+	seed[0] ^= 0x01
+	k, err := AccountKey(seed, 0)
+	require.NoError(t, err)
+	require.NotEqual(t, pubs[0], hex.EncodeToString(k.Public().Bytes()))
+}
+
+func TestParseDerivationPath(t *testing.T) {
+	indices, err := ParseDerivationPath("m/32'/1'/7h")
+	require.NoError(t, err)
+	require.Equal(t, []uint32{32 | HardenedKeyStart, 1 | HardenedKeyStart, 7 | HardenedKeyStart}, indices)
+
+	indices, err = ParseDerivationPath("m")
+	require.NoError(t, err)
+	require.Empty(t, indices)
+
+	for _, path := range []string{"", "32'/1'", "m/32'/1", "m/x'", "m/2147483648'", "m//1'"} {
+		_, err := ParseDerivationPath(path)
+		require.Error(t, err, path)
+	}
+}
@@ -19,6 +19,63 @@ func NewKey() (signature.Signer, error) {
 	return jubjub.GenerateKey(crand.Reader)
 }
 
+// KeyFromBytes restores the private key serialized by its `Bytes()`.
+func KeyFromBytes(bz []byte) (signature.Signer, error) {
+	prvk := new(jubjub.PrivateKey)
+	if _, err := prvk.SetBytes(bz); err != nil {
+		return nil, err
+	}
+
+	// the public key should be derived from the scalar.
+	var pub tedwards.PointAffine
+	base := tedwards.GetEdwardsCurve().Base
+	pub.ScalarMultiplication(&base, new(big.Int).SetBytes(bz[32:64]))
+	if !pub.Equal(&prvk.PublicKey.A) {
+		return nil, errors.New("the public key is not derived from the private key")
+	}
+	return prvk, nil
+}
+
+// KeyFromScalar returns the private key of `scalar` mod the order of the base point.
+// `randSrc` is the 32 bytes random source used by the signing.
+func KeyFromScalar(scalar *big.Int, randSrc []byte) (signature.Signer, error) {
+	if len(randSrc) != 32 {
+		return nil, fmt.Errorf("wrong random source size: %d", len(randSrc))
+	}
+	curve := tedwards.GetEdwardsCurve()
+	s := new(big.Int).Mod(scalar, &curve.Order)
+
+	var pub tedwards.PointAffine
+	pub.ScalarMultiplication(&curve.Base, s)
+	pubBytes := pub.Bytes()
+
+	// the layout of `jubjub.PrivateKey.Bytes()`: public key || scalar || random source
+	bz := make([]byte, 0, 96)
+	bz = append(bz, pubBytes[:]...)
+	bz = append(bz, s.FillBytes(make([]byte, 32))...)
+	bz = append(bz, randSrc...)
+	return KeyFromBytes(bz)
+}
+
+// KeyScalar returns the scalar of the private key.
+func KeyScalar(prvk signature.Signer) *big.Int {
+	return new(big.Int).SetBytes(prvk.Bytes()[32:64])
+}
+
+// PubFromScalar returns `scalar*G`.
+func PubFromScalar(scalar *big.Int) signature.PublicKey {
+	pub := new(jubjub.PublicKey)
+	base := tedwards.GetEdwardsCurve().Base
+	pub.A.ScalarMultiplication(&base, scalar)
+	return pub
+}
+
+// RandScalar returns a random scalar less than the order of the base point.
+func RandScalar() (*big.Int, error) {
+	curve := tedwards.GetEdwardsCurve()
+	return crand.Int(crand.Reader, &curve.Order)
+}
+
 func NewPub() signature.PublicKey {
 	return new(jubjub.PublicKey)
 }
@@ -53,6 +110,16 @@ func ECDHSharedSecret(privateKey signature.Signer, _otherPublicKey signature.Pub
 	return hasher.Sum(nil), nil
 }
 
+// KDFSeed binds the ECDH shared secret to the ephemeral public key `epk`,
+// as KDF^Sapling(sharedSecret, epk) of the Zcash Sapling specification takes both.
+// The result is the input of `SaplingKDF`.
+func KDFSeed(sharedSecret, epk []byte) []byte {
+	h, _ := blake2s.New256(nil) // no error without a key
+	h.Write(sharedSecret)
+	h.Write(epk)
+	return h.Sum(nil)
+}
+
 // SaplingKDF derives a key stream of a specified length from a shared secret using BLAKE2s.
 // This function follows the PRF^expand logic, similar to HKDF-Expand (RFC 5869),
 // as defined in the Zcash Sapling specification.
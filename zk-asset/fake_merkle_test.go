@@ -16,16 +16,16 @@ import (
 )
 
 func TestFakeMerkle_WrongRootHash(t *testing.T) {
-	sender := prover.Wallets[0]
-	receiver := prover.Wallets[5]
+	sender := wallets[0]
+	receiver := wallets[5]
 	amt, fee := uint256.NewInt(10), uint256.NewInt(0)
 
 	useSharedNote := sender.GetSharedNote(0)
-	useNote := useSharedNote.ToNoteOf(sender.PrivateKey.Public())
+	useNote := useSharedNote.ToNoteOf(sender.PublicKey())
 	useNoteCommitment := useNote.Commitment()
 
 	// get merkle proof info.
-	rootHash, proofPath, depth, idx, _, err := verifier.GetNoteCommitmentMerkle(useNoteCommitment)
+	rootHash, proofPath, depth, idx, numLeaves, err := verifier.GetNoteCommitmentMerkle(useNoteCommitment)
 	require.NoError(t, err)
 	//fmt.Printf("Merkle Info: numLeaves=%d, idx=%d, depth=%d, proofPath.len=%d\n", numLeaves, idx, depth, len(proofPath))
 
@@ -35,9 +35,9 @@ func TestFakeMerkle_WrongRootHash(t *testing.T) {
 	// generate the ZKTx including zk-proof
 	_, err = prover.CreateZKTx(
 		sender.PrivateKey,
-		receiver.Address, amt, fee,
+		receiver.Address, amt, fee, nil,
 		useNote,
-		rootHash, proofPath, depth, idx,
+		rootHash, proofPath, depth, idx, numLeaves,
 		prKey, css,
 	)
 	require.Error(t, err)
@@ -49,7 +49,7 @@ var fakeCommitments []types.NoteCommitment
 var fakeMerkleDepth = verifier.GetNoteCommitmentMerkleDepth()
 
 func TestFakeMerkle_UseFakeMerkle(t *testing.T) {
-	faker := prover.NewWallet()
+	faker := prover.NewWallet(verifier.NewLocalChain())
 
 	for i := 0; i < 5; i++ {
 		balance := uint256.NewInt(1_000_000_000)
@@ -57,7 +57,7 @@ func TestFakeMerkle_UseFakeMerkle(t *testing.T) {
 
 		fakeNote := &types.Note{
 			Version: 1,
-			PubKey:  faker.PrivateKey.Public(),
+			PubKey:  faker.PublicKey(),
 			Balance: balance,
 			Salt:    salt,
 		}
@@ -75,11 +75,11 @@ func TestFakeMerkle_UseFakeMerkle(t *testing.T) {
 		faker.AddSharedNote(sharedNote)
 	}
 
-	receiver := prover.NewWallet()
+	receiver := prover.NewWallet(verifier.NewLocalChain())
 	amt, fee := uint256.NewInt(10), uint256.NewInt(0)
 
 	useSharedNote := faker.GetSharedNote(0)
-	useNote := useSharedNote.ToNoteOf(faker.PrivateKey.Public())
+	useNote := useSharedNote.ToNoteOf(faker.PublicKey())
 	useNoteCommitment := useNote.Commitment()
 
 	// get merkle proof info.
@@ -90,9 +90,9 @@ func TestFakeMerkle_UseFakeMerkle(t *testing.T) {
 	// generate the ZKTx including zk-proof
 	zkTx, err := prover.CreateZKTx(
 		faker.PrivateKey,
-		receiver.Address, amt, fee,
+		receiver.Address, amt, fee, nil,
 		useNote,
-		rootHash, proofPath, depth, idx,
+		rootHash, proofPath, depth, idx, numLeaves,
 		prKey, css,
 	)
 	// the merkle tree is fully faked.
@@ -0,0 +1,89 @@
+package zk_asset
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/kysee/zkp/zk-asset/crypto"
+	"github.com/kysee/zkp/zk-asset/prover"
+	"github.com/kysee/zkp/zk-asset/types"
+	"github.com/kysee/zkp/zk-asset/verifier"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeeCollection(t *testing.T) {
+	sender := newFundedWallet(t, 40)
+	receiver := prover.NewWallet(verifier.NewLocalChain())
+	supply := verifier.GetSupply()
+
+	notes := sender.UnspentNotes()
+	inputs, root, depth, err := prover.GetNoteInputs(sender.Chain(), []*types.Note{notes[0].ToNoteOf(sender.PublicKey())})
+	require.NoError(t, err)
+	b := prover.NewTxBuilder(sender.ProofAuthorizingKey())
+	require.NoError(t, b.AddInput(inputs[0]))
+	b.SetAnchor(root, depth)
+	require.NoError(t, b.AddOutput(receiver.Address, uint256.NewInt(25), nil))
+	b.SetFee(uint256.NewInt(3))
+	utx, err := b.Build()
+	require.NoError(t, err)
+	utx.Tx.ProofBytes, err = prover.Prove(utx.Witness, prKey, css)
+	require.NoError(t, err)
+	require.NoError(t, prover.SignZKTx(utx.Tx, sender.PrivateKey, utx.Witness.Alpha))
+
+	// the fee is proven, so the signer can not change it.
+	tampered := *utx.Tx
+	tampered.Fee = uint256.NewInt(1)
+	require.NoError(t, prover.SignZKTx(&tampered, sender.PrivateKey, utx.Witness.Alpha))
+	require.Error(t, verifier.VerifyZKTx(&tampered))
+
+	require.NoError(t, verifier.VerifyZKTx(utx.Tx))
+	_, err = sender.SyncSharedNotes()
+	require.NoError(t, err)
+	require.EqualValues(t, 40-25-3, sender.GetBalance().Uint64())
+
+	// the fee leaves the notes but stays in the supply until it is paid.
+	_supply := verifier.GetSupply()
+	require.Equal(t, supply.Total, _supply.Total)
+	require.EqualValues(t, supply.ShieldedPool.Uint64()-3, _supply.ShieldedPool.Uint64())
+	require.EqualValues(t, supply.PendingFees.Uint64()+3, _supply.PendingFees.Uint64())
+	require.NoError(t, verifier.AuditSupply())
+
+	//
+	// the producer of the block collects the fees.
+	_, err = verifier.SealBlock(receiver.Address)
+	require.Error(t, err)
+
+	producerKey, err := crypto.NewKey()
+	require.NoError(t, err)
+	producer := types.Pub2TransparentAddr(producerKey.Public())
+	blk, err := verifier.SealBlock(producer)
+	require.NoError(t, err)
+	require.Equal(t, _supply.PendingFees, blk.Fees)
+	require.Equal(t, verifier.GetZKTxCount(), blk.ToTxIdx)
+	require.Equal(t, blk.Fees, verifier.GetTransparentBalance(producer))
+
+	supply, _supply = _supply, verifier.GetSupply()
+	require.Equal(t, supply.Total, _supply.Total)
+	require.True(t, _supply.PendingFees.IsZero())
+	require.NoError(t, verifier.AuditSupply())
+
+	// the next block has no tx and no fee.
+	next, err := verifier.SealBlock(producer)
+	require.NoError(t, err)
+	require.Equal(t, blk.Height+1, next.Height)
+	require.Equal(t, blk.ToTxIdx, next.FromTxIdx)
+	require.Equal(t, next.FromTxIdx, next.ToTxIdx)
+	require.True(t, next.Fees.IsZero())
+
+	// the block served by rpc
+	srv := httptest.NewServer(verifier.NewRPCHandler())
+	defer srv.Close()
+	client := prover.NewRPCClient(srv.URL)
+	_blk, err := client.GetBlock(blk.Height)
+	require.NoError(t, err)
+	require.Equal(t, blk, _blk)
+	_blk, err = client.GetBlock(next.Height + 1)
+	require.NoError(t, err)
+	require.Nil(t, _blk)
+}
@@ -0,0 +1,370 @@
+package prover
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/hash"
+	"github.com/consensys/gnark-crypto/signature"
+	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/holiman/uint256"
+	"github.com/kysee/zkp/zk-asset/crypto"
+	"github.com/kysee/zkp/zk-asset/types"
+)
+
+// A tx is made in three steps which may run on different machines.
+//
+//  1. TxBuilder.Build: creates the new notes and the witness with the proof authorizing key.
+//  2. Prove:           proves the witness with the proving key.
+//  3. SignZKTx:        signs the tx with the spending key.
+//
+// `CreateZKTx` runs all of them at once.
+
+// UnprovenTx is a tx without the proof and the spend authorizing signature,
+// and the witness to prove it.
+type UnprovenTx struct {
+	Tx      *types.ZKTx
+	Witness *types.ZKWitness
+}
+
+// TxBuilder accumulates the inputs, the output and the fee of a tx.
+type TxBuilder struct {
+	pak *types.ProofAuthorizingKey
+
+	inputs []*NoteInput
+	root   []byte
+	depth  int
+
+	toPubKey signature.PublicKey
+	amount   *uint256.Int
+	memo     []byte
+	fee      *uint256.Int
+
+	// the transparent input (shield) and output (unshield).
+	// the input without the account mints, and the output without the account burns.
+	transparentFrom string
+	publicIn        *uint256.Int
+	transparentTo   string
+	publicOut       *uint256.Int
+}
+
+// NewTxBuilder returns the builder of a tx spending the notes of `pak`.
+func NewTxBuilder(pak *types.ProofAuthorizingKey) *TxBuilder {
+	return &TxBuilder{
+		pak: pak,
+		fee: uint256.NewInt(0),
+	}
+}
+
+// AddInput adds a note to be spent. Up to `types.NumInputNotes` notes can be added.
+// The Merkle proofs of all inputs should be of the root given to `SetAnchor`.
+func (b *TxBuilder) AddInput(in *NoteInput) error {
+	if len(b.inputs) >= types.NumInputNotes {
+		return fmt.Errorf("too many input notes: max(%d)", types.NumInputNotes)
+	}
+	if !in.Note.PubKey.Equal(b.pak.PublicKey()) {
+		return errors.New("the input note is not owned by the key")
+	}
+	b.inputs = append(b.inputs, in)
+	return nil
+}
+
+// SetAnchor sets the root of the note commitment tree and its depth, which the inputs are proven against.
+func (b *TxBuilder) SetAnchor(rootHash []byte, depth int) {
+	b.root, b.depth = rootHash, depth
+}
+
+// AddOutput sets the note sending `amount` to `toAddr` with `memo` of at most `types.MemoSize` bytes.
+// A tx has one output besides the change, which goes back to the spender.
+func (b *TxBuilder) AddOutput(toAddr string, amount *uint256.Int, memo []byte) error {
+	if b.toPubKey != nil {
+		return errors.New("the output is already added")
+	}
+	if len(memo) > types.MemoSize {
+		return types.ErrMemoTooLong
+	}
+	bz, err := types.DecodeAddress(toAddr)
+	if err != nil {
+		return err
+	}
+	toPubKey := crypto.NewPub()
+	if _, err := toPubKey.SetBytes(bz); err != nil {
+		return fmt.Errorf("wrong address: %w", err)
+	}
+	b.toPubKey, b.amount, b.memo = toPubKey, amount, memo
+	return nil
+}
+
+// AddTransparentInput shields `amount` from the transparent account `fromAddr`.
+// The tx should be signed with the key of the account by `SignTransparent`.
+func (b *TxBuilder) AddTransparentInput(fromAddr string, amount *uint256.Int) error {
+	if b.publicIn != nil {
+		return errors.New("the transparent input is already added")
+	}
+	if _, err := types.TransparentAddr2Pub(fromAddr); err != nil {
+		return err
+	}
+	b.transparentFrom, b.publicIn = fromAddr, amount
+	return nil
+}
+
+// AddMint mints `amount` into the notes of the tx.
+// The tx should be signed by the issuance keys with `SignMint`.
+func (b *TxBuilder) AddMint(amount *uint256.Int) error {
+	if b.publicIn != nil {
+		return errors.New("the transparent input is already added")
+	}
+	b.publicIn = amount
+	return nil
+}
+
+// AddBurn burns `amount` from the input notes of the tx.
+func (b *TxBuilder) AddBurn(amount *uint256.Int) error {
+	if b.publicOut != nil {
+		return errors.New("the transparent output is already added")
+	}
+	b.publicOut = amount
+	return nil
+}
+
+// AddTransparentOutput unshields `amount` to the transparent account `toAddr`.
+// A tx having the transparent output needs no shielded output.
+func (b *TxBuilder) AddTransparentOutput(toAddr string, amount *uint256.Int) error {
+	if b.publicOut != nil {
+		return errors.New("the transparent output is already added")
+	}
+	if _, err := types.TransparentAddr2Pub(toAddr); err != nil {
+		return err
+	}
+	b.transparentTo, b.publicOut = toAddr, amount
+	return nil
+}
+
+// SetFee sets the fee of the tx. The default is zero.
+func (b *TxBuilder) SetFee(fee *uint256.Int) {
+	b.fee = fee
+}
+
+// Build creates the new notes and returns the tx to be proven and signed.
+func (b *TxBuilder) Build() (*UnprovenTx, error) {
+	publicIn, publicOut := valueOrZero(b.publicIn), valueOrZero(b.publicOut)
+	if len(b.inputs) == 0 && publicIn.IsZero() {
+		return nil, errors.New("no input note")
+	}
+	// the root of the empty tree is nil, so the depth tells whether the anchor is set.
+	if b.depth <= 0 {
+		// the dummy inputs of a shielding tx are not checked, but the root is still a public input.
+		return nil, errors.New("no anchor")
+	}
+	if b.toPubKey == nil && publicOut.IsZero() {
+		return nil, errors.New("no output")
+	}
+
+	pk := b.pak.PublicKey()
+	toPubKey, amount := b.toPubKey, b.amount
+	if toPubKey == nil {
+		// an unshielding tx has no new note.
+		toPubKey, amount = pk, uint256.NewInt(0)
+	}
+	for _, v := range []*uint256.Int{amount, b.fee, publicIn, publicOut} {
+		if v.BitLen() > types.MaxValueBits {
+			return nil, fmt.Errorf("too large value: %s", v.Dec())
+		}
+	}
+
+	// fill the rest of the inputs with dummy notes.
+	inputs := append([]*NoteInput(nil), b.inputs...)
+	for len(inputs) < types.NumInputNotes {
+		inputs = append(inputs, newDummyInput(pk))
+	}
+
+	total := publicIn.Clone()
+	for _, in := range inputs {
+		var overflow bool
+		if total, overflow = new(uint256.Int).AddOverflow(total, in.Note.Balance); overflow {
+			return nil, errors.New("the total balance of the input notes overflows")
+		}
+	}
+	needAmt := new(uint256.Int).Add(amount, b.fee)
+	needAmt.Add(needAmt, publicOut)
+	if total.Lt(needAmt) {
+		return nil, fmt.Errorf("insufficient balance of the input notes: balance(%s), need(%s)", total.Dec(), needAmt.Dec())
+	}
+
+	newNote := &types.Note{
+		Version: types.NoteVersion,
+		PubKey:  toPubKey,
+		Balance: amount,
+		Salt:    types.RandBytes(32),
+	}
+	// no new note if the amount is zero.
+	var err error
+	var newNoteC []byte
+	var newSecretNote types.SecretNote
+	if !amount.IsZero() {
+		newSharedNote := newNote.ToSharedNote()
+		newSharedNote.Memo = b.memo
+		newNoteC = newNote.Commitment()
+		newSecretNote, err = types.EncryptSharedNote(newSharedNote, toPubKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	changeNote := &types.Note{
+		Version: types.NoteVersion,
+		PubKey:  pk,
+		Balance: new(uint256.Int).Sub(total, needAmt),
+		Salt:    types.RandBytes(32),
+	}
+	// no change note if the change is zero.
+	var changeNoteC []byte
+	var changeSecretNote types.SecretNote
+	if !changeNote.Balance.IsZero() {
+		changeNoteC = changeNote.Commitment()
+		changeSecretNote, err = types.EncryptSharedNote(changeNote.ToSharedNote(), pk)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	alpha := types.NewAlpha()
+	rk := b.pak.RandomizedKey(alpha).Bytes()
+
+	wtn := &types.ZKWitness{
+		Ak:                   b.pak.Ak.Bytes(),
+		Nk:                   b.pak.Nk,
+		Alpha:                alpha,
+		Rk:                   rk,
+		NoteVersion:          inputs[0].Note.Version,
+		Depth:                b.depth,
+		MerkleRoot:           b.root,
+		Amount:               amount,
+		Fee:                  b.fee,
+		ToPubKey:             toPubKey.Bytes(),
+		NewNoteSalt:          newNote.Salt,
+		ChangeNoteSalt:       changeNote.Salt,
+		NewNoteCommitment:    newNoteC,
+		ChangeNoteCommitment: changeNoteC,
+		PublicIn:             b.publicIn,
+		PublicOut:            b.publicOut,
+	}
+	for _, in := range inputs {
+		// the circuit takes the directions of the Merkle proof as the bits of PathIdx.
+		pathIdx, err := types.NoteCommitmentMerklePathIndex(in.Idx, in.NumLeaves)
+		if err != nil {
+			return nil, err
+		}
+		wtn.Inputs = append(wtn.Inputs, &types.WitnessInput{
+			Balance:    in.Note.Balance,
+			Salt:       in.Note.Salt,
+			Commitment: in.Note.Commitment(),
+			PathIdx:    pathIdx,
+			MerklePath: in.ProofPath,
+		})
+		wtn.Nullifiers = append(wtn.Nullifiers, in.Note.Nullifier(b.pak.Nk))
+	}
+
+	return &UnprovenTx{
+		Tx: &types.ZKTx{
+			MerkleRoot:         b.root,
+			Nullifiers:         wtn.Nullifiers,
+			NewNoteCommitments: []types.NoteCommitment{wtn.NewNoteCommitment, changeNoteC},
+			NewSecretNotes:     []types.SecretNote{newSecretNote, changeSecretNote},
+			Rk:                 rk,
+			PublicIn:           b.publicIn,
+			TransparentFrom:    b.transparentFrom,
+			PublicOut:          b.publicOut,
+			TransparentTo:      b.transparentTo,
+			Fee:                b.fee,
+		},
+		Witness: wtn,
+	}, nil
+}
+
+// Prove generates the proof of `wtn`.
+func Prove(wtn *types.ZKWitness, provingKey plonk.ProvingKey, ccs constraint.ConstraintSystem) ([]byte, error) {
+	assignment, err := wtn.Assignment()
+	if err != nil {
+		return nil, err
+	}
+	fullWtn, err := frontend.NewWitness(assignment, ecc.BN254.ScalarField())
+	if err != nil {
+		return nil, err
+	}
+
+	proof, err := plonk.Prove(
+		ccs,
+		provingKey,
+		fullWtn,
+		//backend.WithSolverOptions(
+		//	solver.WithLogger(
+		//zerolog.New(os.Stdout).Level(zerolog.TraceLevel).With().Timestamp().Logger()
+		//	),
+		//),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	bufProof := bytes.NewBuffer(nil)
+	if _, err := proof.WriteTo(bufProof); err != nil {
+		return nil, err
+	}
+	return bufProof.Bytes(), nil
+}
+
+// SignZKTx signs `tx` with the spending key `ask` randomized by `alpha`, the one in the witness of the tx.
+// The signature does not cover the proof, so the tx can be signed before or while it is proven.
+func SignZKTx(tx *types.ZKTx, ask signature.Signer, alpha []byte) error {
+	if !bytes.Equal(types.RandomizeKey(ask.Public(), alpha).Bytes(), tx.Rk) {
+		return errors.New("the tx is not of the spending key")
+	}
+	rsk, err := types.RandomizeSpendingKey(ask, alpha)
+	if err != nil {
+		return err
+	}
+	sig, err := rsk.Sign(tx.SigHash(), hash.MIMC_BN254.New())
+	if err != nil {
+		return err
+	}
+	tx.SpendAuthSig = sig
+	return nil
+}
+
+// SignTransparent signs `tx` with the key of its transparent input account.
+func SignTransparent(tx *types.ZKTx, key signature.Signer) error {
+	if types.Pub2TransparentAddr(key.Public()) != tx.TransparentFrom {
+		return errors.New("the tx is not of the transparent key")
+	}
+	sig, err := key.Sign(tx.SigHash(), hash.MIMC_BN254.New())
+	if err != nil {
+		return err
+	}
+	tx.TransparentSig = sig
+	return nil
+}
+
+// SignMint adds the signature of the issuance key `issuer` to the mint tx.
+func SignMint(tx *types.ZKTx, issuer signature.Signer) error {
+	if !tx.IsMint() {
+		return errors.New("the tx is not a mint")
+	}
+	sig, err := issuer.Sign(tx.SigHash(), hash.MIMC_BN254.New())
+	if err != nil {
+		return err
+	}
+	tx.IssuerSigs = append(tx.IssuerSigs, &types.IssuerSig{PubKey: issuer.Public().Bytes(), Sig: sig})
+	return nil
+}
+
+func valueOrZero(v *uint256.Int) *uint256.Int {
+	if v == nil {
+		return uint256.NewInt(0)
+	}
+	return v
+}
@@ -0,0 +1,100 @@
+package prover
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/kysee/zkp/zk-asset/types"
+)
+
+// ChainSource is the view of the ledger that a Wallet needs to find and spend its notes.
+// `verifier.LocalChain` serves it in-process and `RPCClient` serves it from a remote verifier.
+//
+// The range getters return the items in [from, to) and may return fewer items than requested;
+// an empty result means there are no more items from `from`.
+type ChainSource interface {
+	GetZKTxs(from, to int) ([]*types.ZKTx, error)
+	GetCompactBlock(from, to int) (*types.CompactBlock, error)
+	GetNoteCommitments(from, to int) ([]types.NoteCommitment, error)
+	GetSecretNotes(from, to int) ([]types.SecretNote, error)
+	HasNullifier(nullifier types.NoteNullifier) (bool, error)
+	GetMerkleRoot() (*types.MerkleRootResult, error)
+}
+
+// Broadcaster submits ZKTxs to the ledger.
+// It returns the index of the submitted tx.
+type Broadcaster interface {
+	SubmitZKTx(zktx *types.ZKTx) (int, error)
+}
+
+var (
+	_ ChainSource = (*RPCClient)(nil)
+	_ Broadcaster = (*RPCClient)(nil)
+)
+
+var errTreeUpdated = errors.New("the note commitment tree was updated while fetching")
+
+// chainBatchSize is the number of items requested from a ChainSource at once.
+const chainBatchSize = 256
+
+// GetNoteCommitmentMerkle fetches all note commitments from `chain` and builds the Merkle proof of `commitment` locally,
+// so the chain does not learn which note is about to be spent.
+func GetNoteCommitmentMerkle(chain ChainSource, commitment types.NoteCommitment) (root []byte, proofSet [][]byte, depth int, idx, numLeaves uint64, err error) {
+	commitments, info, err := fetchNoteCommitments(chain)
+	if err != nil {
+		return
+	}
+	root, proofSet, idx, numLeaves, err = types.NoteCommitmentMerkleProof(commitments, commitment)
+	if err != nil {
+		return
+	}
+	if !bytes.Equal(root, info.Root) {
+		err = errTreeUpdated
+		return
+	}
+	depth = info.Depth
+	return
+}
+
+// GetNoteInputs is `GetNoteCommitmentMerkle` for several notes.
+// The Merkle proofs of the returned inputs are of the same root.
+func GetNoteInputs(chain ChainSource, notes []*types.Note) (inputs []*NoteInput, root []byte, depth int, err error) {
+	commitments, info, err := fetchNoteCommitments(chain)
+	if err != nil {
+		return
+	}
+	for _, n := range notes {
+		_root, proofSet, idx, numLeaves, err := types.NoteCommitmentMerkleProof(commitments, n.Commitment())
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		if !bytes.Equal(_root, info.Root) {
+			return nil, nil, 0, errTreeUpdated
+		}
+		inputs = append(inputs, &NoteInput{Note: n, ProofPath: proofSet, Idx: idx, NumLeaves: numLeaves})
+	}
+	return inputs, info.Root, info.Depth, nil
+}
+
+// fetchNoteCommitments fetches all note commitments of the tree whose root is returned together.
+// The caller should check the root against the one computed from the commitments,
+// since the tree may be updated while fetching.
+func fetchNoteCommitments(chain ChainSource) ([]types.NoteCommitment, *types.MerkleRootResult, error) {
+	info, err := chain.GetMerkleRoot()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	commitments := make([]types.NoteCommitment, 0, info.NumLeaves)
+	for len(commitments) < info.NumLeaves {
+		cms, err := chain.GetNoteCommitments(len(commitments), info.NumLeaves)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(cms) == 0 {
+			return nil, nil, errors.New("note commitments are missing")
+		}
+		commitments = append(commitments, cms...)
+	}
+	return commitments, info, nil
+}
@@ -0,0 +1,63 @@
+package prover
+
+import (
+	"github.com/kysee/zkp/zk-asset/crypto"
+)
+
+// DefaultAccountGapLimit is the number of consecutive unused accounts
+// after which `RestoreWallets` stops looking for more accounts.
+const DefaultAccountGapLimit = 5
+
+// NewWalletFromMnemonic creates the wallet of `account` derived from `mnemonic`.
+// `passphrase` is the optional BIP-39 passphrase.
+func NewWalletFromMnemonic(mnemonic, passphrase string, account uint32, chain ChainSource) (*Wallet, error) {
+	seed, err := crypto.MnemonicToSeed(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	prvk, err := crypto.AccountKey(seed, account)
+	if err != nil {
+		return nil, err
+	}
+	return NewWalletWithKey(prvk, chain), nil
+}
+
+// RestoreWallets restores the accounts derived from `mnemonic` by rescanning `chain`.
+// It derives accounts in order until `gapLimit` consecutive accounts have no note,
+// and returns the wallets of the accounts up to the last used one, at least the account 0.
+// The returned wallets are synced to the tip of the chain.
+// If `gapLimit` is not positive, `DefaultAccountGapLimit` is used.
+func RestoreWallets(mnemonic, passphrase string, chain ChainSource, gapLimit int) ([]*Wallet, error) {
+	if gapLimit <= 0 {
+		gapLimit = DefaultAccountGapLimit
+	}
+	seed, err := crypto.MnemonicToSeed(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	var ws []*Wallet
+	lastUsed := 0
+	for len(ws) < lastUsed+1+gapLimit {
+		// scan the next accounts in one pass over the chain.
+		var batch []*Wallet
+		for account := len(ws); account < lastUsed+1+gapLimit; account++ {
+			prvk, err := crypto.AccountKey(seed, uint32(account))
+			if err != nil {
+				return nil, err
+			}
+			batch = append(batch, NewWalletWithKey(prvk, chain))
+		}
+		if err := SyncWallets(batch); err != nil {
+			return nil, err
+		}
+
+		for _, w := range batch {
+			if len(w.notes) > 0 {
+				lastUsed = len(ws)
+			}
+			ws = append(ws, w)
+		}
+	}
+	return ws[:lastUsed+1], nil
+}
@@ -0,0 +1,256 @@
+package prover
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kysee/zkp/zk-asset/crypto"
+	"github.com/kysee/zkp/zk-asset/types"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+// keystoreVersion is the version of the wallet file format.
+const keystoreVersion = 1
+
+// The scrypt parameters for new wallet files.
+// N=2^15, r=8 takes about 100ms and 32MB per key derivation.
+const (
+	keystoreScryptN      = 1 << 15
+	keystoreScryptR      = 8
+	keystoreScryptP      = 1
+	keystoreScryptKeyLen = chacha20poly1305.KeySize
+
+	// keystoreScryptMaxMem bounds the memory (128*N*r bytes) of the parameters read from a file.
+	keystoreScryptMaxMem = 256 << 20
+)
+
+var (
+	ErrWrongPassphrase   = errors.New("wrong passphrase")
+	ErrKeystoreCorrupted = errors.New("the wallet file is corrupted")
+)
+
+// keystoreFile is the wallet file format.
+//
+// The spending key, the notes, the sync checkpoint and the history of a wallet
+// are encrypted with ChaCha20-Poly1305 under a key derived from the passphrase by scrypt.
+// The header is authenticated as the associated data of the ciphertext.
+// The checksum detects a corrupted file without the passphrase.
+type keystoreFile struct {
+	keystoreHeader
+	Ciphertext []byte `json:"ciphertext"`
+	// Checksum is the hex encoded SHA-256 of the header and the ciphertext.
+	Checksum string `json:"checksum"`
+}
+
+type keystoreHeader struct {
+	Version int `json:"version"`
+	// Address is not secret; it lets tools identify the wallet file without the passphrase.
+	Address string      `json:"address"`
+	KDF     keystoreKDF `json:"kdf"`
+	Nonce   []byte      `json:"nonce"`
+}
+
+type keystoreKDF struct {
+	Name string `json:"name"`
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+	Salt []byte `json:"salt"`
+}
+
+// keystorePayload is the plaintext of the ciphertext in a wallet file.
+type keystorePayload struct {
+	PrivateKey []byte       `json:"privateKey"`
+	State      *WalletState `json:"state"`
+}
+
+// Save writes the wallet into the file at `path`, encrypted under `passphrase`.
+// The file is replaced atomically; a crash while saving leaves the previous file intact.
+func (w *Wallet) Save(path, passphrase string) error {
+	payload := &keystorePayload{
+		PrivateKey: w.PrivateKey.Bytes(),
+		State:      w.State(),
+	}
+	ksf, err := sealKeystore(w.Address, payload, passphrase)
+	if err != nil {
+		return err
+	}
+	return writeKeystore(path, ksf)
+}
+
+// LoadWallet reads the wallet file at `path` saved by `Save`.
+// The loaded wallet resumes the sync from the saved checkpoint.
+func LoadWallet(path, passphrase string, chain ChainSource) (*Wallet, error) {
+	ksf, err := readKeystore(path)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := openKeystore(ksf, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	prvk, err := crypto.KeyFromBytes(payload.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKeystoreCorrupted, err)
+	}
+	w := NewWalletWithKey(prvk, chain)
+	if w.Address != ksf.Address {
+		return nil, fmt.Errorf("%w: the address is not of the key", ErrKeystoreCorrupted)
+	}
+	if payload.State != nil {
+		if err := w.RestoreState(payload.State); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrKeystoreCorrupted, err)
+		}
+	}
+	return w, nil
+}
+
+// ChangePassphrase re-encrypts the wallet file at `path` under `newPassphrase`.
+func ChangePassphrase(path, oldPassphrase, newPassphrase string) error {
+	ksf, err := readKeystore(path)
+	if err != nil {
+		return err
+	}
+	payload, err := openKeystore(ksf, oldPassphrase)
+	if err != nil {
+		return err
+	}
+	newKsf, err := sealKeystore(ksf.Address, payload, newPassphrase)
+	if err != nil {
+		return err
+	}
+	return writeKeystore(path, newKsf)
+}
+
+func sealKeystore(address string, payload *keystorePayload, passphrase string) (*keystoreFile, error) {
+	plaintext, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	ksf := &keystoreFile{
+		keystoreHeader: keystoreHeader{
+			Version: keystoreVersion,
+			Address: address,
+			KDF: keystoreKDF{
+				Name: "scrypt",
+				N:    keystoreScryptN,
+				R:    keystoreScryptR,
+				P:    keystoreScryptP,
+				Salt: types.RandBytes(32),
+			},
+			Nonce: types.RandBytes(chacha20poly1305.NonceSize),
+		},
+	}
+	key, err := ksf.KDF.deriveKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	ad, err := json.Marshal(&ksf.keystoreHeader)
+	if err != nil {
+		return nil, err
+	}
+	if ksf.Ciphertext, err = crypto.ChaCha20Poly1305_Encrypt(key, ksf.Nonce, plaintext, ad); err != nil {
+		return nil, err
+	}
+	ksf.Checksum = ksf.checksum(ad)
+	return ksf, nil
+}
+
+func openKeystore(ksf *keystoreFile, passphrase string) (*keystorePayload, error) {
+	ad, err := json.Marshal(&ksf.keystoreHeader)
+	if err != nil {
+		return nil, err
+	}
+	if ksf.checksum(ad) != ksf.Checksum {
+		return nil, fmt.Errorf("%w: wrong checksum", ErrKeystoreCorrupted)
+	}
+	if ksf.Version != keystoreVersion {
+		return nil, fmt.Errorf("unsupported wallet file version: %d", ksf.Version)
+	}
+
+	key, err := ksf.KDF.deriveKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	// the checksum is correct, so a failure of the authentication means a wrong passphrase.
+	plaintext, err := crypto.ChaCha20Poly1305_Decrypt(key, ksf.Nonce, ksf.Ciphertext, ad)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+
+	payload := &keystorePayload{}
+	if err := json.Unmarshal(plaintext, payload); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKeystoreCorrupted, err)
+	}
+	return payload, nil
+}
+
+func (ksf *keystoreFile) checksum(header []byte) string {
+	h := sha256.New()
+	h.Write(header)
+	h.Write(ksf.Ciphertext)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (kdf *keystoreKDF) deriveKey(passphrase string) ([]byte, error) {
+	if kdf.Name != "scrypt" {
+		return nil, fmt.Errorf("unsupported kdf: %s", kdf.Name)
+	}
+	// the parameters come from the file; reject ones which would exhaust the memory.
+	if kdf.N <= 1 || kdf.N&(kdf.N-1) != 0 || kdf.R <= 0 || kdf.P <= 0 || kdf.R*kdf.P >= 1<<10 ||
+		kdf.N > keystoreScryptMaxMem/(128*kdf.R) {
+		return nil, fmt.Errorf("%w: wrong scrypt parameters", ErrKeystoreCorrupted)
+	}
+	return scrypt.Key([]byte(passphrase), kdf.Salt, kdf.N, kdf.R, kdf.P, keystoreScryptKeyLen)
+}
+
+func readKeystore(path string) (*keystoreFile, error) {
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ksf := &keystoreFile{}
+	if err := json.Unmarshal(bz, ksf); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKeystoreCorrupted, err)
+	}
+	return ksf, nil
+}
+
+// writeKeystore writes the file into a temporary file in the same directory and renames it to `path`.
+func writeKeystore(path string, ksf *keystoreFile) error {
+	bz, err := json.MarshalIndent(ksf, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after the rename succeeds
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(bz); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,137 @@
+package prover
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/kysee/zkp/zk-asset/types"
+	"github.com/stretchr/testify/require"
+)
+
+func newKeystoreTestWallet(t *testing.T) *Wallet {
+	w := NewWallet(nil)
+	for i := 1; i <= 3; i++ {
+		w.AddSharedNote(&types.SharedNote{
+			Version: types.NoteVersion,
+			Balance: uint256.NewInt(uint64(i * 100)),
+			Salt:    types.RandBytes(32),
+			Memo:    []byte{},
+		})
+	}
+	st := w.State()
+	st.Checkpoint = Checkpoint{TxIdx: 7, CommitmentIdx: 11}
+	st.History = []*TxRecord{{TxIdx: 6, Kind: TxReceived, Amount: uint256.NewInt(100), Commitment: st.Notes[0].Commitment}}
+	require.NoError(t, w.RestoreState(st))
+	return w
+}
+
+func TestKeystore_SaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "wallet.json")
+	w := newKeystoreTestWallet(t)
+
+	require.NoError(t, w.Save(path, "passphrase"))
+
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	// save again over the existing file; no temporary file should be left.
+	require.NoError(t, w.Save(path, "passphrase"))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	loaded, err := LoadWallet(path, "passphrase", nil)
+	require.NoError(t, err)
+	require.Equal(t, w.Address, loaded.Address)
+	require.Equal(t, w.PrivateKey.Bytes(), loaded.PrivateKey.Bytes())
+	require.Equal(t, w.Checkpoint(), loaded.Checkpoint())
+	require.Equal(t, w.GetBalance(), loaded.GetBalance())
+	require.Equal(t, w.History(), loaded.History())
+
+	bz0, err := json.Marshal(w.State())
+	require.NoError(t, err)
+	bz1, err := json.Marshal(loaded.State())
+	require.NoError(t, err)
+	require.JSONEq(t, string(bz0), string(bz1))
+
+	// the spending key should not be stored in plaintext.
+	bz, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(bz), "privateKey")
+	require.Contains(t, string(bz), w.Address)
+
+	_, err = LoadWallet(path, "wrong passphrase", nil)
+	require.ErrorIs(t, err, ErrWrongPassphrase)
+}
+
+func TestKeystore_ChangePassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallet.json")
+	w := newKeystoreTestWallet(t)
+	require.NoError(t, w.Save(path, "old"))
+
+	require.ErrorIs(t, ChangePassphrase(path, "wrong", "new"), ErrWrongPassphrase)
+	require.NoError(t, ChangePassphrase(path, "old", "new"))
+
+	_, err := LoadWallet(path, "old", nil)
+	require.ErrorIs(t, err, ErrWrongPassphrase)
+	loaded, err := LoadWallet(path, "new", nil)
+	require.NoError(t, err)
+	require.Equal(t, w.GetBalance(), loaded.GetBalance())
+}
+
+func TestKeystore_Corrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallet.json")
+	w := newKeystoreTestWallet(t)
+	require.NoError(t, w.Save(path, "passphrase"))
+
+	bz, err := os.ReadFile(path)
+	require.NoError(t, err)
+	ksf := &keystoreFile{}
+	require.NoError(t, json.Unmarshal(bz, ksf))
+
+	corrupt := func(f func(ksf *keystoreFile)) string {
+		_ksf := *ksf
+		_ksf.Ciphertext = append([]byte(nil), ksf.Ciphertext...)
+		f(&_ksf)
+		p := filepath.Join(t.TempDir(), "corrupted.json")
+		require.NoError(t, writeKeystore(p, &_ksf))
+		return p
+	}
+
+	// a flipped bit in the ciphertext
+	_, err = LoadWallet(corrupt(func(ksf *keystoreFile) { ksf.Ciphertext[10] ^= 0x01 }), "passphrase", nil)
+	require.ErrorIs(t, err, ErrKeystoreCorrupted)
+
+	// a modified header
+	_, err = LoadWallet(corrupt(func(ksf *keystoreFile) { ksf.Address = "bz" }), "passphrase", nil)
+	require.ErrorIs(t, err, ErrKeystoreCorrupted)
+
+	// a truncated file
+	p := filepath.Join(t.TempDir(), "truncated.json")
+	require.NoError(t, os.WriteFile(p, bz[:len(bz)/2], 0600))
+	_, err = LoadWallet(p, "passphrase", nil)
+	require.ErrorIs(t, err, ErrKeystoreCorrupted)
+}
+
+func TestKeystore_ScryptParams(t *testing.T) {
+	kdf := keystoreKDF{Name: "scrypt", N: keystoreScryptN, R: keystoreScryptR, P: keystoreScryptP, Salt: types.RandBytes(32)}
+	_, err := kdf.deriveKey("passphrase")
+	require.NoError(t, err)
+
+	// N=2^20, r=8 needs 1GiB.
+	_kdf := kdf
+	_kdf.N = 1 << 20
+	_, err = _kdf.deriveKey("passphrase")
+	require.ErrorIs(t, err, ErrKeystoreCorrupted)
+
+	// N is not a power of two.
+	_kdf = kdf
+	_kdf.N = keystoreScryptN + 1
+	_, err = _kdf.deriveKey("passphrase")
+	require.ErrorIs(t, err, ErrKeystoreCorrupted)
+}
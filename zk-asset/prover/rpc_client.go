@@ -0,0 +1,207 @@
+package prover
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/plonk"
+	"github.com/holiman/uint256"
+	"github.com/kysee/zkp/zk-asset/types"
+)
+
+// RPCClient talks to the verifier's HTTP+JSON RPC service.
+// It lets a wallet run in a different process from the ledger.
+type RPCClient struct {
+	baseURL string
+	hc      *http.Client
+}
+
+func NewRPCClient(baseURL string) *RPCClient {
+	return &RPCClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		hc:      &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// SubmitZKTx sends the zktx to the verifier and returns its index in the ledger.
+func (c *RPCClient) SubmitZKTx(zktx *types.ZKTx) (int, error) {
+	ret := &types.SubmitZKTxResult{}
+	if err := c.call(http.MethodPost, "/zktx", nil, zktx, ret); err != nil {
+		return -1, err
+	}
+	return ret.TxIdx, nil
+}
+
+// GetZKTx returns the zktx at `idx`, or nil if it does not exist.
+func (c *RPCClient) GetZKTx(idx int) (*types.ZKTx, error) {
+	ret := &types.ZKTx{}
+	err := c.call(http.MethodGet, "/zktx/"+strconv.Itoa(idx), nil, nil, ret)
+	if errors.Is(err, errNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// GetZKTxs returns the zktxs in the range [from, to).
+// The verifier may return fewer items than requested.
+func (c *RPCClient) GetZKTxs(from, to int) ([]*types.ZKTx, error) {
+	ret := &types.ZKTxsResult{}
+	if err := c.call(http.MethodGet, "/zktxs", rangeQuery(from, to), nil, ret); err != nil {
+		return nil, err
+	}
+	return ret.ZKTxs, nil
+}
+
+// GetCompactBlock returns the compact representation of the zktxs in the range [from, to).
+// The verifier may return fewer txs than requested.
+func (c *RPCClient) GetCompactBlock(from, to int) (*types.CompactBlock, error) {
+	ret := &types.CompactBlock{}
+	if err := c.call(http.MethodGet, "/compactblock", rangeQuery(from, to), nil, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// GetNoteCommitments returns the note commitments in the range [from, to).
+// The verifier may return fewer items than requested.
+func (c *RPCClient) GetNoteCommitments(from, to int) ([]types.NoteCommitment, error) {
+	ret := &types.NoteCommitmentsResult{}
+	if err := c.call(http.MethodGet, "/commitments", rangeQuery(from, to), nil, ret); err != nil {
+		return nil, err
+	}
+	return ret.Commitments, nil
+}
+
+// GetSecretNotes returns the secret notes in the range [from, to).
+// The verifier may return fewer items than requested.
+func (c *RPCClient) GetSecretNotes(from, to int) ([]types.SecretNote, error) {
+	ret := &types.SecretNotesResult{}
+	if err := c.call(http.MethodGet, "/secretnotes", rangeQuery(from, to), nil, ret); err != nil {
+		return nil, err
+	}
+	return ret.SecretNotes, nil
+}
+
+// HasNullifier reports whether the nullifier is already recorded in the ledger.
+func (c *RPCClient) HasNullifier(nullifier types.NoteNullifier) (bool, error) {
+	ret := &types.NullifierResult{}
+	if err := c.call(http.MethodGet, "/nullifiers/"+hex.EncodeToString(nullifier), nil, nil, ret); err != nil {
+		return false, err
+	}
+	return ret.Exists, nil
+}
+
+// GetMerkleRoot returns the current root, the number of leaves and the depth of the note commitment tree.
+func (c *RPCClient) GetMerkleRoot() (*types.MerkleRootResult, error) {
+	ret := &types.MerkleRootResult{}
+	if err := c.call(http.MethodGet, "/root", nil, nil, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// GetTransparentBalance returns the balance of the transparent account `addr`.
+func (c *RPCClient) GetTransparentBalance(addr string) (*uint256.Int, error) {
+	ret := &types.TransparentBalanceResult{}
+	if err := c.call(http.MethodGet, "/transparent/"+url.PathEscape(addr), nil, nil, ret); err != nil {
+		return nil, err
+	}
+	return ret.Balance, nil
+}
+
+// GetSupply returns the total supply and the values of the pools.
+func (c *RPCClient) GetSupply() (*types.SupplyResult, error) {
+	ret := &types.SupplyResult{}
+	if err := c.call(http.MethodGet, "/supply", nil, nil, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// GetBlock returns the block at `height`, or nil if it does not exist.
+func (c *RPCClient) GetBlock(height int) (*types.Block, error) {
+	ret := &types.Block{}
+	err := c.call(http.MethodGet, "/block/"+strconv.Itoa(height), nil, nil, ret)
+	if errors.Is(err, errNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// GetVerifyingKey returns the verifying key of the verifier.
+func (c *RPCClient) GetVerifyingKey() (plonk.VerifyingKey, error) {
+	ret := &types.VerifyingKeyResult{}
+	if err := c.call(http.MethodGet, "/vk", nil, nil, ret); err != nil {
+		return nil, err
+	}
+	vk := plonk.NewVerifyingKey(ecc.BN254)
+	if _, err := vk.ReadFrom(bytes.NewReader(ret.VerifyingKey)); err != nil {
+		return nil, err
+	}
+	return vk, nil
+}
+
+var errNotFound = errors.New("not found")
+
+func (c *RPCClient) call(method, path string, query url.Values, req, resp any) error {
+	u := c.baseURL + path
+	if query != nil {
+		u += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if req != nil {
+		bz, err := json.Marshal(req)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(bz)
+	}
+
+	httpReq, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+	if req != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	httpResp, err := c.hc.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		rpcErr := &types.RPCError{}
+		_ = json.NewDecoder(httpResp.Body).Decode(rpcErr)
+		if httpResp.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("%w: %s", errNotFound, rpcErr.Error)
+		}
+		return fmt.Errorf("rpc error: status(%d), %s", httpResp.StatusCode, rpcErr.Error)
+	}
+	return json.NewDecoder(httpResp.Body).Decode(resp)
+}
+
+func rangeQuery(from, to int) url.Values {
+	return url.Values{
+		"from": []string{strconv.Itoa(from)},
+		"to":   []string{strconv.Itoa(to)},
+	}
+}
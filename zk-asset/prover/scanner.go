@@ -0,0 +1,104 @@
+package prover
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/consensys/gnark-crypto/signature"
+	"github.com/kysee/zkp/zk-asset/types"
+)
+
+// ScanResult is a secret note decrypted by one of the keys of a Scanner.
+type ScanResult struct {
+	// NoteIdx is the index of the secret note in the scanned batch.
+	NoteIdx int
+	// KeyIdx is the index of the key which decrypted the secret note.
+	KeyIdx     int
+	SharedNote *types.SharedNote
+}
+
+// Scanner trial-decrypts batches of secret notes with several keys across CPU cores.
+type Scanner struct {
+	keys    []signature.Signer
+	workers int
+}
+
+// NewScanner returns a Scanner of `keys`.
+// If `workers` is not positive, it uses as many workers as CPU cores.
+func NewScanner(keys []signature.Signer, workers int) *Scanner {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	return &Scanner{
+		keys:    keys,
+		workers: workers,
+	}
+}
+
+// Scan trial-decrypts every secret note with every key.
+// `commitments[i]` is the note commitment of `secretNotes[i]`.
+// It returns the decrypted notes ordered by NoteIdx, and by KeyIdx for the same note.
+// Empty secret notes are skipped.
+func (s *Scanner) Scan(commitments []types.NoteCommitment, secretNotes []types.SecretNote) []*ScanResult {
+	return s.scan(len(secretNotes), func(i int, key signature.Signer) (*types.SharedNote, error) {
+		if len(secretNotes[i]) == 0 || i >= len(commitments) {
+			return nil, types.ErrNotMyNote
+		}
+		return types.TrialDecryptSharedNote(secretNotes[i], commitments[i], key)
+	})
+}
+
+// ScanCompact trial-decrypts every compact output with every key.
+// The NoteIdx of a result is the index of the output in `outputs`.
+// The memos of the returned notes are nil.
+func (s *Scanner) ScanCompact(outputs []*types.CompactOutput) []*ScanResult {
+	return s.scan(len(outputs), func(i int, key signature.Signer) (*types.SharedNote, error) {
+		return types.DecryptCompactOutput(outputs[i], key)
+	})
+}
+
+func (s *Scanner) scan(n int, decrypt func(i int, key signature.Signer) (*types.SharedNote, error)) []*ScanResult {
+	var (
+		mtx     sync.Mutex
+		results []*ScanResult
+		wg      sync.WaitGroup
+	)
+
+	// the notes are split into contiguous chunks, one chunk per worker.
+	chunk := (n + s.workers - 1) / s.workers
+	for from := 0; from < n; from += chunk {
+		to := min(from+chunk, n)
+
+		wg.Add(1)
+		go func(from, to int) {
+			defer wg.Done()
+
+			var found []*ScanResult
+			for i := from; i < to; i++ {
+				for k, key := range s.keys {
+					sn, err := decrypt(i, key)
+					if err != nil {
+						continue
+					}
+					found = append(found, &ScanResult{NoteIdx: i, KeyIdx: k, SharedNote: sn})
+				}
+			}
+
+			if len(found) > 0 {
+				mtx.Lock()
+				results = append(results, found...)
+				mtx.Unlock()
+			}
+		}(from, to)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].NoteIdx != results[j].NoteIdx {
+			return results[i].NoteIdx < results[j].NoteIdx
+		}
+		return results[i].KeyIdx < results[j].KeyIdx
+	})
+	return results
+}
@@ -0,0 +1,200 @@
+package prover
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/signature"
+	"github.com/holiman/uint256"
+	"github.com/kysee/zkp/zk-asset/crypto"
+	"github.com/kysee/zkp/zk-asset/types"
+	"github.com/stretchr/testify/require"
+)
+
+// newSecretNote returns the commitment and the secret note of a new note sent to `to`.
+func newSecretNote(t testing.TB, balance uint64, to signature.PublicKey) (types.NoteCommitment, types.SecretNote) {
+	sn := &types.SharedNote{
+		Version: types.NoteVersion,
+		Balance: uint256.NewInt(balance),
+		Salt:    types.RandBytes(32),
+		Memo:    []byte{},
+	}
+	secretNote, err := types.EncryptSharedNote(sn, to)
+	require.NoError(t, err)
+	return sn.ToNoteOf(to).Commitment(), secretNote
+}
+
+func TestScanner(t *testing.T) {
+	var keys []signature.Signer
+	for i := 0; i < 5; i++ {
+		k, err := crypto.NewKey()
+		require.NoError(t, err)
+		keys = append(keys, k)
+	}
+	// the scanner has only the first 3 keys.
+	myKeys := keys[:3]
+
+	var commitments []types.NoteCommitment
+	var secretNotes []types.SecretNote
+	for i := 0; i < 200; i++ {
+		if i%7 == 0 {
+			commitments = append(commitments, nil)
+			secretNotes = append(secretNotes, nil)
+			continue
+		}
+		cm, secretNote := newSecretNote(t, uint64(i), keys[i%len(keys)].Public())
+		commitments = append(commitments, cm)
+		secretNotes = append(secretNotes, secretNote)
+	}
+
+	for _, workers := range []int{0, 1, 3, 1000} {
+		results := NewScanner(myKeys, workers).Scan(commitments, secretNotes)
+
+		// the result should be same as the one of the serial loop.
+		var expected []*ScanResult
+		for i, sn := range secretNotes {
+			if len(sn) == 0 {
+				continue
+			}
+			for k, key := range myKeys {
+				_sharedNote, err := types.DecryptSharedNote(sn, commitments[i], key)
+				if err != nil {
+					continue
+				}
+				expected = append(expected, &ScanResult{NoteIdx: i, KeyIdx: k, SharedNote: _sharedNote})
+			}
+		}
+		require.Equal(t, expected, results)
+
+		for _, r := range results {
+			require.Less(t, r.NoteIdx%len(keys), len(myKeys))
+			require.Equal(t, r.NoteIdx%len(keys), r.KeyIdx)
+			require.Equal(t, uint64(r.NoteIdx), r.SharedNote.Balance.Uint64())
+		}
+	}
+}
+
+func TestTrialDecryptSharedNote_Tampered(t *testing.T) {
+	key, err := crypto.NewKey()
+	require.NoError(t, err)
+	cm, secretNote := newSecretNote(t, 1, key.Public())
+
+	_, err = types.TrialDecryptSharedNote(secretNote, cm, key)
+	require.NoError(t, err)
+
+	// the ciphertext is bound to the note commitment.
+	otherCm, _ := newSecretNote(t, 1, key.Public())
+	_, err = types.TrialDecryptSharedNote(secretNote, otherCm, key)
+	require.ErrorIs(t, err, types.ErrNotMyNote)
+
+	// the prefix check passes, but the authentication fails.
+	secretNote[len(secretNote)-1] ^= 0xff
+	_, err = types.TrialDecryptSharedNote(secretNote, cm, key)
+	require.ErrorIs(t, err, types.ErrNotMyNote)
+
+	_, err = types.TrialDecryptSharedNote(secretNote[:32], cm, key)
+	require.ErrorIs(t, err, types.ErrNotMyNote)
+}
+
+func TestScanner_Compact(t *testing.T) {
+	myKey, err := crypto.NewKey()
+	require.NoError(t, err)
+	otherKey, err := crypto.NewKey()
+	require.NoError(t, err)
+
+	zktx := types.NewZKTx()
+	var notes []*types.SharedNote
+	for i, to := range []signature.Signer{myKey, otherKey} {
+		sn := &types.SharedNote{
+			Version: types.NoteVersion,
+			Balance: uint256.NewInt(uint64(i + 1)),
+			Salt:    types.RandBytes(32),
+			Memo:    []byte("memo is not in the compact output"),
+		}
+		notes = append(notes, sn)
+		zktx.NewNoteCommitments[i] = sn.ToNoteOf(to.Public()).Commitment()
+		zktx.NewSecretNotes[i], err = types.EncryptSharedNote(sn, to.Public())
+		require.NoError(t, err)
+	}
+
+	ctx := types.NewCompactTx(zktx, 0)
+	require.Len(t, ctx.Outputs, 2)
+	require.Len(t, ctx.Outputs[0].CiphertextPrefix, types.CompactNoteCiphertextSize)
+
+	results := NewScanner([]signature.Signer{myKey}, 0).ScanCompact(ctx.Outputs)
+	require.Len(t, results, 1)
+	require.Equal(t, 0, results[0].NoteIdx)
+	require.Equal(t, notes[0].Balance, results[0].SharedNote.Balance)
+	require.Equal(t, notes[0].Salt, results[0].SharedNote.Salt)
+	require.Nil(t, results[0].SharedNote.Memo)
+
+	// the prefix is not authenticated, so a note not matching its commitment should be rejected.
+	ctx.Outputs[0].Commitment = ctx.Outputs[1].Commitment
+	require.Empty(t, NewScanner([]signature.Signer{myKey}, 0).ScanCompact(ctx.Outputs))
+}
+
+// benchScanNotes is the number of secret notes scanned per benchmark iteration.
+// 1% of them are for the wallet key.
+const benchScanNotes = 100_000
+
+var (
+	benchOnce        sync.Once
+	benchKey         signature.Signer
+	benchCommitments []types.NoteCommitment
+	benchSecretNotes []types.SecretNote
+)
+
+func setupBenchScan(b *testing.B) {
+	benchOnce.Do(func() {
+		var err error
+		benchKey, err = crypto.NewKey()
+		require.NoError(b, err)
+		otherKey, err := crypto.NewKey()
+		require.NoError(b, err)
+
+		for i := 0; i < benchScanNotes; i++ {
+			to := otherKey.Public()
+			if i%100 == 0 {
+				to = benchKey.Public()
+			}
+			cm, secretNote := newSecretNote(b, uint64(i), to)
+			benchCommitments = append(benchCommitments, cm)
+			benchSecretNotes = append(benchSecretNotes, secretNote)
+		}
+	})
+	b.ResetTimer()
+}
+
+// BenchmarkScan_SerialLoop is the trial decryption loop used before the Scanner:
+// one full authenticated decryption per secret note.
+func BenchmarkScan_SerialLoop(b *testing.B) {
+	setupBenchScan(b)
+	for i := 0; i < b.N; i++ {
+		found := 0
+		for j, sn := range benchSecretNotes {
+			if _, err := types.DecryptSharedNote(sn, benchCommitments[j], benchKey); err == nil {
+				found++
+			}
+		}
+		require.Equal(b, benchScanNotes/100, found)
+	}
+	b.ReportMetric(float64(benchScanNotes*b.N)/b.Elapsed().Seconds(), "notes/s")
+}
+
+// BenchmarkScan_SingleWorker shows the gain of the early rejection only.
+func BenchmarkScan_SingleWorker(b *testing.B) {
+	benchmarkScanner(b, 1)
+}
+
+func BenchmarkScan_AllCores(b *testing.B) {
+	benchmarkScanner(b, 0)
+}
+
+func benchmarkScanner(b *testing.B, workers int) {
+	setupBenchScan(b)
+	scanner := NewScanner([]signature.Signer{benchKey}, workers)
+	for i := 0; i < b.N; i++ {
+		require.Len(b, scanner.Scan(benchCommitments, benchSecretNotes), benchScanNotes/100)
+	}
+	b.ReportMetric(float64(benchScanNotes*b.N)/b.Elapsed().Seconds(), "notes/s")
+}
@@ -0,0 +1,391 @@
+package prover
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+
+	"github.com/consensys/gnark-crypto/signature"
+	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/constraint"
+	"github.com/holiman/uint256"
+	"github.com/kysee/zkp/zk-asset/types"
+)
+
+var ErrInsufficientBalance = errors.New("insufficient balance")
+
+// SelectionStrategy is how a wallet picks the notes to spend.
+type SelectionStrategy int
+
+const (
+	// LargestFirst picks the largest notes first. It makes the fewest txs.
+	LargestFirst SelectionStrategy = iota
+	// MinimizeChange picks one tx worth of notes whose total is closest to the amount,
+	// or falls back to LargestFirst if no such notes cover the amount.
+	MinimizeChange
+	// PrivacyPreserving picks notes at random, so the choice does not reveal the sizes or the order of the notes.
+	// It also fills the free inputs of the last tx with more random notes, which merges them for free.
+	PrivacyPreserving
+)
+
+func (s SelectionStrategy) String() string {
+	switch s {
+	case LargestFirst:
+		return "largest-first"
+	case MinimizeChange:
+		return "minimize-change"
+	case PrivacyPreserving:
+		return "privacy-preserving"
+	default:
+		return fmt.Sprintf("SelectionStrategy(%d)", int(s))
+	}
+}
+
+// maxMinimizeChangeNotes bounds the pairs searched by MinimizeChange.
+const maxMinimizeChangeNotes = 1024
+
+// numTxsFor returns the number of txs spending `n` notes.
+func numTxsFor(n int) int {
+	return (n + types.NumInputNotes - 1) / types.NumInputNotes
+}
+
+// neededFor returns the amount plus the fees of the txs spending `n` notes.
+func neededFor(n int, amount, fee *uint256.Int) *uint256.Int {
+	fees := new(uint256.Int).Mul(fee, uint256.NewInt(uint64(numTxsFor(n))))
+	return fees.Add(fees, amount)
+}
+
+func sumNotes(notes []*WalletNote) *uint256.Int {
+	ret := uint256.NewInt(0)
+	for _, n := range notes {
+		ret.Add(ret, n.Balance)
+	}
+	return ret
+}
+
+// SelectNotes picks the notes to send `amount` paying `fee` per tx with `strategy`.
+// The notes are spent `types.NumInputNotes` at a time in the returned order,
+// so the total of them covers `amount` and the fees of all those txs.
+func SelectNotes(notes []*WalletNote, amount, fee *uint256.Int, strategy SelectionStrategy) ([]*WalletNote, error) {
+	var candidates []*WalletNote
+	for _, n := range notes {
+		if !n.Spent && n.Position >= 0 && !n.Balance.IsZero() {
+			candidates = append(candidates, n)
+		}
+	}
+
+	switch strategy {
+	case LargestFirst:
+		sortNotesDesc(candidates)
+		return accumulateNotes(candidates, amount, fee)
+	case MinimizeChange:
+		if sel := selectMinChange(candidates, amount, fee); sel != nil {
+			return sel, nil
+		}
+		sortNotesDesc(candidates)
+		return accumulateNotes(candidates, amount, fee)
+	case PrivacyPreserving:
+		rand.Shuffle(len(candidates), func(i, j int) {
+			candidates[i], candidates[j] = candidates[j], candidates[i]
+		})
+		sel, err := accumulateNotes(candidates, amount, fee)
+		if err != nil {
+			return nil, err
+		}
+		for i := len(sel); i < len(candidates) && i%types.NumInputNotes != 0; i++ {
+			sel = append(sel, candidates[i])
+		}
+		return sel, nil
+	default:
+		return nil, fmt.Errorf("unknown selection strategy: %v", strategy)
+	}
+}
+
+func sortNotesDesc(notes []*WalletNote) {
+	sort.SliceStable(notes, func(i, j int) bool {
+		return notes[i].Balance.Gt(notes[j].Balance)
+	})
+}
+
+// accumulateNotes picks the notes in order until they cover the amount and the fees.
+func accumulateNotes(notes []*WalletNote, amount, fee *uint256.Int) ([]*WalletNote, error) {
+	total := uint256.NewInt(0)
+	for i, n := range notes {
+		total.Add(total, n.Balance)
+		if !total.Lt(neededFor(i+1, amount, fee)) {
+			return notes[:i+1], nil
+		}
+	}
+	return nil, ErrInsufficientBalance
+}
+
+// selectMinChange returns the notes of one tx which cover the amount and the fee with the least change.
+// It returns nil if there are no such notes.
+func selectMinChange(notes []*WalletNote, amount, fee *uint256.Int) []*WalletNote {
+	if len(notes) > maxMinimizeChangeNotes {
+		return nil
+	}
+	need := neededFor(1, amount, fee)
+
+	var best []*WalletNote
+	var bestTotal *uint256.Int
+	try := func(sel ...*WalletNote) {
+		total := sumNotes(sel)
+		if total.Lt(need) {
+			return
+		}
+		// prefer the less change, and then the fewer notes.
+		if best == nil || total.Lt(bestTotal) || (total.Eq(bestTotal) && len(sel) < len(best)) {
+			best, bestTotal = sel, total
+		}
+	}
+	for i, n0 := range notes {
+		try(n0)
+		for _, n1 := range notes[i+1:] {
+			try(n0, n1)
+		}
+	}
+	return best
+}
+
+// SetProvingKey sets the key used to prove the txs made by `Send` and `Consolidate` in the process.
+func (w *Wallet) SetProvingKey(provingKey plonk.ProvingKey, ccs constraint.ConstraintSystem) {
+	w.prover = &LocalProver{ProvingKey: provingKey, CCS: ccs}
+}
+
+// SetProver sets the prover of the txs made by `Send` and `Consolidate`, such as a `RemoteProver`.
+// The txs are still signed by the wallet.
+func (w *Wallet) SetProver(prover Prover) {
+	w.prover = prover
+}
+
+// SetSelectionStrategy sets how `Send` picks the notes. The default is `LargestFirst`.
+func (w *Wallet) SetSelectionStrategy(strategy SelectionStrategy) {
+	w.strategy = strategy
+}
+
+// Send sends `amount` to `toAddr` with `memo`, paying `fee` per tx.
+// If one tx cannot spend enough notes, it chains several txs each of which sends a part of `amount`,
+// and every note sent by them has the same `memo`.
+// The wallet is synced after the txs are submitted.
+// On error, it returns the txs submitted before the error.
+func (w *Wallet) Send(toAddr string, amount, fee *uint256.Int, memo []byte) ([]*types.ZKTx, error) {
+	if len(memo) > types.MemoSize {
+		return nil, types.ErrMemoTooLong
+	}
+	return w.sendChained(amount, fee, func(inputs []*WalletNote, amt *uint256.Int) (*types.ZKTx, error) {
+		return w.spend(inputs, toAddr, amt, fee, memo)
+	})
+}
+
+// Unshield sends `amount` to the transparent account `toAddr`, paying `fee` per tx.
+// Like `Send`, it chains several txs if one tx cannot spend enough notes.
+// On error, it returns the txs submitted before the error.
+func (w *Wallet) Unshield(toAddr string, amount, fee *uint256.Int) ([]*types.ZKTx, error) {
+	if _, err := types.TransparentAddr2Pub(toAddr); err != nil {
+		return nil, err
+	}
+	return w.sendChained(amount, fee, func(inputs []*WalletNote, amt *uint256.Int) (*types.ZKTx, error) {
+		builder, err := w.newSpendBuilder(inputs)
+		if err != nil {
+			return nil, err
+		}
+		if err := builder.AddTransparentOutput(toAddr, amt); err != nil {
+			return nil, err
+		}
+		builder.SetFee(fee)
+		return w.proveAndSubmit(builder, nil)
+	})
+}
+
+// Burn destroys `amount` of the notes, paying `fee` per tx.
+// Like `Send`, it chains several txs if one tx cannot spend enough notes.
+// On error, it returns the txs submitted before the error.
+func (w *Wallet) Burn(amount, fee *uint256.Int) ([]*types.ZKTx, error) {
+	return w.sendChained(amount, fee, func(inputs []*WalletNote, amt *uint256.Int) (*types.ZKTx, error) {
+		builder, err := w.newSpendBuilder(inputs)
+		if err != nil {
+			return nil, err
+		}
+		if err := builder.AddBurn(amt); err != nil {
+			return nil, err
+		}
+		builder.SetFee(fee)
+		return w.proveAndSubmit(builder, nil)
+	})
+}
+
+// Shield moves `amount` from the transparent account of `key` into a new note of the wallet.
+// The account pays `amount` and `fee`.
+// The wallet is synced after the tx is submitted.
+func (w *Wallet) Shield(key signature.Signer, amount, fee *uint256.Int) (*types.ZKTx, error) {
+	info, err := w.chain.GetMerkleRoot()
+	if err != nil {
+		return nil, err
+	}
+	builder := NewTxBuilder(w.pak)
+	// no note is spent, but the root is still a public input of the proof.
+	builder.SetAnchor(info.Root, info.Depth)
+	if err := builder.AddTransparentInput(types.Pub2TransparentAddr(key.Public()), new(uint256.Int).Add(amount, fee)); err != nil {
+		return nil, err
+	}
+	if err := builder.AddOutput(w.Address, amount, nil); err != nil {
+		return nil, err
+	}
+	builder.SetFee(fee)
+	zktx, err := w.proveAndSubmit(builder, key)
+	if err != nil {
+		return nil, err
+	}
+	_, err = w.SyncSharedNotes()
+	return zktx, err
+}
+
+// sendChained selects the notes covering `amount` and `fee` per tx, and submits the txs made by `send`,
+// each of which spends up to `types.NumInputNotes` notes and sends `amt`, a part of `amount`.
+// The wallet is synced after the txs are submitted.
+func (w *Wallet) sendChained(amount, fee *uint256.Int, send func(inputs []*WalletNote, amt *uint256.Int) (*types.ZKTx, error)) ([]*types.ZKTx, error) {
+	if _, err := w.SyncSharedNotes(); err != nil {
+		return nil, err
+	}
+	notes, err := SelectNotes(w.notes, amount, fee, w.strategy)
+	if err != nil {
+		return nil, err
+	}
+
+	var txs []*types.ZKTx
+	remaining := amount.Clone()
+	for i := 0; i < len(notes); i += types.NumInputNotes {
+		inputs := notes[i:min(i+types.NumInputNotes, len(notes))]
+		total := sumNotes(inputs)
+		if total.Lt(fee) {
+			return txs, fmt.Errorf("%w: the notes do not cover the fee", ErrInsufficientBalance)
+		}
+		amt := new(uint256.Int).Sub(total, fee)
+		if amt.Gt(remaining) {
+			amt = remaining.Clone()
+		}
+		remaining.Sub(remaining, amt)
+
+		zktx, err := send(inputs, amt)
+		if err != nil {
+			return txs, err
+		}
+		txs = append(txs, zktx)
+	}
+
+	_, err = w.SyncSharedNotes()
+	return txs, err
+}
+
+// Consolidate merges the unspent notes by spending them in pairs to the wallet itself, paying `fee` per tx.
+// Only the notes whose balance is less than `dust` are merged; if `dust` is nil, all notes are merged into one.
+// A pair whose total does not exceed `fee` is left as it is.
+// On error, it returns the txs submitted before the error.
+func (w *Wallet) Consolidate(fee, dust *uint256.Int) ([]*types.ZKTx, error) {
+	var txs []*types.ZKTx
+	for {
+		if _, err := w.SyncSharedNotes(); err != nil {
+			return txs, err
+		}
+
+		var candidates []*WalletNote
+		for _, n := range w.notes {
+			if !n.Spent && n.Position >= 0 && (dust == nil || n.Balance.Lt(dust)) {
+				candidates = append(candidates, n)
+			}
+		}
+		// the smallest notes first
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].Balance.Lt(candidates[j].Balance)
+		})
+
+		merged := 0
+		for i := 0; i+types.NumInputNotes <= len(candidates); i += types.NumInputNotes {
+			inputs := candidates[i : i+types.NumInputNotes]
+			total := sumNotes(inputs)
+			if !total.Gt(fee) {
+				continue
+			}
+			zktx, err := w.spend(inputs, w.Address, total.Sub(total, fee), fee, nil)
+			if err != nil {
+				return txs, err
+			}
+			txs = append(txs, zktx)
+			merged++
+		}
+		if merged == 0 {
+			return txs, nil
+		}
+	}
+}
+
+// spend proves and submits the tx spending `notes`.
+// The rest of the notes after `amt` and `fee` goes back to the wallet as the change.
+func (w *Wallet) spend(notes []*WalletNote, toAddr string, amt, fee *uint256.Int, memo []byte) (*types.ZKTx, error) {
+	builder, err := w.newSpendBuilder(notes)
+	if err != nil {
+		return nil, err
+	}
+	if err := builder.AddOutput(toAddr, amt, memo); err != nil {
+		return nil, err
+	}
+	builder.SetFee(fee)
+	return w.proveAndSubmit(builder, nil)
+}
+
+// newSpendBuilder returns the builder of a tx spending `notes`.
+func (w *Wallet) newSpendBuilder(notes []*WalletNote) (*TxBuilder, error) {
+	var _notes []*types.Note
+	for _, n := range notes {
+		_notes = append(_notes, n.ToNoteOf(w.pubKey))
+	}
+	inputs, root, depth, err := GetNoteInputs(w.chain, _notes)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := NewTxBuilder(w.pak)
+	for _, in := range inputs {
+		if err := builder.AddInput(in); err != nil {
+			return nil, err
+		}
+	}
+	builder.SetAnchor(root, depth)
+	return builder, nil
+}
+
+// proveAndSubmit builds, proves, signs and submits the tx of `builder`.
+// `transparentKey` signs the tx if it has a transparent input.
+func (w *Wallet) proveAndSubmit(builder *TxBuilder, transparentKey signature.Signer) (*types.ZKTx, error) {
+	if w.prover == nil {
+		return nil, errors.New("the prover is not set")
+	}
+	b, ok := w.chain.(Broadcaster)
+	if !ok {
+		return nil, errors.New("the chain of the wallet can not submit txs")
+	}
+
+	utx, err := builder.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	// only the witness goes to the prover; the spending key stays in the wallet.
+	if utx.Tx.ProofBytes, err = w.prover.Prove(utx.Witness); err != nil {
+		return nil, err
+	}
+	if err := SignZKTx(utx.Tx, w.PrivateKey, utx.Witness.Alpha); err != nil {
+		return nil, err
+	}
+	if transparentKey != nil {
+		if err := SignTransparent(utx.Tx, transparentKey); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := b.SubmitZKTx(utx.Tx); err != nil {
+		return nil, err
+	}
+	return utx.Tx, nil
+}
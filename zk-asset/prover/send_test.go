@@ -0,0 +1,79 @@
+package prover
+
+import (
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/kysee/zkp/zk-asset/types"
+	"github.com/stretchr/testify/require"
+)
+
+func newSelectTestNotes(balances ...uint64) []*WalletNote {
+	var notes []*WalletNote
+	for i, b := range balances {
+		notes = append(notes, &WalletNote{
+			SharedNote: &types.SharedNote{Version: types.NoteVersion, Balance: uint256.NewInt(b)},
+			Position:   i,
+		})
+	}
+	return notes
+}
+
+func balancesOf(notes []*WalletNote) []uint64 {
+	var ret []uint64
+	for _, n := range notes {
+		ret = append(ret, n.Balance.Uint64())
+	}
+	return ret
+}
+
+func TestSelectNotes(t *testing.T) {
+	notes := newSelectTestNotes(5, 40, 12, 30, 1, 8)
+	fee := uint256.NewInt(1)
+
+	// the spent notes, the notes not in the chain and the empty notes are not selected.
+	notes = append(notes, newSelectTestNotes(1000, 1000, 0)...)
+	notes[6].Spent = true
+	notes[7].Position = -1
+
+	sel, err := SelectNotes(notes, uint256.NewInt(20), fee, LargestFirst)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{40}, balancesOf(sel))
+
+	// 40+30 covers 60+1, but 40+30+12 does not cover 80+2.
+	sel, err = SelectNotes(notes, uint256.NewInt(80), fee, LargestFirst)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{40, 30, 12}, balancesOf(sel))
+
+	// 12+8 is closest to 19+1.
+	sel, err = SelectNotes(notes, uint256.NewInt(19), fee, MinimizeChange)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{12, 8}, balancesOf(sel))
+
+	// a single note is preferred for the same change.
+	sel, err = SelectNotes(notes, uint256.NewInt(11), fee, MinimizeChange)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{12}, balancesOf(sel))
+
+	// no pair covers 75+1, so it falls back to LargestFirst.
+	sel, err = SelectNotes(notes, uint256.NewInt(75), fee, MinimizeChange)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{40, 30, 12}, balancesOf(sel))
+
+	for i := 0; i < 20; i++ {
+		sel, err = SelectNotes(notes, uint256.NewInt(20), fee, PrivacyPreserving)
+		require.NoError(t, err)
+		// the inputs of the last tx are filled.
+		require.Zero(t, len(sel)%types.NumInputNotes)
+		require.False(t, sumNotes(sel).Lt(neededFor(len(sel), uint256.NewInt(20), fee)))
+	}
+
+	// 96 in total, and the fees of 3 txs are needed to spend all.
+	sel, err = SelectNotes(notes, uint256.NewInt(93), fee, LargestFirst)
+	require.NoError(t, err)
+	require.Len(t, sel, 6)
+	_, err = SelectNotes(notes, uint256.NewInt(94), fee, LargestFirst)
+	require.ErrorIs(t, err, ErrInsufficientBalance)
+	_, err = SelectNotes(notes, uint256.NewInt(94), fee, PrivacyPreserving)
+	require.ErrorIs(t, err, ErrInsufficientBalance)
+}
@@ -0,0 +1,218 @@
+package prover
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/constraint"
+	"github.com/kysee/zkp/zk-asset/types"
+)
+
+// Prover proves the witness of a tx.
+type Prover interface {
+	Prove(wtn *types.ZKWitness) ([]byte, error)
+}
+
+// LocalProver proves in the process with the proving key.
+type LocalProver struct {
+	ProvingKey plonk.ProvingKey
+	CCS        constraint.ConstraintSystem
+}
+
+func (p *LocalProver) Prove(wtn *types.ZKWitness) ([]byte, error) {
+	return Prove(wtn, p.ProvingKey, p.CCS)
+}
+
+var (
+	ErrProvingQueueFull     = errors.New("the proving queue is full")
+	ErrProvingServiceClosed = errors.New("the proving service is closed")
+)
+
+// DefaultProvingQueueSize is the number of witnesses waiting for the workers of a `ProvingService`.
+const DefaultProvingQueueSize = 64
+
+// maxWitnessSize limits the size of a witness sent to a `ProvingService`.
+const maxWitnessSize = 1 << 20
+
+// ProvingService proves the witnesses sent by the clients, such as light wallets, which can not prove by themselves.
+// A witness has no spending key, so the service can not spend the notes; the clients sign their txs by themselves.
+//
+//	POST /prove    prove a `types.ZKWitness` and return `types.ProveResult`
+type ProvingService struct {
+	prover Prover
+	queue  chan *provingJob
+
+	mtx    sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+type provingJob struct {
+	ctx   context.Context
+	wtn   *types.ZKWitness
+	proof []byte
+	err   error
+	done  chan struct{}
+}
+
+// NewProvingService starts `workers` goroutines proving the queued witnesses.
+// If `workers` is not positive, the number of CPUs is used.
+// If `queueSize` is not positive, `DefaultProvingQueueSize` is used.
+// The witness sent when the queue is full is rejected with `ErrProvingQueueFull`.
+func NewProvingService(provingKey plonk.ProvingKey, ccs constraint.ConstraintSystem, workers, queueSize int) *ProvingService {
+	return newProvingService(&LocalProver{ProvingKey: provingKey, CCS: ccs}, workers, queueSize)
+}
+
+func newProvingService(prover Prover, workers, queueSize int) *ProvingService {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if queueSize <= 0 {
+		queueSize = DefaultProvingQueueSize
+	}
+	s := &ProvingService{
+		prover: prover,
+		queue:  make(chan *provingJob, queueSize),
+	}
+	for i := 0; i < workers; i++ {
+		s.wg.Add(1)
+		go s.work()
+	}
+	return s
+}
+
+func (s *ProvingService) work() {
+	defer s.wg.Done()
+	for job := range s.queue {
+		// the client has gone while the job was queued.
+		if err := job.ctx.Err(); err != nil {
+			job.err = err
+		} else {
+			job.proof, job.err = s.prover.Prove(job.wtn)
+		}
+		close(job.done)
+	}
+}
+
+// Prove queues `wtn` and waits for its proof.
+// It returns when the proof is made or `ctx` is done.
+func (s *ProvingService) Prove(ctx context.Context, wtn *types.ZKWitness) ([]byte, error) {
+	// the assignment is checked before queued, so a wrong witness does not wait for a worker.
+	if _, err := wtn.Assignment(); err != nil {
+		return nil, err
+	}
+
+	job := &provingJob{ctx: ctx, wtn: wtn, done: make(chan struct{})}
+	if err := s.enqueue(job); err != nil {
+		return nil, err
+	}
+	select {
+	case <-job.done:
+		return job.proof, job.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *ProvingService) enqueue(job *provingJob) error {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	if s.closed {
+		return ErrProvingServiceClosed
+	}
+	select {
+	case s.queue <- job:
+		return nil
+	default:
+		return ErrProvingQueueFull
+	}
+}
+
+// Close stops accepting witnesses and waits for the workers to finish the queued ones.
+func (s *ProvingService) Close() {
+	s.mtx.Lock()
+	if s.closed {
+		s.mtx.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.queue)
+	s.mtx.Unlock()
+
+	s.wg.Wait()
+}
+
+// Handler returns the HTTP+JSON service of the prover.
+func (s *ProvingService) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /prove", s.handleProve)
+	return mux
+}
+
+// ListenAndServe serves the service on `addr` until it fails.
+// `addr` should be a local or private one, since the witnesses reveal the notes of the clients.
+func (s *ProvingService) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, s.Handler())
+}
+
+func (s *ProvingService) handleProve(w http.ResponseWriter, r *http.Request) {
+	wtn := &types.ZKWitness{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWitnessSize)).Decode(wtn); err != nil {
+		writeServiceError(w, http.StatusBadRequest, err)
+		return
+	}
+	if _, err := wtn.Assignment(); err != nil {
+		writeServiceError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	proof, err := s.Prove(r.Context(), wtn)
+	switch {
+	case errors.Is(err, ErrProvingQueueFull), errors.Is(err, ErrProvingServiceClosed):
+		writeServiceError(w, http.StatusServiceUnavailable, err)
+	case err != nil:
+		// the witness does not satisfy the circuit.
+		writeServiceError(w, http.StatusUnprocessableEntity, err)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(&types.ProveResult{Proof: proof})
+	}
+}
+
+func writeServiceError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(&types.RPCError{Error: err.Error()})
+}
+
+// remoteProveTimeout bounds the time waiting for a `ProvingService`, including the time in its queue.
+const remoteProveTimeout = 10 * time.Minute
+
+// RemoteProver sends the witnesses to a `ProvingService`.
+type RemoteProver struct {
+	rpc *RPCClient
+}
+
+func NewRemoteProver(baseURL string) *RemoteProver {
+	return &RemoteProver{
+		rpc: &RPCClient{
+			baseURL: strings.TrimRight(baseURL, "/"),
+			hc:      &http.Client{Timeout: remoteProveTimeout},
+		},
+	}
+}
+
+func (p *RemoteProver) Prove(wtn *types.ZKWitness) ([]byte, error) {
+	ret := &types.ProveResult{}
+	if err := p.rpc.call(http.MethodPost, "/prove", nil, wtn, ret); err != nil {
+		return nil, err
+	}
+	return ret.Proof, nil
+}
@@ -0,0 +1,109 @@
+package prover
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/holiman/uint256"
+	"github.com/kysee/zkp/zk-asset/crypto"
+	"github.com/kysee/zkp/zk-asset/types"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingProver returns the nullifier of the first input as the proof after `release` is closed.
+type blockingProver struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p *blockingProver) Prove(wtn *types.ZKWitness) ([]byte, error) {
+	p.started <- struct{}{}
+	<-p.release
+	return wtn.Nullifiers[0], nil
+}
+
+// newTestWitness builds a witness spending a note which is not in any tree.
+// It can not be proven, but it is enough for the service to queue.
+func newTestWitness(t *testing.T) *types.ZKWitness {
+	ask, err := crypto.NewKey()
+	require.NoError(t, err)
+	pak := types.NewProofAuthorizingKey(ask)
+
+	note := &types.Note{
+		Version: types.NoteVersion,
+		PubKey:  pak.PublicKey(),
+		Balance: uint256.NewInt(10),
+		Salt:    types.RandBytes(32),
+	}
+	b := NewTxBuilder(pak)
+	require.NoError(t, b.AddInput(&NoteInput{Note: note, ProofPath: [][]byte{note.Commitment()}, NumLeaves: 1}))
+	b.SetAnchor(note.Commitment(), 32)
+	require.NoError(t, b.AddOutput(types.Pub2Addr(pak.PublicKey()), uint256.NewInt(3), nil))
+	utx, err := b.Build()
+	require.NoError(t, err)
+	return utx.Witness
+}
+
+func TestProvingService_Queue(t *testing.T) {
+	p := &blockingProver{started: make(chan struct{}, 3), release: make(chan struct{})}
+	s := newProvingService(p, 1, 1)
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+	client := NewRemoteProver(srv.URL)
+
+	type result struct {
+		proof []byte
+		err   error
+	}
+	wtns := []*types.ZKWitness{newTestWitness(t), newTestWitness(t)}
+	results := make(chan result, len(wtns))
+
+	// the first witness occupies the worker, and the second one waits in the queue.
+	go func() {
+		proof, err := client.Prove(wtns[0])
+		results <- result{proof, err}
+	}()
+	<-p.started
+	go func() {
+		proof, err := client.Prove(wtns[1])
+		results <- result{proof, err}
+	}()
+	require.Eventually(t, func() bool { return len(s.queue) == 1 }, 5*time.Second, time.Millisecond)
+
+	// the queue is full.
+	_, err := client.Prove(newTestWitness(t))
+	require.ErrorContains(t, err, ErrProvingQueueFull.Error())
+
+	close(p.release)
+	var proofs [][]byte
+	for range wtns {
+		r := <-results
+		require.NoError(t, r.err)
+		proofs = append(proofs, r.proof)
+	}
+	require.ElementsMatch(t, [][]byte{wtns[0].Nullifiers[0], wtns[1].Nullifiers[0]}, proofs)
+
+	s.Close()
+	_, err = client.Prove(wtns[0])
+	require.ErrorContains(t, err, ErrProvingServiceClosed.Error())
+}
+
+func TestProvingService_WrongWitness(t *testing.T) {
+	s := newProvingService(&blockingProver{}, 1, 1)
+	defer s.Close()
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/prove", "application/json", bytes.NewBufferString("{bad json"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	wtn := newTestWitness(t)
+	wtn.Inputs = wtn.Inputs[:1]
+	_, err = NewRemoteProver(srv.URL).Prove(wtn)
+	require.ErrorContains(t, err, "status(400)")
+}
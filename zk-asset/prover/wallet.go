@@ -2,119 +2,376 @@ package prover
 
 import (
 	"bytes"
+	"encoding/hex"
+	"errors"
 	"fmt"
 
 	"github.com/consensys/gnark-crypto/signature"
 	"github.com/holiman/uint256"
 	"github.com/kysee/zkp/zk-asset/crypto"
 	"github.com/kysee/zkp/zk-asset/types"
-	"github.com/kysee/zkp/zk-asset/verifier"
 )
 
 type Wallet struct {
-	Address     string
-	PrivateKey  signature.Signer
-	sharedNotes []*types.SharedNote
+	Address    string
+	PrivateKey signature.Signer
+	chain      ChainSource
+
+	// derived from `PrivateKey`; see `types.ProofAuthorizingKey`.
+	pak    *types.ProofAuthorizingKey
+	ivk    signature.Signer
+	pubKey signature.PublicKey
+
+	// notes has all notes found by the wallet, including spent ones.
+	notes []*WalletNote
+	// unspent maps the hex encoded nullifier to the unspent note.
+	unspent    map[string]*WalletNote
+	checkpoint Checkpoint
+	history    []*TxRecord
+
+	// used by `Send` and `Consolidate`
+	prover   Prover
+	strategy SelectionStrategy
 }
 
-var (
-	Wallets []*Wallet
-)
+// WalletNote is a note found by the wallet.
+type WalletNote struct {
+	*types.SharedNote
+	Commitment types.NoteCommitment `json:"commitment"`
+	Nullifier  types.NoteNullifier  `json:"nullifier"`
 
-func init() {
-	for i := 0; i < 10; i++ {
-		w := NewWallet()
-		Wallets = append(Wallets, w)
+	// Position is the leaf index of the note in the note commitment tree.
+	// It is -1 if the note was not found in the ledger.
+	Position int `json:"position"`
+	// TxIdx is the index of the tx which created the note. It is -1 if unknown.
+	TxIdx int  `json:"txIdx"`
+	Spent bool `json:"spent"`
+}
 
-		verifier.InitMint(w.Address, uint256.NewInt(100))
-	}
+// Checkpoint is the point in the ledger up to which the wallet has scanned.
+type Checkpoint struct {
+	// TxIdx is the index of the next tx to scan.
+	TxIdx int `json:"txIdx"`
+	// CommitmentIdx is the index of the next note commitment; the position of the next new note.
+	CommitmentIdx int `json:"commitmentIdx"`
+}
 
-	for _, w := range Wallets {
-		_ = w.SyncSharedNotes()
-		b := w.GetBalance()
-		fmt.Printf("prover=%s, balance=%s\n", w.Address, b.Dec())
-	}
+// TxRecordKind tells how a tx changed the balance of a wallet.
+type TxRecordKind string
+
+const (
+	TxReceived TxRecordKind = "received"
+	TxSpent    TxRecordKind = "spent"
+)
+
+// TxRecord is an entry of the transaction history of a wallet.
+// A tx which spends a note and creates a change note is recorded as two entries.
+type TxRecord struct {
+	TxIdx      int                  `json:"txIdx"`
+	Kind       TxRecordKind         `json:"kind"`
+	Amount     *uint256.Int         `json:"amount"`
+	Commitment types.NoteCommitment `json:"commitment"`
+	// Memo is the memo of the received note. It is nil until the memo is fetched.
+	Memo []byte `json:"memo,omitempty"`
 }
 
-func NewWallet() *Wallet {
+// WalletState is what a wallet has to keep to resume the sync after restart.
+type WalletState struct {
+	Checkpoint Checkpoint    `json:"checkpoint"`
+	Notes      []*WalletNote `json:"notes"`
+	History    []*TxRecord   `json:"history"`
+}
+
+// NewWallet creates a wallet with a new key.
+// The wallet finds its notes in `chain`.
+func NewWallet(chain ChainSource) *Wallet {
 	prvk, _ := crypto.NewKey()
+	return NewWalletWithKey(prvk, chain)
+}
+
+// NewWalletWithKey creates a wallet of the existing key.
+func NewWalletWithKey(prvk signature.Signer, chain ChainSource) *Wallet {
+	pak := types.NewProofAuthorizingKey(prvk)
+	ivk := pak.IncomingViewingKey()
 	return &Wallet{
-		Address:    types.Pub2Addr(prvk.Public()),
+		Address:    types.Pub2Addr(ivk.Public()),
 		PrivateKey: prvk,
+		chain:      chain,
+		pak:        pak,
+		ivk:        ivk,
+		pubKey:     ivk.Public(),
+		unspent:    make(map[string]*WalletNote),
 	}
 }
 
+// PublicKey returns the public key of the address, which owns the notes of the wallet.
+func (w *Wallet) PublicKey() signature.PublicKey {
+	return w.pubKey
+}
+
+// ProofAuthorizingKey returns the key with which a prover proves the spending of the notes of the wallet.
+func (w *Wallet) ProofAuthorizingKey() *types.ProofAuthorizingKey {
+	return w.pak
+}
+
+func (w *Wallet) Chain() ChainSource {
+	return w.chain
+}
+
+// AddSharedNote adds a note which is not found in the ledger.
 func (w *Wallet) AddSharedNote(note *types.SharedNote) {
-	w.sharedNotes = append(w.sharedNotes, note)
+	w.addNote(note, -1, -1)
 }
 
+func (w *Wallet) addNote(note *types.SharedNote, pos, txIdx int) *WalletNote {
+	_note := note.ToNoteOf(w.pubKey)
+	wn := &WalletNote{
+		SharedNote: note,
+		Commitment: _note.Commitment(),
+		Nullifier:  _note.Nullifier(w.pak.Nk),
+		Position:   pos,
+		TxIdx:      txIdx,
+	}
+	w.notes = append(w.notes, wn)
+	w.unspent[hex.EncodeToString(wn.Nullifier)] = wn
+	return wn
+}
+
+// GetSharedNote returns the `idx`-th unspent note.
 func (w *Wallet) GetSharedNote(idx int) *types.SharedNote {
-	if idx < len(w.sharedNotes) {
-		return w.sharedNotes[idx]
+	notes := w.UnspentNotes()
+	if idx < len(notes) {
+		return notes[idx].SharedNote
 	}
 	return nil
 }
 
+// GetSharedNotesCount returns the number of unspent notes.
 func (w *Wallet) GetSharedNotesCount() int {
-	return len(w.sharedNotes)
+	return len(w.unspent)
+}
+
+// UnspentNotes returns the unspent notes in the order they were found.
+func (w *Wallet) UnspentNotes() []*WalletNote {
+	var ret []*WalletNote
+	for _, n := range w.notes {
+		if !n.Spent {
+			ret = append(ret, n)
+		}
+	}
+	return ret
+}
+
+// Checkpoint returns the point in the ledger up to which the wallet has scanned.
+func (w *Wallet) Checkpoint() Checkpoint {
+	return w.checkpoint
 }
 
-func (w *Wallet) SyncSharedNotes() int {
-	w.ClearSharedNotes()
+// SyncSharedNotes scans the compact blocks added to the chain since the last sync.
+// It finds new notes and marks the found notes as spent when their nullifiers appear.
+// The memos of the new notes are not fetched; see `FetchMemo`.
+// It returns the number of unspent notes.
+func (w *Wallet) SyncSharedNotes() (int, error) {
+	err := SyncWallets([]*Wallet{w})
+	return w.GetSharedNotesCount(), err
+}
 
-	// find my shared notes
-	for i := 0; ; i++ {
-		tx := verifier.GetZKTx(i)
-		if tx == nil {
-			break
+// SyncWallets syncs several wallets in one pass over the chain of the first wallet,
+// trial-decrypting each compact block with the keys of all wallets at once.
+// The wallets should have the same checkpoint.
+func SyncWallets(ws []*Wallet) error {
+	if len(ws) == 0 {
+		return nil
+	}
+	keys := make([]signature.Signer, len(ws))
+	for i, w := range ws {
+		if w.checkpoint != ws[0].checkpoint {
+			return fmt.Errorf("the wallets have different checkpoints: %v, %v", ws[0].checkpoint, w.checkpoint)
 		}
+		keys[i] = w.ivk
+	}
+	scanner := NewScanner(keys, 0)
 
-		for i, sn := range tx.NewSecretNotes {
-			if len(sn) == 0 {
-				continue
-			}
-			_sharedNote, err := types.DecryptSharedNote(sn, nil, w.PrivateKey)
-			if err != nil {
-				continue
+	for {
+		from := ws[0].checkpoint.TxIdx
+		blk, err := ws[0].chain.GetCompactBlock(from, from+chainBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(blk.Txs) == 0 {
+			return nil
+		}
+
+		// trial-decrypt the outputs of all txs at once.
+		var outputs []*types.CompactOutput
+		for _, tx := range blk.Txs {
+			outputs = append(outputs, tx.Outputs...)
+		}
+		decrypted := make([]map[int]*types.SharedNote, len(ws))
+		for i := range ws {
+			decrypted[i] = make(map[int]*types.SharedNote)
+		}
+		for _, r := range scanner.ScanCompact(outputs) {
+			decrypted[r.KeyIdx][r.NoteIdx] = r.SharedNote
+		}
+
+		for i, w := range ws {
+			if err := w.scanCompactBlock(blk, decrypted[i]); err != nil {
+				return err
 			}
-			_note := _sharedNote.ToNoteOf(w.PrivateKey.Public())
+		}
+	}
+}
+
+// scanCompactBlock applies the txs of the block to the wallet and advances the checkpoint past them.
+// `decrypted` maps the index of an output in the block to the note decrypted from it.
+func (w *Wallet) scanCompactBlock(blk *types.CompactBlock, decrypted map[int]*types.SharedNote) error {
+	if blk.FromTxIdx != w.checkpoint.TxIdx || blk.FromCommitmentIdx != w.checkpoint.CommitmentIdx {
+		return fmt.Errorf("the compact block does not continue from the checkpoint: block(%d, %d), checkpoint(%d, %d)",
+			blk.FromTxIdx, blk.FromCommitmentIdx, w.checkpoint.TxIdx, w.checkpoint.CommitmentIdx)
+	}
 
-			// 1. _ncmt == tx.NewNoteCommitments[i]
-			_ncmt := _note.Commitment()
-			if !bytes.Equal(_ncmt, tx.NewNoteCommitments[i]) {
-				fmt.Printf("wrong secret note: not same as tx note commitment. expected(%x), got(%x)\n", tx.NewNoteCommitments[i], _ncmt)
-				continue
+	outIdx := 0
+	for i, tx := range blk.Txs {
+		if tx.TxIdx != blk.FromTxIdx+i {
+			return fmt.Errorf("wrong tx index in the compact block: expected(%d), got(%d)", blk.FromTxIdx+i, tx.TxIdx)
+		}
+
+		// 1. the notes having the nullifiers are spent.
+		for _, nf := range tx.Nullifiers {
+			if wn, ok := w.unspent[hex.EncodeToString(nf)]; ok {
+				wn.Spent = true
+				delete(w.unspent, hex.EncodeToString(nf))
+				w.history = append(w.history, &TxRecord{
+					TxIdx:      tx.TxIdx,
+					Kind:       TxSpent,
+					Amount:     wn.Balance,
+					Commitment: wn.Commitment,
+				})
 			}
+		}
 
-			// 2. check the note is used or not (the nullifier exists or not)
-			nullifier := _note.Nullifier(w.getPrvScalar())
-			if verifier.FindNoteNullifier(nullifier) != nil {
-				// already spent
-				fmt.Printf("note already spent: %x\n", nullifier)
-				continue
+		// 2. find my new notes.
+		// `DecryptCompactOutput` has already checked the decrypted note against the commitment.
+		for range tx.Outputs {
+			pos := w.checkpoint.CommitmentIdx
+			w.checkpoint.CommitmentIdx++
+
+			if _sharedNote, ok := decrypted[outIdx]; ok {
+				wn := w.addNote(_sharedNote, pos, tx.TxIdx)
+				w.history = append(w.history, &TxRecord{
+					TxIdx:      tx.TxIdx,
+					Kind:       TxReceived,
+					Amount:     wn.Balance,
+					Commitment: wn.Commitment,
+				})
 			}
+			outIdx++
+		}
+		w.checkpoint.TxIdx = tx.TxIdx + 1
+	}
+	return nil
+}
+
+// FetchMemo fetches the full secret note of `wn` from the chain and fills the memo of `wn`.
+// A note found in a compact block has no memo until it is fetched.
+func (w *Wallet) FetchMemo(wn *WalletNote) ([]byte, error) {
+	if wn.Memo != nil {
+		return wn.Memo, nil
+	}
+	if wn.Position < 0 {
+		return nil, errors.New("the note is not in the chain")
+	}
+
+	sns, err := w.chain.GetSecretNotes(wn.Position, wn.Position+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(sns) != 1 {
+		return nil, fmt.Errorf("secret note not found: %d", wn.Position)
+	}
+	_sharedNote, err := types.DecryptSharedNote(sns[0], wn.Commitment, w.ivk)
+	if err != nil {
+		return nil, err
+	}
 
-			// success
-			w.AddSharedNote(_sharedNote)
+	wn.Memo = _sharedNote.Memo
+	if wn.Memo == nil {
+		wn.Memo = []byte{}
+	}
+	for _, r := range w.history {
+		if r.Kind == TxReceived && bytes.Equal(r.Commitment, wn.Commitment) {
+			r.Memo = wn.Memo
 		}
+	}
+	return wn.Memo, nil
+}
 
+// FetchMemos fetches the memos of all notes found in the chain whose memos are not fetched yet,
+// so the history has the memos of the received notes.
+func (w *Wallet) FetchMemos() error {
+	for _, wn := range w.notes {
+		if wn.Memo != nil || wn.Position < 0 {
+			continue
+		}
+		if _, err := w.FetchMemo(wn); err != nil {
+			return err
+		}
 	}
-	return w.GetSharedNotesCount()
+	return nil
 }
 
+// ClearSharedNotes forgets all notes and rewinds the checkpoint,
+// so the next sync rescans the chain from the beginning.
 func (w *Wallet) ClearSharedNotes() {
-	w.sharedNotes = make([]*types.SharedNote, 0)
+	w.notes = nil
+	w.unspent = make(map[string]*WalletNote)
+	w.checkpoint = Checkpoint{}
+	w.history = nil
+}
+
+// History returns the transaction history found by the sync, oldest first.
+func (w *Wallet) History() []*TxRecord {
+	return append([]*TxRecord(nil), w.history...)
+}
+
+// State returns the state to be persisted for resuming the sync later.
+func (w *Wallet) State() *WalletState {
+	return &WalletState{
+		Checkpoint: w.checkpoint,
+		Notes:      w.notes,
+		History:    w.history,
+	}
+}
+
+// RestoreState replaces the notes and the checkpoint of the wallet with `st`.
+// The next sync continues from `st.Checkpoint`.
+func (w *Wallet) RestoreState(st *WalletState) error {
+	unspent := make(map[string]*WalletNote)
+	for _, wn := range st.Notes {
+		if wn.SharedNote == nil {
+			return fmt.Errorf("wrong wallet state: empty note")
+		}
+		_note := wn.ToNoteOf(w.pubKey)
+		if !bytes.Equal(_note.Commitment(), wn.Commitment) {
+			return fmt.Errorf("wrong wallet state: the note is not mine: %x", wn.Commitment)
+		}
+		wn.Nullifier = _note.Nullifier(w.pak.Nk)
+		if !wn.Spent {
+			unspent[hex.EncodeToString(wn.Nullifier)] = wn
+		}
+	}
+	w.notes = st.Notes
+	w.unspent = unspent
+	w.checkpoint = st.Checkpoint
+	w.history = st.History
+	return nil
 }
 
 func (w *Wallet) GetBalance() *uint256.Int {
 	ret := uint256.NewInt(0)
-	for _, n := range w.sharedNotes {
+	for _, n := range w.unspent {
 		ret = ret.Add(ret, n.Balance)
 	}
 	return ret
 }
-
-func (w *Wallet) getPrvScalar() ([]byte, []byte) {
-	s := w.PrivateKey.Bytes()[32:64]
-	return s[:16], s[16:32]
-}
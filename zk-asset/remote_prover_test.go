@@ -0,0 +1,37 @@
+package zk_asset
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/kysee/zkp/zk-asset/prover"
+	"github.com/kysee/zkp/zk-asset/verifier"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteProver(t *testing.T) {
+	svc := prover.NewProvingService(prKey, css, 2, 0)
+	defer svc.Close()
+	srv := httptest.NewServer(svc.Handler())
+	defer srv.Close()
+
+	// the wallet has no proving key; it sends the witnesses to the service and signs the txs by itself.
+	sender := newFundedWallet(t, 50)
+	sender.SetProver(prover.NewRemoteProver(srv.URL))
+	receiver := prover.NewWallet(verifier.NewLocalChain())
+	amt, fee := uint256.NewInt(20), uint256.NewInt(1)
+
+	memo := []byte("invoice #42")
+
+	txs, err := sender.Send(receiver.Address, amt, fee, memo)
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	require.EqualValues(t, 50-20-1, sender.GetBalance().Uint64())
+
+	_, err = receiver.SyncSharedNotes()
+	require.NoError(t, err)
+	require.Equal(t, amt, receiver.GetBalance())
+	require.NoError(t, receiver.FetchMemos())
+	require.Equal(t, memo, receiver.History()[0].Memo)
+}
@@ -0,0 +1,70 @@
+package zk_asset
+
+import (
+	"testing"
+
+	"github.com/kysee/zkp/zk-asset/crypto"
+	"github.com/kysee/zkp/zk-asset/prover"
+	"github.com/kysee/zkp/zk-asset/verifier"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreWallets_FromMnemonic(t *testing.T) {
+	mnemonic, err := crypto.NewMnemonic()
+	require.NoError(t, err)
+
+	// the accounts 0, 3 and 7 receive notes.
+	funded := map[uint32]uint64{0: 10, 3: 20, 7: 30}
+	var accounts []*prover.Wallet
+	for _, account := range []uint32{0, 3, 7} {
+		w, err := prover.NewWalletFromMnemonic(mnemonic, "", account, verifier.NewLocalChain())
+		require.NoError(t, err)
+		accounts = append(accounts, w)
+	}
+	// the account 0 mints the notes of itself and the account 3.
+	require.NoError(t, mintTo(accounts[0], accounts[1].Address, 20, 10))
+	require.NoError(t, mintTo(accounts[2], accounts[2].Address, 30, 0))
+
+	restored, err := prover.RestoreWallets(mnemonic, "", verifier.NewLocalChain(), 5)
+	require.NoError(t, err)
+	require.Len(t, restored, 8)
+	for account, w := range restored {
+		_w, err := prover.NewWalletFromMnemonic(mnemonic, "", uint32(account), verifier.NewLocalChain())
+		require.NoError(t, err)
+		require.Equal(t, _w.Address, w.Address)
+		require.Equal(t, funded[uint32(account)], w.GetBalance().Uint64())
+		require.Equal(t, verifier.GetZKTxCount(), w.Checkpoint().TxIdx)
+	}
+
+	// the account 7 is beyond the gap limit from the account 3.
+	restored, err = prover.RestoreWallets(mnemonic, "", verifier.NewLocalChain(), 3)
+	require.NoError(t, err)
+	require.Len(t, restored, 4)
+
+	// so is the account 3 from the account 0.
+	restored, err = prover.RestoreWallets(mnemonic, "", verifier.NewLocalChain(), 2)
+	require.NoError(t, err)
+	require.Len(t, restored, 1)
+
+	// another passphrase derives other accounts.
+	restored, err = prover.RestoreWallets(mnemonic, "other", verifier.NewLocalChain(), 2)
+	require.NoError(t, err)
+	require.Len(t, restored, 1)
+	require.True(t, restored[0].GetBalance().IsZero())
+
+	_, err = prover.RestoreWallets("wrong mnemonic", "", verifier.NewLocalChain(), 2)
+	require.Error(t, err)
+}
+
+func TestNewWalletFromMnemonic_Vector(t *testing.T) {
+	// the addresses pin the derivation from the mnemonic to the Jubjub key.
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	for account, addr := range []string{
+		"bz34fWJZjxxPbgwkCQ4WEXK1Sbpfena9QcpnQ9DM3frfHMSQSn65",
+		"bz4U2ziK2pX93qWr1Znnn5Ngs7efbNsPsGnqt9c94NJhhq56PyaU",
+	} {
+		w, err := prover.NewWalletFromMnemonic(mnemonic, "", uint32(account), nil)
+		require.NoError(t, err)
+		require.Equal(t, addr, w.Address)
+	}
+}
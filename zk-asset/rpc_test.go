@@ -0,0 +1,108 @@
+package zk_asset
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/kysee/zkp/zk-asset/prover"
+	"github.com/kysee/zkp/zk-asset/verifier"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRPC_Transfer(t *testing.T) {
+	srv := httptest.NewServer(verifier.NewRPCHandler())
+	defer srv.Close()
+	client := prover.NewRPCClient(srv.URL)
+
+	sender := wallets[2]
+	receiver := wallets[7]
+	amt, fee := uint256.NewInt(10), uint256.NewInt(0)
+
+	// the verifying key served by rpc should be the one of the verifier.
+	vk, err := client.GetVerifyingKey()
+	require.NoError(t, err)
+	bz0, bz1 := bytes.NewBuffer(nil), bytes.NewBuffer(nil)
+	_, err = vk.WriteTo(bz0)
+	require.NoError(t, err)
+	_, err = verifier.ZKVerifyingKey.WriteTo(bz1)
+	require.NoError(t, err)
+	require.Equal(t, bz1.Bytes(), bz0.Bytes())
+
+	rootInfo, err := client.GetMerkleRoot()
+	require.NoError(t, err)
+	require.Equal(t, verifier.GetNoteCommitmentRoot(), rootInfo.Root)
+	require.Equal(t, verifier.GetNoteCommitmentsCount(), rootInfo.NumLeaves)
+
+	useNote := sender.GetSharedNote(0).ToNoteOf(sender.PublicKey())
+
+	// the merkle proof built by the client should be same as the one of the verifier.
+	rootHash, proofPath, depth, idx, numLeaves, err := prover.GetNoteCommitmentMerkle(client, useNote.Commitment())
+	require.NoError(t, err)
+	_rootHash, _proofPath, _depth, _idx, _, err := verifier.GetNoteCommitmentMerkle(useNote.Commitment())
+	require.NoError(t, err)
+	require.Equal(t, _rootHash, rootHash)
+	require.Equal(t, _proofPath, proofPath)
+	require.Equal(t, _depth, depth)
+	require.Equal(t, _idx, idx)
+
+	zkTx, err := prover.CreateZKTx(
+		sender.PrivateKey,
+		receiver.Address, amt, fee, nil,
+		useNote,
+		rootHash, proofPath, depth, idx, numLeaves,
+		prKey, css,
+	)
+	require.NoError(t, err)
+
+	found, err := client.HasNullifier(zkTx.Nullifiers[0])
+	require.NoError(t, err)
+	require.False(t, found)
+
+	txIdx, err := client.SubmitZKTx(zkTx)
+	require.NoError(t, err)
+
+	// double spending
+	_, err = client.SubmitZKTx(zkTx)
+	require.ErrorContains(t, err, "nullifier already exists")
+
+	found, err = client.HasNullifier(zkTx.Nullifiers[0])
+	require.NoError(t, err)
+	require.True(t, found)
+
+	_zkTx, err := client.GetZKTx(txIdx)
+	require.NoError(t, err)
+	require.Equal(t, zkTx, _zkTx)
+
+	_zkTx, err = client.GetZKTx(txIdx + 1)
+	require.NoError(t, err)
+	require.Nil(t, _zkTx)
+
+	zkTxs, err := client.GetZKTxs(txIdx, txIdx+10)
+	require.NoError(t, err)
+	require.Len(t, zkTxs, 1)
+	require.Equal(t, zkTx, zkTxs[0])
+
+	// the new commitments and secret notes are the last ones of the ledger.
+	n := verifier.GetNoteCommitmentsCount()
+	cms, err := client.GetNoteCommitments(n-2, n)
+	require.NoError(t, err)
+	require.Equal(t, zkTx.NewNoteCommitments, cms)
+
+	sns, err := client.GetSecretNotes(n-2, n+2)
+	require.NoError(t, err)
+	require.Equal(t, zkTx.NewSecretNotes, sns)
+
+	_, err = client.GetSecretNotes(2, 1)
+	require.ErrorContains(t, err, "wrong range")
+
+	// the receiver's wallet running on the remote side should see the same notes as the local one.
+	_, err = receiver.SyncSharedNotes()
+	require.NoError(t, err)
+	remoteReceiver := prover.NewWalletWithKey(receiver.PrivateKey, client)
+	_, err = remoteReceiver.SyncSharedNotes()
+	require.NoError(t, err)
+	require.Equal(t, receiver.GetSharedNotesCount(), remoteReceiver.GetSharedNotesCount())
+	require.Equal(t, receiver.GetBalance(), remoteReceiver.GetBalance())
+}
@@ -0,0 +1,84 @@
+package zk_asset
+
+import (
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/kysee/zkp/zk-asset/prover"
+	"github.com/kysee/zkp/zk-asset/verifier"
+	"github.com/stretchr/testify/require"
+)
+
+var _ prover.Broadcaster = (*verifier.LocalChain)(nil)
+
+// newFundedWallet returns a new wallet having a note of each balance.
+func newFundedWallet(t *testing.T, balances ...uint64) *prover.Wallet {
+	w := prover.NewWallet(verifier.NewLocalChain())
+	w.SetProvingKey(prKey, css)
+	// a mint tx makes a note of the output and a note of the change.
+	for i := 0; i < len(balances); i += 2 {
+		var change uint64
+		if i+1 < len(balances) {
+			change = balances[i+1]
+		}
+		require.NoError(t, mintTo(w, w.Address, balances[i], change))
+	}
+	_, err := w.SyncSharedNotes()
+	require.NoError(t, err)
+	require.Equal(t, len(balances), w.GetSharedNotesCount())
+	return w
+}
+
+func TestSend_ChainedTxs(t *testing.T) {
+	sender := newFundedWallet(t, 30, 20, 10)
+	receiver := prover.NewWallet(verifier.NewLocalChain())
+	amt, fee := uint256.NewInt(55), uint256.NewInt(1)
+
+	// 30+20 does not cover 55+1, so the 3 notes are spent by 2 txs.
+	txs, err := sender.Send(receiver.Address, amt, fee, nil)
+	require.NoError(t, err)
+	require.Len(t, txs, 2)
+
+	// the first tx spends its notes fully, so it has no change.
+	require.Empty(t, txs[0].NewNoteCommitments[1])
+	require.NotEmpty(t, txs[1].NewNoteCommitments[1])
+
+	require.EqualValues(t, 60-55-2, sender.GetBalance().Uint64())
+	require.Equal(t, 1, sender.GetSharedNotesCount())
+
+	_, err = receiver.SyncSharedNotes()
+	require.NoError(t, err)
+	require.Equal(t, amt, receiver.GetBalance())
+	require.Equal(t, 2, receiver.GetSharedNotesCount())
+
+	// insufficient balance
+	txs, err = sender.Send(receiver.Address, uint256.NewInt(3), fee, nil)
+	require.ErrorIs(t, err, prover.ErrInsufficientBalance)
+	require.Empty(t, txs)
+}
+
+func TestConsolidate(t *testing.T) {
+	w := newFundedWallet(t, 1, 2, 3, 50)
+	fee := uint256.NewInt(1)
+
+	// the notes less than 10 are merged: (1, 2) -> 2, and then (2, 3) -> 4
+	txs, err := w.Consolidate(fee, uint256.NewInt(10))
+	require.NoError(t, err)
+	require.Len(t, txs, 2)
+	require.Equal(t, []uint64{50, 4}, balancesOf(w.UnspentNotes()))
+
+	// a tx can spend the merged note.
+	receiver := prover.NewWallet(verifier.NewLocalChain())
+	w.SetSelectionStrategy(prover.MinimizeChange)
+	_, err = w.Send(receiver.Address, uint256.NewInt(3), fee, nil)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{50}, balancesOf(w.UnspentNotes()))
+}
+
+func balancesOf(notes []*prover.WalletNote) []uint64 {
+	var ret []uint64
+	for _, n := range notes {
+		ret = append(ret, n.Balance.Uint64())
+	}
+	return ret
+}
@@ -0,0 +1,98 @@
+package zk_asset
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/signature"
+	"github.com/holiman/uint256"
+	"github.com/kysee/zkp/zk-asset/crypto"
+	"github.com/kysee/zkp/zk-asset/prover"
+	"github.com/kysee/zkp/zk-asset/verifier"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMintAndBurn(t *testing.T) {
+	var keys []signature.Signer
+	var pubKeys []signature.PublicKey
+	for i := 0; i < 3; i++ {
+		k, err := crypto.NewKey()
+		require.NoError(t, err)
+		keys = append(keys, k)
+		pubKeys = append(pubKeys, k.Public())
+	}
+	require.Error(t, verifier.SetIssuers(4, pubKeys...))
+	require.NoError(t, verifier.SetIssuers(2, pubKeys...))
+	defer func() {
+		require.NoError(t, verifier.SetIssuers(1, issuerKey.Public()))
+	}()
+
+	w := prover.NewWallet(verifier.NewLocalChain())
+	w.SetProvingKey(prKey, css)
+	supply := verifier.GetSupply()
+	require.NoError(t, verifier.AuditSupply())
+
+	info, err := w.Chain().GetMerkleRoot()
+	require.NoError(t, err)
+	b := prover.NewTxBuilder(w.ProofAuthorizingKey())
+	b.SetAnchor(info.Root, info.Depth)
+	require.NoError(t, b.AddMint(uint256.NewInt(70)))
+	require.NoError(t, b.AddOutput(w.Address, uint256.NewInt(70), nil))
+	utx, err := b.Build()
+	require.NoError(t, err)
+	require.True(t, utx.Tx.IsMint())
+	require.NoError(t, prover.SignZKTx(utx.Tx, w.PrivateKey, utx.Witness.Alpha))
+
+	//
+	// the signatures are checked before the proof.
+	// no signature
+	require.ErrorIs(t, verifier.VerifyZKTx(utx.Tx), verifier.ErrNotAuthorizedMint)
+	// the same issuer twice
+	require.NoError(t, prover.SignMint(utx.Tx, keys[0]))
+	require.NoError(t, prover.SignMint(utx.Tx, keys[0]))
+	require.ErrorIs(t, verifier.VerifyZKTx(utx.Tx), verifier.ErrNotAuthorizedMint)
+	// the key which is not an issuer
+	require.NoError(t, prover.SignMint(utx.Tx, issuerKey))
+	require.ErrorIs(t, verifier.VerifyZKTx(utx.Tx), verifier.ErrNotAuthorizedMint)
+
+	// 2 of 3 issuers
+	require.NoError(t, prover.SignMint(utx.Tx, keys[2]))
+	utx.Tx.ProofBytes, err = prover.Prove(utx.Witness, prKey, css)
+	require.NoError(t, err)
+
+	// the signatures do not cover a changed value.
+	tampered := *utx.Tx
+	tampered.PublicIn = uint256.NewInt(700)
+	require.Error(t, verifier.VerifyZKTx(&tampered))
+
+	require.NoError(t, verifier.VerifyZKTx(utx.Tx))
+	_, err = w.SyncSharedNotes()
+	require.NoError(t, err)
+	require.EqualValues(t, 70, w.GetBalance().Uint64())
+
+	_supply := verifier.GetSupply()
+	require.EqualValues(t, supply.Minted.Uint64()+70, _supply.Minted.Uint64())
+	require.EqualValues(t, supply.Total.Uint64()+70, _supply.Total.Uint64())
+	require.NoError(t, verifier.AuditSupply())
+
+	//
+	// burn
+	txs, err := w.Burn(uint256.NewInt(30), uint256.NewInt(1))
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	require.True(t, txs[0].IsBurn())
+	require.Empty(t, txs[0].NewNoteCommitments[0])
+	require.EqualValues(t, 70-30-1, w.GetBalance().Uint64())
+
+	supply, _supply = _supply, verifier.GetSupply()
+	require.EqualValues(t, supply.Burned.Uint64()+30, _supply.Burned.Uint64())
+	require.EqualValues(t, supply.Total.Uint64()-30, _supply.Total.Uint64())
+	require.NoError(t, verifier.AuditSupply())
+
+	// the supply served by rpc
+	srv := httptest.NewServer(verifier.NewRPCHandler())
+	defer srv.Close()
+	remoteSupply, err := prover.NewRPCClient(srv.URL).GetSupply()
+	require.NoError(t, err)
+	require.Equal(t, _supply, remoteSupply)
+}
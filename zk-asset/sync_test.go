@@ -0,0 +1,102 @@
+package zk_asset
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/kysee/zkp/zk-asset/prover"
+	"github.com/kysee/zkp/zk-asset/types"
+	"github.com/kysee/zkp/zk-asset/verifier"
+	"github.com/stretchr/testify/require"
+)
+
+// countingChain counts the txs which a wallet fetches.
+type countingChain struct {
+	prover.ChainSource
+	fetchedTxs int
+}
+
+func (c *countingChain) GetCompactBlock(from, to int) (*types.CompactBlock, error) {
+	blk, err := c.ChainSource.GetCompactBlock(from, to)
+	if blk != nil {
+		c.fetchedTxs += len(blk.Txs)
+	}
+	return blk, err
+}
+
+func TestSync_ResumeFromCheckpoint(t *testing.T) {
+	sender := wallets[3]
+	receiver := wallets[8]
+	amt, fee := uint256.NewInt(10), uint256.NewInt(1)
+
+	_, err := sender.SyncSharedNotes()
+	require.NoError(t, err)
+	require.Equal(t, verifier.GetZKTxCount(), sender.Checkpoint().TxIdx)
+	require.Equal(t, verifier.GetNoteCommitmentsCount(), sender.Checkpoint().CommitmentIdx)
+
+	// persist the sender's wallet state and restore it into a new wallet process.
+	bz, err := json.Marshal(sender.State())
+	require.NoError(t, err)
+	st := &prover.WalletState{}
+	require.NoError(t, json.Unmarshal(bz, st))
+
+	chain := &countingChain{ChainSource: verifier.NewLocalChain()}
+	restored := prover.NewWalletWithKey(sender.PrivateKey, chain)
+	require.NoError(t, restored.RestoreState(st))
+	require.Equal(t, sender.GetBalance(), restored.GetBalance())
+	require.Equal(t, sender.Checkpoint(), restored.Checkpoint())
+
+	// nothing to scan
+	_, err = restored.SyncSharedNotes()
+	require.NoError(t, err)
+	require.Equal(t, 0, chain.fetchedTxs)
+
+	// the position of the note found by the wallet should be its leaf index in the ledger.
+	usedNote := restored.UnspentNotes()[0]
+	require.Equal(t, usedNote.Commitment, verifier.GetNoteCommitment(usedNote.Position))
+
+	useNote := usedNote.ToNoteOf(sender.PublicKey())
+	rootHash, proofPath, depth, idx, numLeaves, err := verifier.GetNoteCommitmentMerkle(useNote.Commitment())
+	require.NoError(t, err)
+	require.EqualValues(t, usedNote.Position, idx)
+
+	zkTx, err := prover.CreateZKTx(
+		sender.PrivateKey,
+		receiver.Address, amt, fee, nil,
+		useNote,
+		rootHash, proofPath, depth, idx, numLeaves,
+		prKey, css,
+	)
+	require.NoError(t, err)
+	require.NoError(t, verifier.VerifyZKTx(zkTx))
+
+	// only the new tx is scanned.
+	balance0 := restored.GetBalance()
+	_, err = restored.SyncSharedNotes()
+	require.NoError(t, err)
+	require.Equal(t, 1, chain.fetchedTxs)
+	require.True(t, usedNote.Spent)
+	require.Equal(t, new(uint256.Int).Sub(balance0, new(uint256.Int).Add(amt, fee)), restored.GetBalance())
+
+	// the spent note and the change note are recorded in the history.
+	history := restored.History()
+	require.GreaterOrEqual(t, len(history), 2)
+	spent, change := history[len(history)-2], history[len(history)-1]
+	require.Equal(t, prover.TxSpent, spent.Kind)
+	require.Equal(t, usedNote.Balance, spent.Amount)
+	require.Equal(t, prover.TxReceived, change.Kind)
+	require.Equal(t, spent.TxIdx, change.TxIdx)
+
+	// the result of the incremental sync should be same as the one of the full rescan.
+	rescanned := prover.NewWalletWithKey(sender.PrivateKey, verifier.NewLocalChain())
+	_, err = rescanned.SyncSharedNotes()
+	require.NoError(t, err)
+	require.Equal(t, rescanned.GetBalance(), restored.GetBalance())
+	require.Equal(t, rescanned.Checkpoint(), restored.Checkpoint())
+	require.Equal(t, rescanned.State().Notes, restored.State().Notes)
+
+	// a wrong state should be rejected.
+	other := prover.NewWalletWithKey(receiver.PrivateKey, verifier.NewLocalChain())
+	require.ErrorContains(t, other.RestoreState(restored.State()), "not mine")
+}
@@ -39,34 +39,35 @@ func init() {
 }
 
 func TestTransfer(t *testing.T) {
-	sender := prover.Wallets[0]
-	receiver := prover.Wallets[5]
+	sender := wallets[0]
+	receiver := wallets[5]
 	amt, fee := uint256.NewInt(10), uint256.NewInt(0)
+	memo := []byte("for the dinner")
 
 	senderBalance0 := sender.GetBalance()
 	recieverBalance0 := receiver.GetBalance()
 
 	useSharedNote := sender.GetSharedNote(0)
-	useNote := useSharedNote.ToNoteOf(sender.PrivateKey.Public())
+	useNote := useSharedNote.ToNoteOf(sender.PublicKey())
 	useNoteCommitment := useNote.Commitment()
 
 	// get merkle proof info.
-	rootHash, proofPath, depth, idx, _, err := verifier.GetNoteCommitmentMerkle(useNoteCommitment)
+	rootHash, proofPath, depth, idx, numLeaves, err := verifier.GetNoteCommitmentMerkle(useNoteCommitment)
 	require.NoError(t, err)
 	//fmt.Printf("Merkle Info: numLeaves=%d, idx=%d, depth=%d, proofPath.len=%d\n", numLeaves, idx, depth, len(proofPath))
 
 	// generate the ZKTx including zk-proof
 	zkTx, err := prover.CreateZKTx(
 		sender.PrivateKey,
-		receiver.Address, amt, fee,
+		receiver.Address, amt, fee, memo,
 		useNote,
-		rootHash, proofPath, depth, idx,
+		rootHash, proofPath, depth, idx, numLeaves,
 		prKey, css,
 	)
 	require.NoError(t, err)
 	fmt.Printf("proof      : (%4dB) %x\n", len(zkTx.ProofBytes), zkTx.ProofBytes)
 	fmt.Printf("merkle root: (%4dB) %x\n", len(zkTx.MerkleRoot), zkTx.MerkleRoot)
-	fmt.Printf("nullifier  : (%4dB) %x\n", len(zkTx.Nullifier), zkTx.Nullifier)
+	fmt.Printf("nullifier  : (%4dB) %x\n", len(zkTx.Nullifiers[0]), zkTx.Nullifiers[0])
 	fmt.Printf("newNote    : (%4dB) %x\n", len(zkTx.NewNoteCommitments[0]), zkTx.NewNoteCommitments[0])
 	fmt.Printf("changeNote : (%4dB) %x\n", len(zkTx.NewNoteCommitments[1]), zkTx.NewNoteCommitments[1])
 
@@ -76,8 +77,10 @@ func TestTransfer(t *testing.T) {
 
 	fmt.Println("---")
 
-	_ = sender.SyncSharedNotes()
-	_ = receiver.SyncSharedNotes()
+	_, err = sender.SyncSharedNotes()
+	require.NoError(t, err)
+	_, err = receiver.SyncSharedNotes()
+	require.NoError(t, err)
 
 	senderBalance1 := sender.GetBalance()
 	recieverBalance1 := receiver.GetBalance()
@@ -86,22 +89,36 @@ func TestTransfer(t *testing.T) {
 
 	fmt.Println("sender balance  : ", senderBalance0.Dec(), "-->", senderBalance1.Dec())
 	fmt.Println("receiver balance: ", recieverBalance0.Dec(), "-->", recieverBalance1.Dec())
+
+	// the receiver reads the memo and finds it in the history.
+	for _, wn := range receiver.UnspentNotes() {
+		if bytes.Equal(wn.Commitment, zkTx.NewNoteCommitments[0]) {
+			received, err := receiver.FetchMemo(wn)
+			require.NoError(t, err)
+			require.Equal(t, memo, received)
+		}
+	}
+	history := receiver.History()
+	last := history[len(history)-1]
+	require.Equal(t, prover.TxReceived, last.Kind)
+	require.Equal(t, zkTx.NewNoteCommitments[0], last.Commitment)
+	require.Equal(t, memo, last.Memo)
 }
 
 func Test_NonExistNote(t *testing.T) {
-	sender := prover.Wallets[0]
-	receiver := prover.Wallets[5]
+	sender := wallets[0]
+	receiver := wallets[5]
 	amt, fee := uint256.NewInt(10), uint256.NewInt(0)
 
 	nonExistNote := &types.Note{
 		Version: 1,
-		PubKey:  sender.PrivateKey.Public(),
+		PubKey:  sender.PublicKey(),
 		Balance: uint256.NewInt(1_000_000),
 		Salt:    types.RandBytes(32),
 	}
 
 	// get merkle proof info for the existing note.
-	existNote := sender.GetSharedNote(0).ToNoteOf(sender.PrivateKey.Public())
+	existNote := sender.GetSharedNote(0).ToNoteOf(sender.PublicKey())
 	rootHash, proofPath, depth, idx, numLeaves, err := verifier.GetNoteCommitmentMerkle(existNote.Commitment())
 	require.NoError(t, err)
 	fmt.Printf("Merkle Info: numLeaves=%d, idx=%d, depth=%d, proofPath.len=%d\n", numLeaves, idx, depth, len(proofPath))
@@ -109,9 +126,9 @@ func Test_NonExistNote(t *testing.T) {
 	// expected error: nonExistNote.Commitment() is not in the proofPath
 	_, err = prover.CreateZKTx(
 		sender.PrivateKey,
-		receiver.Address, amt, fee,
+		receiver.Address, amt, fee, nil,
 		nonExistNote,
-		rootHash, proofPath, depth, idx,
+		rootHash, proofPath, depth, idx, numLeaves,
 		prKey, css,
 	)
 	require.Error(t, err)
@@ -122,39 +139,41 @@ func Test_NonExistNote(t *testing.T) {
 	// expected error: rootHash is not same
 	_, err = prover.CreateZKTx(
 		sender.PrivateKey,
-		receiver.Address, amt, fee,
+		receiver.Address, amt, fee, nil,
 		nonExistNote,
-		rootHash, proofPath, depth, idx,
+		rootHash, proofPath, depth, idx, numLeaves,
 		prKey, css,
 	)
 	require.Error(t, err)
 }
 
 func Test_WrongNewSharedNote(t *testing.T) {
-	sender := prover.Wallets[1]
-	receiver := prover.Wallets[6]
+	sender := wallets[1]
+	receiver := wallets[6]
 	amt, fee := uint256.NewInt(10), uint256.NewInt(0)
 
 	senderBalance0 := sender.GetBalance()
 	recieverBalance0 := receiver.GetBalance()
 
 	useSharedNote := sender.GetSharedNote(0)
-	useNote := useSharedNote.ToNoteOf(sender.PrivateKey.Public())
+	useNote := useSharedNote.ToNoteOf(sender.PublicKey())
 	useNoteCommitment := useNote.Commitment()
 
 	// get merkle proof info.
-	rootHash, proofPath, depth, idx, _, err := verifier.GetNoteCommitmentMerkle(useNoteCommitment)
+	rootHash, proofPath, depth, idx, numLeaves, err := verifier.GetNoteCommitmentMerkle(useNoteCommitment)
 	require.NoError(t, err)
 	//fmt.Printf("Merkle Info: numLeaves=%d, idx=%d, depth=%d, proofPath.len=%d\n", numLeaves, idx, depth, len(proofPath))
 
-	// generate the ZKTx including zk-proof
-	zkTx, err := prover.CreateZKTx(
-		sender.PrivateKey,
-		receiver.Address, amt, fee,
-		useNote,
-		rootHash, proofPath, depth, idx,
-		prKey, css,
-	)
+	// build and prove the ZKTx
+	b := prover.NewTxBuilder(sender.ProofAuthorizingKey())
+	require.NoError(t, b.AddInput(&prover.NoteInput{Note: useNote, ProofPath: proofPath, Idx: idx, NumLeaves: numLeaves}))
+	b.SetAnchor(rootHash, depth)
+	require.NoError(t, b.AddOutput(receiver.Address, amt, nil))
+	b.SetFee(fee)
+	utx, err := b.Build()
+	require.NoError(t, err)
+	zkTx := utx.Tx
+	zkTx.ProofBytes, err = prover.Prove(utx.Witness, prKey, css)
 	require.NoError(t, err)
 
 	//
@@ -165,17 +184,30 @@ func Test_WrongNewSharedNote(t *testing.T) {
 		Salt:    types.RandBytes(32),
 		Memo:    nil,
 	}
-	zkTx.NewSecretNotes[0], err = types.EncryptSharedNote(fakedNewSharedNote, nil, receiver.PrivateKey.Public())
+	fakedSecretNote, err := types.EncryptSharedNote(fakedNewSharedNote, receiver.PublicKey())
 	require.NoError(t, err)
 
+	// the secret note modified after signing is rejected.
+	signedTx := *zkTx
+	require.NoError(t, prover.SignZKTx(&signedTx, sender.PrivateKey, utx.Witness.Alpha))
+	signedTx.NewSecretNotes = []types.SecretNote{fakedSecretNote, zkTx.NewSecretNotes[1]}
+	require.ErrorIs(t, verifier.VerifyZKTx(&signedTx), types.ErrWrongSpendAuthSig)
+
+	// the sender signs the modified secret note.
+	// the proof does not cover the secret notes, so the tx is accepted but the receiver can not find the note.
+	zkTx.NewSecretNotes[0] = fakedSecretNote
+	require.NoError(t, prover.SignZKTx(zkTx, sender.PrivateKey, utx.Witness.Alpha))
+
 	// send the ZKTx to the verifier
 	err = verifier.VerifyZKTx(zkTx)
 	require.NoError(t, err)
 
 	fmt.Println("---")
 
-	_ = sender.SyncSharedNotes()
-	_ = receiver.SyncSharedNotes()
+	_, err = sender.SyncSharedNotes()
+	require.NoError(t, err)
+	_, err = receiver.SyncSharedNotes()
+	require.NoError(t, err)
 
 	senderBalance1 := sender.GetBalance()
 	recieverBalance1 := receiver.GetBalance()
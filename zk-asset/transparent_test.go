@@ -0,0 +1,126 @@
+package zk_asset
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/holiman/uint256"
+	"github.com/kysee/zkp/zk-asset/crypto"
+	"github.com/kysee/zkp/zk-asset/prover"
+	"github.com/kysee/zkp/zk-asset/types"
+	"github.com/kysee/zkp/zk-asset/verifier"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShieldAndUnshield(t *testing.T) {
+	w := newFundedWallet(t, 50)
+	tKey, err := crypto.NewKey()
+	require.NoError(t, err)
+	tAddr := types.Pub2TransparentAddr(tKey.Public())
+	fee := uint256.NewInt(1)
+	pool := verifier.GetShieldedPoolValue()
+
+	// the shielded address is not a transparent one.
+	_, err = w.Unshield(w.Address, uint256.NewInt(20), fee)
+	require.Error(t, err)
+
+	//
+	// unshield 20 from the notes to the transparent account.
+	txs, err := w.Unshield(tAddr, uint256.NewInt(20), fee)
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	// no new note but the change.
+	require.Empty(t, txs[0].NewNoteCommitments[0])
+	require.NotEmpty(t, txs[0].NewNoteCommitments[1])
+
+	require.EqualValues(t, 50-20-1, w.GetBalance().Uint64())
+	require.EqualValues(t, 20, verifier.GetTransparentBalance(tAddr).Uint64())
+	// the fee leaves the shielded pool, too.
+	require.EqualValues(t, pool.Uint64()-20-1, verifier.GetShieldedPoolValue().Uint64())
+
+	//
+	// shield 15 from the transparent account to the wallet.
+	_, err = w.Shield(tKey, uint256.NewInt(15), fee)
+	require.NoError(t, err)
+
+	require.EqualValues(t, 29+15, w.GetBalance().Uint64())
+	require.EqualValues(t, 20-15-1, verifier.GetTransparentBalance(tAddr).Uint64())
+	require.EqualValues(t, pool.Uint64()-20-1+16-1, verifier.GetShieldedPoolValue().Uint64())
+
+	//
+	// the value balance is checked before the proof, so the txs below need not be proven.
+	info, err := w.Chain().GetMerkleRoot()
+	require.NoError(t, err)
+	newShieldTx := func(amount uint64) *types.ZKTx {
+		b := prover.NewTxBuilder(w.ProofAuthorizingKey())
+		b.SetAnchor(info.Root, info.Depth)
+		require.NoError(t, b.AddTransparentInput(tAddr, uint256.NewInt(amount)))
+		require.NoError(t, b.AddOutput(w.Address, uint256.NewInt(amount), nil))
+		utx, err := b.Build()
+		require.NoError(t, err)
+		require.NoError(t, prover.SignZKTx(utx.Tx, w.PrivateKey, utx.Witness.Alpha))
+		return utx.Tx
+	}
+
+	// insufficient transparent balance
+	tx := newShieldTx(5)
+	require.NoError(t, prover.SignTransparent(tx, tKey))
+	require.ErrorContains(t, verifier.VerifyZKTx(tx), "insufficient transparent balance")
+
+	// the tx not signed by the transparent account
+	otherKey, err := crypto.NewKey()
+	require.NoError(t, err)
+	tx = newShieldTx(4)
+	require.Error(t, prover.SignTransparent(tx, otherKey))
+	require.ErrorIs(t, verifier.VerifyZKTx(tx), types.ErrWrongTransparentSig)
+
+	// the signature does not cover a changed value.
+	require.NoError(t, prover.SignTransparent(tx, tKey))
+	tx.PublicIn = uint256.NewInt(3)
+	require.ErrorIs(t, verifier.VerifyZKTx(tx), types.ErrWrongSpendAuthSig)
+
+	require.EqualValues(t, 4, verifier.GetTransparentBalance(tAddr).Uint64())
+
+	// the balance served by rpc
+	srv := httptest.NewServer(verifier.NewRPCHandler())
+	defer srv.Close()
+	client := prover.NewRPCClient(srv.URL)
+	bal, err := client.GetTransparentBalance(tAddr)
+	require.NoError(t, err)
+	require.EqualValues(t, 4, bal.Uint64())
+	_, err = client.GetTransparentBalance(w.Address)
+	require.ErrorContains(t, err, "status(400)")
+}
+
+func TestUnshield_OutOfRange(t *testing.T) {
+	w := newFundedWallet(t, 50)
+	tKey, err := crypto.NewKey()
+	require.NoError(t, err)
+	tAddr := types.Pub2TransparentAddr(tKey.Public())
+
+	notes := w.UnspentNotes()
+	inputs, root, depth, err := prover.GetNoteInputs(w.Chain(), []*types.Note{notes[0].ToNoteOf(w.PublicKey())})
+	require.NoError(t, err)
+	b := prover.NewTxBuilder(w.ProofAuthorizingKey())
+	require.NoError(t, b.AddInput(inputs[0]))
+	b.SetAnchor(root, depth)
+	require.NoError(t, b.AddTransparentOutput(tAddr, uint256.NewInt(20)))
+	utx, err := b.Build()
+	require.NoError(t, err)
+	utx.Tx.ProofBytes, err = prover.Prove(utx.Witness, prKey, css)
+	require.NoError(t, err)
+
+	// the proof of 20 holds for r+20, since the public inputs are reduced modulo r.
+	r, overflow := uint256.FromBig(ecc.BN254.ScalarField())
+	require.False(t, overflow)
+	tampered := *utx.Tx
+	tampered.PublicOut = new(uint256.Int).Add(r, uint256.NewInt(20))
+	require.NoError(t, prover.SignZKTx(&tampered, w.PrivateKey, utx.Witness.Alpha))
+	require.ErrorContains(t, verifier.VerifyZKTx(&tampered), "too large value")
+	require.True(t, verifier.GetTransparentBalance(tAddr).IsZero())
+
+	require.NoError(t, prover.SignZKTx(utx.Tx, w.PrivateKey, utx.Witness.Alpha))
+	require.NoError(t, verifier.VerifyZKTx(utx.Tx))
+	require.EqualValues(t, 20, verifier.GetTransparentBalance(tAddr).Uint64())
+}
@@ -42,3 +42,35 @@ func Addr2Pub(addr string) signature.PublicKey {
 	_, _ = pubKey.SetBytes(pubKeyBytes)
 	return pubKey
 }
+
+//
+// Transparent addresses
+//
+// A transparent account is identified by a Jubjub public key.
+// Its balance is public and kept by the verifier; see `ZKTx.PublicIn` and `ZKTx.PublicOut`.
+
+const transparentAddrPrefix = "bt"
+
+// Pub2TransparentAddr returns the transparent address of `pubKey`.
+func Pub2TransparentAddr(pubKey signature.PublicKey) string {
+	return transparentAddrPrefix + base58.CheckEncode(pubKey.Bytes(), ver)
+}
+
+// TransparentAddr2Pub returns the public key of the transparent address.
+func TransparentAddr2Pub(addr string) (signature.PublicKey, error) {
+	if !strings.HasPrefix(addr, transparentAddrPrefix) {
+		return nil, fmt.Errorf("wrong transparent address prefix: %s", addr)
+	}
+	bz, _ver, err := base58.CheckDecode(addr[len(transparentAddrPrefix):])
+	if err != nil {
+		return nil, err
+	}
+	if _ver != ver {
+		return nil, fmt.Errorf("wrong version: expected(%d), got(%d)", ver, _ver)
+	}
+	pubKey := crypto.NewPub()
+	if _, err := pubKey.SetBytes(bz); err != nil {
+		return nil, err
+	}
+	return pubKey, nil
+}
@@ -0,0 +1,14 @@
+package types
+
+import "github.com/holiman/uint256"
+
+// Block is a range of consecutive ZKTxs sealed by a producer.
+// The fees of the txs in the block are paid to the transparent account `Producer`.
+type Block struct {
+	Height int `json:"height"`
+	// FromTxIdx, ToTxIdx is the range [from, to) of the txs in the block.
+	FromTxIdx int          `json:"fromTxIdx"`
+	ToTxIdx   int          `json:"toTxIdx"`
+	Fees      *uint256.Int `json:"fees"`
+	Producer  string       `json:"producer"`
+}
@@ -1,8 +1,6 @@
 package types
 
 import (
-	"math/big"
-
 	"github.com/consensys/gnark-crypto/ecc"
 	ecc_tedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
 	"github.com/consensys/gnark/backend/plonk"
@@ -18,36 +16,55 @@ import (
 	"github.com/consensys/gnark/test/unsafekzg"
 )
 
-var (
-	E128 = new(big.Int).Lsh(big.NewInt(1), 128)
-)
+// NumInputNotes is the number of notes spent by a ZKTx.
+// A tx spending fewer notes fills the rest of the inputs with dummy notes of zero balance.
+const NumInputNotes = 2
+
+// MaxValueBits is the bit size of the values in the circuit.
+// The values are range checked, so the sum of them can not wrap around the field modulus.
+const MaxValueBits = 128
+
+// InputNote is a note spent in the circuit.
+type InputNote struct {
+	Balance        frontend.Variable
+	Salt           frontend.Variable
+	NoteCommitment frontend.Variable
+	NoteIdx        frontend.Variable
+	NoteMerklePath []frontend.Variable
+}
 
 type ZKCircuit struct {
 	curveID ecc_tedwards.ID
 
-	FromPrv0 frontend.Variable
-	FromPrv1 frontend.Variable
+	// the proof authorizing key of the spender; see `ProofAuthorizingKey`.
+	Ak std_eddsa.PublicKey
+	Nk frontend.Variable
+	// Alpha randomizes `Ak` to `Rk` which verifies the spend authorizing signature of the tx.
+	Alpha frontend.Variable
+	Rk    std_eddsa.PublicKey `gnark:",public"`
 
 	NoteVer frontend.Variable
 
-	// used note
-	FromPub        std_eddsa.PublicKey
-	Balance        frontend.Variable
-	Salt0          frontend.Variable
-	NoteCommitment frontend.Variable
-	NoteIdx        frontend.Variable
-	NoteMerklePath []frontend.Variable
-	NoteMerkleRoot frontend.Variable `gnark:",public"`
-	Nullifier      frontend.Variable `gnark:",public"`
+	// used notes
+	Inputs         [NumInputNotes]InputNote
+	NoteMerkleRoot frontend.Variable                `gnark:",public"`
+	Nullifiers     [NumInputNotes]frontend.Variable `gnark:",public"`
 
 	// new notes (new note and change note)
 	Amount frontend.Variable
-	Fee    frontend.Variable
-	ToPub  std_eddsa.PublicKey
-	Salt1  frontend.Variable
+	// Fee is public, so the verifier can collect it.
+	Fee   frontend.Variable `gnark:",public"`
+	ToPub std_eddsa.PublicKey
+	Salt1 frontend.Variable
+	Salt2 frontend.Variable
 
 	NewNoteCommitment    frontend.Variable `gnark:",public"`
 	ChangeNoteCommitment frontend.Variable `gnark:",public"`
+
+	// the value moved from the transparent pool into the notes (shield),
+	// and from the notes to the transparent pool (unshield).
+	PublicIn  frontend.Variable `gnark:",public"`
+	PublicOut frontend.Variable `gnark:",public"`
 }
 
 func (cc *ZKCircuit) Define(api frontend.API) error {
@@ -61,101 +78,102 @@ func (cc *ZKCircuit) Define(api frontend.API) error {
 		return err
 	}
 
-	cc.verifyKeys(api, curve)
-	cc.verifyNoteCommitment(api, &hasher)
+	pk := cc.verifyKeys(api, curve, &hasher)
+	cc.verifyValueRanges(api)
+	cc.verifyNoteCommitments(api, &hasher, pk)
 	cc.verifyNewNoteCommitment(api, &hasher)
-	cc.verifyChangeNoteCommitment(api, &hasher)
+	cc.verifyChangeNoteCommitment(api, &hasher, pk)
 	return nil
 }
 
-func (cc *ZKCircuit) verifyKeys(api frontend.API, curve std_tedwards.Curve) {
-	//
-	//	verify PrvKey Ownership
-	//
-	// Private key scalar = S1 * 2^128 + S0
-	// 범위 체크: 각각이 128bit 내에 있는지 확인
-	_ = api.ToBinary(cc.FromPrv0, 128)
-	_ = api.ToBinary(cc.FromPrv1, 128)
-
+// verifyKeys returns the public key of the spender, `pk`, derived from the proof authorizing key.
+func (cc *ZKCircuit) verifyKeys(api frontend.API, curve std_tedwards.Curve, hasher hash.FieldHasher) std_tedwards.Point {
 	// 베이스 포인트 설정
 	base := std_tedwards.Point{}
 	base.X = curve.Params().Base[0]
 	base.Y = curve.Params().Base[1]
 
-	// Public Key 계산: PubKey = (S1 * 2^128 + S0) * Base
-	// 단계별로:
-	// 1) c1 = S0 * Base
-	c1 := curve.ScalarMul(base, cc.FromPrv0)
+	curve.AssertIsOnCurve(cc.Ak.A)
 
-	// 2) c128 = c1 * 2^128 = (S0 * Base) * 2^128 = (S0 * 2^128) * Base
-	c128 := curve.ScalarMul(c1, E128.Bytes())
+	//
+	// rk = ak + alpha * Base
+	// the spend authorizing signature verified with `Rk` proves that the spender knows `ask`.
+	rk := curve.Add(cc.Ak.A, curve.ScalarMul(base, cc.Alpha))
+	api.AssertIsEqual(cc.Rk.A.X, rk.X)
+	api.AssertIsEqual(cc.Rk.A.Y, rk.Y)
 
-	// 3) c2 = S1 * Base
-	c2 := curve.ScalarMul(base, cc.FromPrv1)
+	//
+	// ivk = Hash(ak, nk), pk = ivk * Base
+	// the notes are owned by `pk`, so only the `nk` of the owner derives it.
+	hasher.Reset()
+	hasher.Write(cc.Ak.A.X, cc.Ak.A.Y, cc.Nk)
+	ivk := hasher.Sum()
+	pk := curve.ScalarMul(base, ivk)
 
-	// 4) computedPub = c128 + c2 = (S0 * 2^128 + S1) * Base
-	computedPubPt := curve.Add(c128, c2)
+	// ToPub 이 유효한 점인지 확인
+	curve.AssertIsOnCurve(cc.ToPub.A)
+	return pk
+}
 
-	// 타원곡선 위의 점인지 확인
-	curve.AssertIsOnCurve(computedPubPt)
+// verifyValueRanges checks that every value is less than 2^MaxValueBits.
+func (cc *ZKCircuit) verifyValueRanges(api frontend.API) {
+	for i := range cc.Inputs {
+		api.ToBinary(cc.Inputs[i].Balance, MaxValueBits)
+	}
+	api.ToBinary(cc.Amount, MaxValueBits)
+	api.ToBinary(cc.Fee, MaxValueBits)
+	api.ToBinary(cc.PublicIn, MaxValueBits)
+	api.ToBinary(cc.PublicOut, MaxValueBits)
+}
 
-	// ✅ 핵심: FromPrvScalar로부터 유도된 공개키가 FromPub과 일치하는지 검증
-	api.AssertIsEqual(cc.FromPub.A.X, computedPubPt.X)
-	api.AssertIsEqual(cc.FromPub.A.Y, computedPubPt.Y)
+func (cc *ZKCircuit) verifyNoteCommitments(api frontend.API, hasher hash.FieldHasher, pk std_tedwards.Point) {
+	for i := range cc.Inputs {
+		cc.verifyNoteCommitment(api, hasher, &cc.Inputs[i], pk, cc.Nullifiers[i])
+	}
 
-	// ToPub 이 유효한 점인지 확인
-	curve.AssertIsOnCurve(cc.ToPub.A)
+	// check balance
+	needAmt := api.Add(cc.Amount, cc.Fee, cc.PublicOut)
+	api.AssertIsLessOrEqual(needAmt, cc.totalInput(api))
+}
+
+// totalInput returns the sum of the input notes and `PublicIn`.
+func (cc *ZKCircuit) totalInput(api frontend.API) frontend.Variable {
+	total := cc.PublicIn
+	for i := range cc.Inputs {
+		total = api.Add(total, cc.Inputs[i].Balance)
+	}
+	return total
 }
 
-func (cc *ZKCircuit) verifyNoteCommitment(api frontend.API, hasher hash.FieldHasher) {
+func (cc *ZKCircuit) verifyNoteCommitment(api frontend.API, hasher hash.FieldHasher, in *InputNote, pk std_tedwards.Point, nullifier frontend.Variable) {
 	//
 	// verify NoteCommitment
 	// Merkle proof 검증 - numLeaves를 고려한 custom verification
-	cc.verifyMerkleProof(api, hasher)
-
-	// check balance
-	needAmt := api.Add(cc.Amount, cc.Fee)
-	api.AssertIsLessOrEqual(needAmt, cc.Balance)
+	// a dummy note (zero balance) is not in the tree, so its Merkle proof is not checked.
+	isDummy := api.IsZero(in.Balance)
+	verifyMerkleProof(api, hasher, in, cc.NoteMerkleRoot, isDummy)
 
 	hasher.Reset()
 	// 각 필드를 개별적으로 Write (Go 코드와 동일하게)
 	hasher.Write(
 		cc.NoteVer,
-		cc.FromPub.A.X,
-		cc.FromPub.A.Y,
-		cc.Balance,
-		cc.Salt0,
+		pk.X,
+		pk.Y,
+		in.Balance,
+		in.Salt,
 	)
 	computedCommitment := hasher.Sum()
-
-	api.Println("Expected NoteCommitment:", cc.NoteCommitment)
-	api.Println("Computed NoteCommitment:", computedCommitment)
-
-	api.AssertIsEqual(cc.NoteCommitment, computedCommitment)
+	api.AssertIsEqual(in.NoteCommitment, computedCommitment)
 
 	//
 	// verify Nullifier
-	// Step 1: Nullifier key 파생
-	// nk = Hash(private_key, "nullifier_key")
-
-	hasher.Reset()
-	// 각 필드를 개별적으로 Write
-	hasher.Write(cc.FromPrv0, cc.FromPrv1)
-	// Domain separator (선택적으로 추가)
-	nk := hasher.Sum()
-
-	// Step 2: Nullifier 계산
 	// nf = Hash(nk, note_commitment)
 	hasher.Reset()
-	hasher.Write(nk, cc.NoteCommitment) // note commitment
+	hasher.Write(cc.Nk, in.NoteCommitment) // note commitment
 	computedNullifier := hasher.Sum()
 
-	// Step 3: ⭐ 계산된 nullifier가 public input과 일치하는지 검증 ⭐
-	// 이것이 핵심! Circuit이 올바른 nullifier를 계산했음을 증명
-	api.Println("Expected Nullifier:", cc.Nullifier)
-	api.Println("Computed Nullifier:", computedNullifier)
-
-	api.AssertIsEqual(cc.Nullifier, computedNullifier)
+	// ⭐ 계산된 nullifier가 public input과 일치하는지 검증 ⭐
+	api.AssertIsEqual(nullifier, computedNullifier)
 }
 
 func (cc *ZKCircuit) verifyNewNoteCommitment(api frontend.API, hasher hash.FieldHasher) {
@@ -165,7 +183,8 @@ func (cc *ZKCircuit) verifyNewNoteCommitment(api frontend.API, hasher hash.Field
 	//
 	hasher.Reset()
 	hasher.Write(cc.NoteVer, cc.ToPub.A.X, cc.ToPub.A.Y, cc.Amount, cc.Salt1)
-	calculatedCommitment := hasher.Sum()
+	// an unshielding tx may have no new note.
+	calculatedCommitment := api.Select(api.IsZero(cc.Amount), 0, hasher.Sum())
 
 	api.Println("Expected NewNoteCommitment:", cc.NewNoteCommitment)
 	api.Println("Computed NewNoteCommitment:", calculatedCommitment)
@@ -174,11 +193,12 @@ func (cc *ZKCircuit) verifyNewNoteCommitment(api frontend.API, hasher hash.Field
 }
 
 // verifyMerkleProof는 gnark std library의 VerifyProof를 사용
-func (cc *ZKCircuit) verifyMerkleProof(api frontend.API, hasher hash.FieldHasher) {
-	api.AssertIsEqual(cc.NoteMerklePath[0], cc.NoteCommitment)
+// If `skip` is 1, the computed root is not compared with `root`.
+func verifyMerkleProof(api frontend.API, hasher hash.FieldHasher, in *InputNote, root, skip frontend.Variable) {
+	api.AssertIsEqual(in.NoteMerklePath[0], in.NoteCommitment)
 	mp := merkle.MerkleProof{
-		RootHash: cc.NoteMerkleRoot,
-		Path:     cc.NoteMerklePath,
+		RootHash: root,
+		Path:     in.NoteMerklePath,
 	}
 	depth := len(mp.Path) - 1
 	sum := _leafSum(hasher, mp.Path[0])
@@ -186,11 +206,8 @@ func (cc *ZKCircuit) verifyMerkleProof(api frontend.API, hasher hash.FieldHasher
 	// The binary decomposition is the bitwise negation of the order of hashes ->
 	// If the path in the plain go code is 					0 1 1 0 1 0
 	// The binary decomposition of the leaf index will be 	1 0 0 1 0 1 (little endian)
-	binLeaf := api.ToBinary(cc.NoteIdx, depth)
+	binLeaf := api.ToBinary(in.NoteIdx, depth)
 
-	for i := 0; i < 5; i++ {
-		api.Println("   Path[", i, "]:", mp.Path[i])
-	}
 	for i := 1; i < len(mp.Path); i++ { // the size of the loop is fixed -> one circuit per size
 		d1 := api.Select(binLeaf[i-1], mp.Path[i], sum)
 		d2 := api.Select(binLeaf[i-1], sum, mp.Path[i])
@@ -198,11 +215,8 @@ func (cc *ZKCircuit) verifyMerkleProof(api frontend.API, hasher hash.FieldHasher
 		sum = api.Select(api.IsZero(mp.Path[i]), sum, newSum)
 	}
 
-	api.Println("Expected RootHash:", cc.NoteMerkleRoot)
-	api.Println("Computed RootHash:", sum)
-
 	// Compare our calculated Merkle root to the desired Merkle root.
-	api.AssertIsEqual(sum, mp.RootHash)
+	api.AssertIsEqual(api.Select(skip, mp.RootHash, sum), mp.RootHash)
 }
 
 // leafSum returns the hash created from data inserted to form a leaf.
@@ -227,18 +241,18 @@ func _nodeSum(h hash.FieldHasher, a, b frontend.Variable) frontend.Variable {
 	return res
 }
 
-func (cc *ZKCircuit) verifyChangeNoteCommitment(api frontend.API, hasher hash.FieldHasher) {
+func (cc *ZKCircuit) verifyChangeNoteCommitment(api frontend.API, hasher hash.FieldHasher, pk std_tedwards.Point) {
 	//
 	// verify ChangeNoteCommitment
 	//
-	change := api.Sub(cc.Balance, cc.Amount, cc.Fee)
+	change := api.Sub(cc.totalInput(api), cc.Amount, cc.Fee, cc.PublicOut)
 
 	// change가 0인지 확인
 	isZero := api.IsZero(change)
 
 	// change > 0인 경우 거스름돈 노트 해시 계산
 	hasher.Reset()
-	hasher.Write(cc.NoteVer, cc.FromPub.A.X, cc.FromPub.A.Y, change, cc.Salt0)
+	hasher.Write(cc.NoteVer, pk.X, pk.Y, change, cc.Salt2)
 	changeNoteC := hasher.Sum()
 
 	// change가 0 이면 `calculatedCommitment`는 무조건 `0`,
@@ -259,24 +273,14 @@ func (cc *ZKCircuit) GetCurveId() ecc_tedwards.ID {
 	return cc.curveID
 }
 
-func (cc *ZKCircuit) AssignPrivKey(sk0, sk1 []byte) {
-	cc.FromPrv0, cc.FromPrv1 = sk0, sk1
-}
-
-func (cc *ZKCircuit) AssignTransfer(n *Note) {
-	cc.NoteVer = n.Version
-	cc.FromPub.Assign(cc.curveID, n.PubKey.Bytes())
-	cc.Balance = n.Balance
-	cc.Salt0 = n.Salt
-	cc.NoteCommitment = n.Commitment()
-}
-
 func CompileCircuit(depth int) (constraint.ConstraintSystem, plonk.ProvingKey, plonk.VerifyingKey) {
 	var err error
 	var cc ZKCircuit
 
 	cc.curveID = ecc_tedwards.BN254
-	cc.NoteMerklePath = make([]frontend.Variable, depth+1)
+	for i := range cc.Inputs {
+		cc.Inputs[i].NoteMerklePath = make([]frontend.Variable, depth+1)
+	}
 	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &cc)
 	if err != nil {
 		panic(err)
@@ -0,0 +1,142 @@
+package types
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/consensys/gnark-crypto/signature"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/holiman/uint256"
+	"github.com/kysee/zkp/zk-asset/crypto"
+)
+
+// CompactNoteCiphertextSize is the size of the ciphertext prefix kept in a CompactOutput.
+// It covers the RLP list header (at most 9 bytes), the version (1 byte),
+// the balance (at most 33 bytes) and the salt (33 bytes) of a SharedNote, but not the memo.
+const CompactNoteCiphertextSize = 9 + 1 + 33 + 33
+
+// CompactOutput is the part of a new note that a light wallet needs to find it.
+type CompactOutput struct {
+	Commitment NoteCommitment `json:"commitment"`
+	// EphemeralKey is the ECDHE public key of the secret note.
+	EphemeralKey []byte `json:"ephemeralKey"`
+	// CiphertextPrefix is the first `CompactNoteCiphertextSize` bytes of the ciphertext of the secret note.
+	CiphertextPrefix []byte `json:"ciphertextPrefix"`
+}
+
+// CompactTx is the compact representation of a ZKTx for light wallets.
+// It has no proof and no full ciphertexts.
+type CompactTx struct {
+	TxIdx      int             `json:"txIdx"`
+	Nullifiers []NoteNullifier `json:"nullifiers"`
+	// Outputs has the new notes added to the note commitment tree, in the order of the tree.
+	Outputs []*CompactOutput `json:"outputs"`
+}
+
+// CompactBlock is a range of consecutive CompactTxs.
+type CompactBlock struct {
+	// FromTxIdx is the index of the first tx.
+	FromTxIdx int `json:"fromTxIdx"`
+	// FromCommitmentIdx is the leaf index of the first output of the first tx.
+	FromCommitmentIdx int          `json:"fromCommitmentIdx"`
+	Txs               []*CompactTx `json:"txs"`
+}
+
+// NewCompactTx returns the compact representation of the zktx at `txIdx`.
+func NewCompactTx(zktx *ZKTx, txIdx int) *CompactTx {
+	ctx := &CompactTx{TxIdx: txIdx}
+	for _, nf := range zktx.Nullifiers {
+		if len(nf) > 0 {
+			ctx.Nullifiers = append(ctx.Nullifiers, nf)
+		}
+	}
+	for i, cm := range zktx.NewNoteCommitments {
+		// an empty commitment is not added to the tree.
+		if len(cm) == 0 {
+			continue
+		}
+		out := &CompactOutput{Commitment: cm}
+		if i < len(zktx.NewSecretNotes) && len(zktx.NewSecretNotes[i]) > 32 {
+			sn := zktx.NewSecretNotes[i]
+			out.EphemeralKey = sn[:32]
+			out.CiphertextPrefix = sn[32:min(len(sn), 32+CompactNoteCiphertextSize)]
+		}
+		ctx.Outputs = append(ctx.Outputs, out)
+	}
+	return ctx
+}
+
+// DecryptCompactOutput recovers the version, the balance and the salt of the note in `out`
+// if it is encrypted for `myPrivKey`, or returns `ErrNotMyNote`. The memo of the returned note is nil.
+//
+// The ciphertext prefix is not authenticated,
+// so the recovered note is accepted only if it matches the note commitment in `out`.
+func DecryptCompactOutput(out *CompactOutput, myPrivKey signature.Signer) (*SharedNote, error) {
+	if len(out.EphemeralKey) != 32 || len(out.CiphertextPrefix) == 0 {
+		return nil, ErrNotMyNote
+	}
+	tmpPubKey := crypto.NewPub()
+	if _, err := tmpPubKey.SetBytes(out.EphemeralKey); err != nil {
+		return nil, ErrNotMyNote
+	}
+	sharedSecret, err := crypto.ECDHSharedSecret(myPrivKey, tmpPubKey)
+	if err != nil {
+		return nil, ErrNotMyNote
+	}
+	encKey, nonce, err := noteEncKey(sharedSecret, out.EphemeralKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := crypto.ChaCha20_DecryptPrefix(encKey, nonce, out.CiphertextPrefix, len(out.CiphertextPrefix))
+	if err != nil {
+		return nil, err
+	}
+	if !isSharedNotePrefix(plaintext) {
+		return nil, ErrNotMyNote
+	}
+
+	sn, err := decodeSharedNotePrefix(plaintext)
+	if err != nil {
+		return nil, ErrNotMyNote
+	}
+	if !bytes.Equal(sn.ToNoteOf(myPrivKey.Public()).Commitment(), out.Commitment) {
+		return nil, ErrNotMyNote
+	}
+	return sn, nil
+}
+
+// decodeSharedNotePrefix decodes the fields before the memo from the truncated RLP encoding of a SharedNote.
+func decodeSharedNotePrefix(plaintext []byte) (*SharedNote, error) {
+	// io.MultiReader hides the length of the input from the stream,
+	// so the stream does not reject the list which is longer than the truncated input.
+	s := rlp.NewStream(io.MultiReader(bytes.NewReader(plaintext)), 0)
+	if _, err := s.List(); err != nil {
+		return nil, err
+	}
+	version, err := s.Uint8()
+	if err != nil {
+		return nil, err
+	}
+	balanceBig, err := s.BigInt()
+	if err != nil {
+		return nil, err
+	}
+	balance, overflow := uint256.FromBig(balanceBig)
+	if overflow {
+		return nil, errors.New("balance value overflows uint256")
+	}
+	salt, err := s.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	if len(salt) != 32 {
+		return nil, fmt.Errorf("wrong salt size: %d", len(salt))
+	}
+	return &SharedNote{
+		Version: version,
+		Balance: balance,
+		Salt:    salt,
+	}, nil
+}
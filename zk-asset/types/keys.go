@@ -0,0 +1,112 @@
+package types
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/twistededwards/eddsa"
+	"github.com/consensys/gnark-crypto/signature"
+	"github.com/kysee/zkp/utils"
+	"github.com/kysee/zkp/zk-asset/crypto"
+)
+
+// The key hierarchy of an account, a simplified one of Sapling.
+//
+//	ask             spending key; a Jubjub private key kept by the signer
+//	ak  = ask*G     spend authorizing key
+//	nk  = H(ask)    nullifier key
+//	ivk = H(ak, nk) incoming viewing key; it decrypts the notes
+//	pk  = ivk*G     the public key of the address; the owner of the notes
+//
+// A prover gets only `ak` and `nk`, the ProofAuthorizingKey.
+// It can prove the spending of the notes, but can not make the spend authorizing signature
+// which is made by `rsk = ask + alpha` and verified with `rk = ak + alpha*G`.
+
+// ProofAuthorizingKey is the key with which a prover proves the spending of the notes of an account.
+type ProofAuthorizingKey struct {
+	Ak signature.PublicKey
+	Nk []byte
+}
+
+// NewProofAuthorizingKey derives the proof authorizing key of the spending key `ask`.
+func NewProofAuthorizingKey(ask signature.Signer) *ProofAuthorizingKey {
+	s := ask.Bytes()[32:64]
+	return &ProofAuthorizingKey{
+		Ak: ask.Public(),
+		Nk: utils.DefaultHashSum(s[:16], s[16:32]),
+	}
+}
+
+// ProofAuthorizingKeyFromBytes restores the key serialized by `Bytes()`.
+func ProofAuthorizingKeyFromBytes(bz []byte) (*ProofAuthorizingKey, error) {
+	if len(bz) != 64 {
+		return nil, fmt.Errorf("wrong proof authorizing key size: %d", len(bz))
+	}
+	ak := crypto.NewPub()
+	if _, err := ak.SetBytes(bz[:32]); err != nil {
+		return nil, err
+	}
+	return &ProofAuthorizingKey{
+		Ak: ak,
+		Nk: append([]byte(nil), bz[32:]...),
+	}, nil
+}
+
+// Bytes returns the compressed `ak` followed by `nk`.
+func (k *ProofAuthorizingKey) Bytes() []byte {
+	return append(k.Ak.Bytes(), k.Nk...)
+}
+
+// IncomingViewingKey returns `ivk` as a Jubjub private key, whose public key is `pk`.
+// It decrypts the notes sent to the address of the account.
+func (k *ProofAuthorizingKey) IncomingViewingKey() signature.Signer {
+	ivk := k.ivk()
+	ivkKey, err := crypto.KeyFromScalar(new(big.Int).SetBytes(ivk), utils.DefaultHashSum(ivk, []byte("ivk")))
+	if err != nil {
+		// `KeyFromScalar` fails only for a wrong random source size.
+		panic(err)
+	}
+	return ivkKey
+}
+
+// PublicKey returns `pk`, the public key of the address of the account.
+func (k *ProofAuthorizingKey) PublicKey() signature.PublicKey {
+	return k.IncomingViewingKey().Public()
+}
+
+func (k *ProofAuthorizingKey) ivk() []byte {
+	ak := k.Ak.(*eddsa.PublicKey)
+	ax := ak.A.X.Bytes()
+	ay := ak.A.Y.Bytes()
+	return utils.DefaultHashSum(ax[:], ay[:], k.Nk)
+}
+
+// RandomizedKey returns `rk = ak + alpha*G`.
+func (k *ProofAuthorizingKey) RandomizedKey(alpha []byte) signature.PublicKey {
+	return RandomizeKey(k.Ak, alpha)
+}
+
+// RandomizeKey returns `pub + alpha*G`.
+func RandomizeKey(pub signature.PublicKey, alpha []byte) signature.PublicKey {
+	_pub := pub.(*eddsa.PublicKey)
+	alphaPub := crypto.PubFromScalar(new(big.Int).SetBytes(alpha)).(*eddsa.PublicKey)
+
+	rk := new(eddsa.PublicKey)
+	rk.A.Add(&_pub.A, &alphaPub.A)
+	return rk
+}
+
+// RandomizeSpendingKey returns `rsk = ask + alpha`, the key of `RandomizeKey(ask.Public(), alpha)`.
+func RandomizeSpendingKey(ask signature.Signer, alpha []byte) (signature.Signer, error) {
+	rsk := new(big.Int).Add(crypto.KeyScalar(ask), new(big.Int).SetBytes(alpha))
+	return crypto.KeyFromScalar(rsk, utils.DefaultHashSum(ask.Bytes()[64:], alpha))
+}
+
+// NewAlpha returns a random scalar to randomize the spend authorizing key.
+func NewAlpha() []byte {
+	alpha, err := crypto.RandScalar()
+	if err != nil {
+		panic(err)
+	}
+	return alpha.FillBytes(make([]byte, 32))
+}
@@ -0,0 +1,48 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/consensys/gnark-crypto/hash"
+	"github.com/kysee/zkp/zk-asset/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProofAuthorizingKey(t *testing.T) {
+	ask, err := crypto.NewKey()
+	require.NoError(t, err)
+	pak := NewProofAuthorizingKey(ask)
+
+	restored, err := ProofAuthorizingKeyFromBytes(pak.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, pak.Bytes(), restored.Bytes())
+	require.True(t, pak.PublicKey().Equal(restored.PublicKey()))
+
+	// the address is not the public key of the spending key.
+	require.False(t, pak.PublicKey().Equal(ask.Public()))
+	require.True(t, pak.PublicKey().Equal(pak.IncomingViewingKey().Public()))
+
+	_, err = ProofAuthorizingKeyFromBytes(pak.Bytes()[1:])
+	require.Error(t, err)
+}
+
+func TestRandomizeSpendingKey(t *testing.T) {
+	ask, err := crypto.NewKey()
+	require.NoError(t, err)
+	pak := NewProofAuthorizingKey(ask)
+	alpha := NewAlpha()
+
+	rsk, err := RandomizeSpendingKey(ask, alpha)
+	require.NoError(t, err)
+	rk := pak.RandomizedKey(alpha)
+	require.True(t, rk.Equal(rsk.Public()))
+	require.False(t, rk.Equal(ask.Public()))
+
+	msg := RandBytes(32)
+	msg[0] = 0 // a field element
+	sig, err := rsk.Sign(msg, hash.MIMC_BN254.New())
+	require.NoError(t, err)
+	ok, err := rk.Verify(sig, msg, hash.MIMC_BN254.New())
+	require.NoError(t, err)
+	require.True(t, ok)
+}
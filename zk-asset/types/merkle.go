@@ -0,0 +1,76 @@
+package types
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/consensys/gnark-crypto/accumulator/merkletree"
+	"github.com/kysee/zkp/utils"
+)
+
+// NoteCommitmentMerkleProof builds the Merkle proof of `commitment` over the ordered list of `commitments`.
+// The verifier and light wallets that mirror the commitment list share this function,
+// so both sides always agree on the tree layout.
+func NoteCommitmentMerkleProof(commitments []NoteCommitment, commitment NoteCommitment) (root []byte, proofSet [][]byte, idx, numLeaves uint64, err error) {
+	var buf bytes.Buffer
+	found := false
+	for i, c := range commitments {
+		if !found && bytes.Equal(c, commitment) {
+			idx = uint64(i)
+			found = true
+		}
+		buf.Write(c)
+	}
+	if !found {
+		err = errors.New("commitment not found")
+		return
+	}
+	root, proofSet, numLeaves, err = merkletree.BuildReaderProof(
+		&buf,
+		utils.DefaultHasher(),
+		utils.DefaultHasher().Size(),
+		idx,
+	)
+	return
+}
+
+// NoteCommitmentMerklePathIndex returns the directions of the Merkle proof of the leaf at `idx`
+// in the tree of `numLeaves` leaves, as the bits of an integer from the leaf to the root;
+// the bit i is 1 if the node at the level i is the right child.
+//
+// The tree is not balanced unless `numLeaves` is a power of 2.
+// It consists of the full subtrees of the sizes of the bits of `numLeaves`, from the largest on the left,
+// so the directions are not the bits of `idx` for the leaves outside the largest subtree.
+func NoteCommitmentMerklePathIndex(idx, numLeaves uint64) (uint64, error) {
+	if idx >= numLeaves {
+		return 0, fmt.Errorf("the leaf index is out of range: idx(%d), numLeaves(%d)", idx, numLeaves)
+	}
+
+	start, left := uint64(0), 0
+	for h := 63; h >= 0; h-- {
+		size := uint64(1) << h
+		if numLeaves&size == 0 {
+			continue
+		}
+		if idx >= start+size {
+			start += size
+			left++
+			continue
+		}
+
+		// 1. in the full subtree, the directions are the bits of the offset.
+		ret := idx - start
+		level := h
+		// 2. the smaller subtrees on the right are merged first, and the subtree is the left child of it.
+		if start+size < numLeaves {
+			level++
+		}
+		// 3. the subtree is the right child of each larger subtree on the left.
+		for i := 0; i < left; i++ {
+			ret |= 1 << (level + i)
+		}
+		return ret, nil
+	}
+	return 0, errors.New("unreachable")
+}
@@ -0,0 +1,46 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/kysee/zkp/utils"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNoteCommitmentMerklePathIndex recomputes the root from every Merkle proof
+// taking the directions from NoteCommitmentMerklePathIndex, as the circuit does.
+func TestNoteCommitmentMerklePathIndex(t *testing.T) {
+	var commitments []NoteCommitment
+	for numLeaves := uint64(1); numLeaves <= 40; numLeaves++ {
+		commitments = append(commitments, utils.DefaultHashSum(RandBytes(32)))
+
+		for idx := uint64(0); idx < numLeaves; idx++ {
+			root, proofSet, _idx, _numLeaves, err := NoteCommitmentMerkleProof(commitments, commitments[idx])
+			require.NoError(t, err)
+			require.Equal(t, idx, _idx)
+			require.Equal(t, numLeaves, _numLeaves)
+
+			pathIdx, err := NoteCommitmentMerklePathIndex(idx, numLeaves)
+			require.NoError(t, err)
+			require.Less(t, pathIdx, uint64(1)<<(len(proofSet)-1))
+
+			sum := utils.DefaultHashSum(proofSet[0])
+			for i := 1; i < len(proofSet); i++ {
+				if pathIdx&(1<<(i-1)) != 0 {
+					sum = utils.DefaultHashSum(proofSet[i], sum)
+				} else {
+					sum = utils.DefaultHashSum(sum, proofSet[i])
+				}
+			}
+			require.Equal(t, root, sum, "numLeaves=%d, idx=%d", numLeaves, idx)
+
+			// the directions are the bits of the index in a full tree.
+			if numLeaves&(numLeaves-1) == 0 {
+				require.Equal(t, idx, pathIdx)
+			}
+		}
+	}
+
+	_, err := NoteCommitmentMerklePathIndex(3, 3)
+	require.Error(t, err)
+}
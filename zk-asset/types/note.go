@@ -2,7 +2,9 @@ package types
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"math/big"
 
 	"github.com/consensys/gnark-crypto/ecc/bn254/twistededwards/eddsa"
@@ -13,6 +15,15 @@ import (
 	"github.com/kysee/zkp/zk-asset/crypto"
 )
 
+// NoteVersion is the version of the notes created by this package.
+const NoteVersion = 1
+
+// MemoSize is the size of the memo in an encrypted note.
+// Every memo is padded with zeros to this size, so the size of a secret note does not reveal the memo length.
+const MemoSize = 512
+
+var ErrMemoTooLong = fmt.Errorf("memo is longer than %d bytes", MemoSize)
+
 type NoteCommitment = []byte
 type NoteNullifier = []byte
 type SecretNote = []byte
@@ -47,20 +58,24 @@ func (n *Note) Commitment() []byte {
 	//saltElem.SetBytes(n.Salt)
 	//saltBytes := saltElem.Bytes()
 
+	// `Bytes()` of zero is empty, which would be skipped by the hasher; the circuit hashes it as 0.
+	balance := n.Balance.Bytes()
+	if len(balance) == 0 {
+		balance = []byte{0}
+	}
+
 	h := utils.DefaultHashSum(
 		[]byte{n.Version},
 		ax[:],
 		ay[:],
-		n.Balance.Bytes(),
+		balance,
 		n.Salt) //saltBytes[:])
 	return h
 }
 
-func (n *Note) Nullifier(sk0, sk1 []byte) []byte {
-	// Step 1: Nullifier key generation
-	nk := utils.DefaultHashSum(sk0, sk1)
-
-	// Step 2: Nullifier 계산
+// Nullifier returns the nullifier of the note spent with the nullifier key `nk`.
+// See `ProofAuthorizingKey`.
+func (n *Note) Nullifier(nk []byte) []byte {
 	// nf = Hash(nk, note_commitment)
 	return utils.DefaultHashSum(
 		nk,
@@ -90,6 +105,7 @@ type SharedNote struct {
 	Salt []byte
 
 	// Memo is an arbitrary message field that can be included in the transaction.
+	// It is at most `MemoSize` bytes. The trailing zeros of a memo are lost by the padding.
 	Memo []byte
 }
 
@@ -106,17 +122,24 @@ func (sn *SharedNote) Bytes() []byte {
 }
 
 // EncodeRLP encodes the SharedNote into RLP format.
+// The memo is padded with zeros to `MemoSize` bytes.
 // This method implements the rlp.Encoder interface.
-func (sn *SharedNote) EncodeRLP(w *bytes.Buffer) error {
+func (sn *SharedNote) EncodeRLP(w io.Writer) error {
+	if len(sn.Memo) > MemoSize {
+		return ErrMemoTooLong
+	}
 	// Convert Balance to *big.Int for encoding, as rlp has built-in support for it.
 	balanceBig := sn.Balance.ToBig()
 
+	memo := make([]byte, MemoSize)
+	copy(memo, sn.Memo)
+
 	// Encode fields in order into a slice for rlp.Encode.
 	return rlp.Encode(w, []interface{}{
 		sn.Version,
 		balanceBig,
 		sn.Salt,
-		sn.Memo,
+		memo,
 	})
 }
 
@@ -141,10 +164,15 @@ func (sn *SharedNote) DecodeRLP(s *rlp.Stream) error {
 		return fmt.Errorf("balance value overflows uint256")
 	}
 
+	// the memo is always padded, so a memo of another size is not made by `EncodeRLP`.
+	if len(temp.Memo) != MemoSize {
+		return fmt.Errorf("wrong memo size: %d", len(temp.Memo))
+	}
+
 	sn.Version = temp.Version
 	sn.Balance = balance
 	sn.Salt = temp.Salt
-	sn.Memo = temp.Memo
+	sn.Memo = bytes.TrimRight(temp.Memo, "\x00")
 
 	return nil
 }
@@ -158,31 +186,50 @@ func (sn *SharedNote) ToNoteOf(pubKey signature.PublicKey) *Note {
 	}
 }
 
-func (sn *SharedNote) Encrypt(sharedKey, ad []byte) (SecretNote, error) {
-	saplingKDF, err := crypto.SaplingKDF(sharedKey, 44)
+// Encrypt encrypts the note with the key derived from the ECDH shared secret and the ephemeral public key `epk`.
+// The ciphertext is bound to `epk` and the note commitment `cm` as the associated data.
+func (sn *SharedNote) Encrypt(sharedSecret, epk []byte, cm NoteCommitment) ([]byte, error) {
+	if len(sn.Memo) > MemoSize {
+		return nil, ErrMemoTooLong
+	}
+	encKey, nonce, err := noteEncKey(sharedSecret, epk)
 	if err != nil {
 		return nil, err
 	}
-	encKey := saplingKDF[:32]
-	nonce := saplingKDF[32:44]
-
-	return crypto.ChaCha20Poly1305_Encrypt(encKey, nonce, sn.Bytes(), ad)
+	return crypto.ChaCha20Poly1305_Encrypt(encKey, nonce, sn.Bytes(), noteAD(epk, cm))
 }
 
-func (sn *SharedNote) Decrypt(sharedKey, ciphertext, ad []byte) error {
-	saplingKDF, err := crypto.SaplingKDF(sharedKey, 44)
+// Decrypt decrypts the ciphertext made by `Encrypt` into the note.
+func (sn *SharedNote) Decrypt(sharedSecret, epk, ciphertext []byte, cm NoteCommitment) error {
+	encKey, nonce, err := noteEncKey(sharedSecret, epk)
 	if err != nil {
 		return err
 	}
-	encKey := saplingKDF[:32]
-	nonce := saplingKDF[32:44]
 
-	plaintext, err := crypto.ChaCha20Poly1305_Decrypt(encKey, nonce, ciphertext, ad)
+	plaintext, err := crypto.ChaCha20Poly1305_Decrypt(encKey, nonce, ciphertext, noteAD(epk, cm))
+	if err != nil {
+		return err
+	}
 	return rlp.DecodeBytes(plaintext, sn)
 }
 
-// EncryptSharedNote encrypts a SharedNote and returns the ciphertext and temporarily public key
-func EncryptSharedNote(shared *SharedNote, ad []byte, receiverPubKey signature.PublicKey) (SecretNote, error) {
+// noteEncKey derives the encryption key and the nonce of a secret note from the ECDH shared secret and the ephemeral public key.
+func noteEncKey(sharedSecret, epk []byte) ([]byte, []byte, error) {
+	saplingKDF, err := crypto.SaplingKDF(crypto.KDFSeed(sharedSecret, epk), 44)
+	if err != nil {
+		return nil, nil, err
+	}
+	return saplingKDF[:32], saplingKDF[32:44], nil
+}
+
+// noteAD returns the associated data of the encryption of a note.
+func noteAD(epk []byte, cm NoteCommitment) []byte {
+	return append(append([]byte(nil), epk...), cm...)
+}
+
+// EncryptSharedNote encrypts a SharedNote sent to `receiverPubKey` and returns the ephemeral public key followed by the ciphertext.
+// The ciphertext is bound to the commitment of the note owned by `receiverPubKey`.
+func EncryptSharedNote(shared *SharedNote, receiverPubKey signature.PublicKey) (SecretNote, error) {
 	// Encrypt the SharedNote
 	tmpKey, err := crypto.NewKey()
 	if err != nil {
@@ -193,20 +240,110 @@ func EncryptSharedNote(shared *SharedNote, ad []byte, receiverPubKey signature.P
 		return nil, err
 	}
 
-	ciphertext, err := shared.Encrypt(sharedSecret, ad)
+	epk := tmpKey.Public().Bytes()
+	ciphertext, err := shared.Encrypt(sharedSecret, epk, shared.ToNoteOf(receiverPubKey).Commitment())
+	if err != nil {
+		return nil, err
+	}
+	return append(epk, ciphertext...), nil
+}
+
+// ErrNoteCommitmentMismatch is returned when a decrypted note is not the one of the note commitment.
+var ErrNoteCommitmentMismatch = errors.New("the decrypted note does not match the note commitment")
+
+// DecryptSharedNote decrypts the secret note of the note commitment `cm` with `myPrivKey`.
+// The decrypted note is checked against `cm`.
+func DecryptSharedNote(secretNote SecretNote, cm NoteCommitment, myPrivKey signature.Signer) (*SharedNote, error) {
+	if len(secretNote) <= 32 {
+		return nil, fmt.Errorf("too short secret note: %d", len(secretNote))
+	}
+	epk, ciphertext := secretNote[:32], secretNote[32:]
+	tmpPubKey := crypto.NewPub()
+	if _, err := tmpPubKey.SetBytes(epk); err != nil {
+		return nil, fmt.Errorf("wrong ephemeral key: %w", err)
+	}
+	sharedSecret, err := crypto.ECDHSharedSecret(myPrivKey, tmpPubKey)
 	if err != nil {
 		return nil, err
 	}
-	return append(tmpKey.Public().Bytes(), ciphertext...), nil
+
+	sn := &SharedNote{}
+	if err := sn.Decrypt(sharedSecret, epk, ciphertext, cm); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(sn.ToNoteOf(myPrivKey.Public()).Commitment(), cm) {
+		return nil, ErrNoteCommitmentMismatch
+	}
+	return sn, nil
 }
 
-func DecryptSharedNote(secretNote SecretNote, ad []byte, myPrivKey signature.Signer) (*SharedNote, error) {
-	bzSenderPubKey, ciphertext := secretNote[:32], secretNote[32:]
+// ErrNotMyNote is returned by `TrialDecryptSharedNote` when the secret note is not encrypted for the key.
+var ErrNotMyNote = errors.New("not my note")
+
+// notePlaintextPrefixSize is the number of plaintext bytes checked before the authenticated decryption.
+// It covers the RLP list header (at most 9 bytes), the version and the first byte of the balance.
+const notePlaintextPrefixSize = 11
+
+// TrialDecryptSharedNote decrypts the secret note of the note commitment `cm`
+// if it is encrypted for `myPrivKey`, or returns `ErrNotMyNote`.
+//
+// Before the authenticated decryption, it decrypts only the first bytes of the ciphertext
+// and checks that they look like the beginning of a `SharedNote`.
+// This rejects almost all secret notes of others at the cost of one ChaCha20 block.
+func TrialDecryptSharedNote(secretNote SecretNote, cm NoteCommitment, myPrivKey signature.Signer) (*SharedNote, error) {
+	if len(secretNote) <= 32 {
+		return nil, ErrNotMyNote
+	}
+	epk, ciphertext := secretNote[:32], secretNote[32:]
 	tmpPubKey := crypto.NewPub()
-	tmpPubKey.SetBytes(bzSenderPubKey)
+	if _, err := tmpPubKey.SetBytes(epk); err != nil {
+		return nil, ErrNotMyNote
+	}
 	sharedSecret, err := crypto.ECDHSharedSecret(myPrivKey, tmpPubKey)
+	if err != nil {
+		return nil, ErrNotMyNote
+	}
+
+	encKey, nonce, err := noteEncKey(sharedSecret, epk)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := crypto.ChaCha20_DecryptPrefix(encKey, nonce, ciphertext, notePlaintextPrefixSize)
+	if err != nil {
+		return nil, err
+	}
+	if !isSharedNotePrefix(prefix) {
+		return nil, ErrNotMyNote
+	}
 
 	sn := &SharedNote{}
-	err = sn.Decrypt(sharedSecret, ciphertext, ad)
-	return sn, err
+	if err := sn.Decrypt(sharedSecret, epk, ciphertext, cm); err != nil {
+		return nil, ErrNotMyNote
+	}
+	if !bytes.Equal(sn.ToNoteOf(myPrivKey.Public()).Commitment(), cm) {
+		return nil, ErrNotMyNote
+	}
+	return sn, nil
+}
+
+// isSharedNotePrefix reports whether `prefix` can be the beginning of an RLP encoded `SharedNote`:
+// a list header, the known version and the header of a balance of at most 32 bytes.
+func isSharedNotePrefix(prefix []byte) bool {
+	if len(prefix) == 0 || prefix[0] < 0xc0 {
+		return false
+	}
+	offset := 1
+	if prefix[0] > 0xf7 {
+		// long list: the header is followed by the length of the payload
+		offset += int(prefix[0] - 0xf7)
+	}
+	if len(prefix) < offset+2 {
+		return false
+	}
+	// a single byte smaller than 0x80 is encoded as itself.
+	if prefix[offset] != NoteVersion {
+		return false
+	}
+	// the balance is a single byte or a string of at most 32 bytes.
+	return prefix[offset+1] <= 0xa0
 }
@@ -0,0 +1,84 @@
+package types
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/holiman/uint256"
+	"github.com/kysee/zkp/zk-asset/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSharedNote_Memo(t *testing.T) {
+	key, err := crypto.NewKey()
+	require.NoError(t, err)
+
+	var secretNotes []SecretNote
+	for _, memo := range [][]byte{nil, []byte("hello"), bytes.Repeat([]byte{'m'}, MemoSize)} {
+		sn := &SharedNote{Version: NoteVersion, Balance: uint256.NewInt(7), Salt: RandBytes(32), Memo: memo}
+		secretNote, err := EncryptSharedNote(sn, key.Public())
+		require.NoError(t, err)
+		secretNotes = append(secretNotes, secretNote)
+
+		decrypted, err := TrialDecryptSharedNote(secretNote, sn.ToNoteOf(key.Public()).Commitment(), key)
+		require.NoError(t, err)
+		require.Equal(t, sn.Balance, decrypted.Balance)
+		require.Equal(t, len(memo), len(decrypted.Memo))
+		require.Equal(t, string(memo), string(decrypted.Memo))
+	}
+	// the size of a secret note does not depend on its memo.
+	require.Len(t, secretNotes[1], len(secretNotes[0]))
+	require.Len(t, secretNotes[2], len(secretNotes[0]))
+
+	// too long memo
+	sn := &SharedNote{Version: NoteVersion, Balance: uint256.NewInt(7), Salt: RandBytes(32), Memo: make([]byte, MemoSize+1)}
+	_, err = EncryptSharedNote(sn, key.Public())
+	require.ErrorIs(t, err, ErrMemoTooLong)
+
+	// the memo which is not padded is rejected.
+	bz, err := rlp.EncodeToBytes([]interface{}{byte(NoteVersion), uint256.NewInt(7).ToBig(), RandBytes(32), []byte("hello")})
+	require.NoError(t, err)
+	require.ErrorContains(t, rlp.DecodeBytes(bz, &SharedNote{}), "wrong memo size")
+}
+
+func TestDecryptSharedNote(t *testing.T) {
+	key, err := crypto.NewKey()
+	require.NoError(t, err)
+	sn := &SharedNote{Version: NoteVersion, Balance: uint256.NewInt(7), Salt: RandBytes(32), Memo: []byte("hi")}
+	cm := sn.ToNoteOf(key.Public()).Commitment()
+	secretNote, err := EncryptSharedNote(sn, key.Public())
+	require.NoError(t, err)
+
+	decrypted, err := DecryptSharedNote(secretNote, cm, key)
+	require.NoError(t, err)
+	require.Equal(t, sn.Memo, decrypted.Memo)
+
+	// the ciphertext is bound to the note commitment.
+	otherCm := (&SharedNote{Version: NoteVersion, Balance: uint256.NewInt(8), Salt: sn.Salt}).ToNoteOf(key.Public()).Commitment()
+	_, err = DecryptSharedNote(secretNote, otherCm, key)
+	require.Error(t, err)
+
+	// the ciphertext is bound to the ephemeral key.
+	otherSecretNote, err := EncryptSharedNote(sn, key.Public())
+	require.NoError(t, err)
+	swapped := append(append([]byte(nil), otherSecretNote[:32]...), secretNote[32:]...)
+	_, err = DecryptSharedNote(swapped, cm, key)
+	require.Error(t, err)
+
+	// the wrong ephemeral key is reported, not hidden by the later steps.
+	wrongEpk := append(bytes.Repeat([]byte{0xff}, 32), secretNote[32:]...)
+	_, err = DecryptSharedNote(wrongEpk, cm, key)
+	require.ErrorContains(t, err, "wrong ephemeral key")
+
+	// the note encrypted with the commitment of another note is rejected after the decryption.
+	tmpKey, err := crypto.NewKey()
+	require.NoError(t, err)
+	sharedSecret, err := crypto.ECDHSharedSecret(tmpKey, key.Public())
+	require.NoError(t, err)
+	epk := tmpKey.Public().Bytes()
+	ciphertext, err := sn.Encrypt(sharedSecret, epk, otherCm)
+	require.NoError(t, err)
+	_, err = DecryptSharedNote(append(epk, ciphertext...), otherCm, key)
+	require.ErrorIs(t, err, ErrNoteCommitmentMismatch)
+}
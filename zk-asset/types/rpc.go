@@ -0,0 +1,73 @@
+package types
+
+import "github.com/holiman/uint256"
+
+// The types below are the request and response bodies of the verifier's HTTP+JSON RPC service.
+// Byte slices are encoded as base64 strings by `encoding/json`.
+
+// RPCError is returned with a non-2xx status code when a request fails.
+type RPCError struct {
+	Error string `json:"error"`
+}
+
+// SubmitZKTxResult is the response of `POST /zktx`.
+type SubmitZKTxResult struct {
+	TxIdx int `json:"txIdx"`
+}
+
+// ZKTxsResult is the response of `GET /zktxs?from=&to=`.
+type ZKTxsResult struct {
+	From  int     `json:"from"`
+	ZKTxs []*ZKTx `json:"zktxs"`
+}
+
+// NoteCommitmentsResult is the response of `GET /commitments?from=&to=`.
+type NoteCommitmentsResult struct {
+	From        int              `json:"from"`
+	Commitments []NoteCommitment `json:"commitments"`
+}
+
+// SecretNotesResult is the response of `GET /secretnotes?from=&to=`.
+type SecretNotesResult struct {
+	From        int          `json:"from"`
+	SecretNotes []SecretNote `json:"secretNotes"`
+}
+
+// NullifierResult is the response of `GET /nullifiers/{nullifier}`.
+type NullifierResult struct {
+	Exists bool `json:"exists"`
+}
+
+// MerkleRootResult is the response of `GET /root`.
+type MerkleRootResult struct {
+	Root      []byte `json:"root"`
+	NumLeaves int    `json:"numLeaves"`
+	Depth     int    `json:"depth"`
+}
+
+// TransparentBalanceResult is the response of `GET /transparent/{addr}`.
+type TransparentBalanceResult struct {
+	Balance *uint256.Int `json:"balance"`
+}
+
+// SupplyResult is the response of `GET /supply`.
+// `Total` is `Minted - Burned`, and it equals `ShieldedPool + TransparentPool + PendingFees`.
+type SupplyResult struct {
+	Minted          *uint256.Int `json:"minted"`
+	Burned          *uint256.Int `json:"burned"`
+	Total           *uint256.Int `json:"total"`
+	ShieldedPool    *uint256.Int `json:"shieldedPool"`
+	TransparentPool *uint256.Int `json:"transparentPool"`
+	// PendingFees is the fees not paid to a block producer yet.
+	PendingFees *uint256.Int `json:"pendingFees"`
+}
+
+// VerifyingKeyResult is the response of `GET /vk`.
+type VerifyingKeyResult struct {
+	VerifyingKey []byte `json:"verifyingKey"`
+}
+
+// ProveResult is the response of `POST /prove` of the proving service.
+type ProveResult struct {
+	Proof []byte `json:"proof"`
+}
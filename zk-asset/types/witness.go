@@ -0,0 +1,130 @@
+package types
+
+import (
+	"fmt"
+
+	ecc_tedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
+	"github.com/consensys/gnark/frontend"
+	"github.com/holiman/uint256"
+)
+
+// ZKWitness is the full assignment of `ZKCircuit` in a serializable form.
+// It has no spending key; a prover given only the witness can prove the tx but can not sign it.
+type ZKWitness struct {
+	// Ak, Nk is the proof authorizing key of the spender.
+	Ak    []byte `json:"ak"`
+	Nk    []byte `json:"nk"`
+	Alpha []byte `json:"alpha"`
+	Rk    []byte `json:"rk"`
+
+	NoteVersion byte            `json:"noteVersion"`
+	Depth       int             `json:"depth"`
+	Inputs      []*WitnessInput `json:"inputs"`
+	MerkleRoot  []byte          `json:"merkleRoot"`
+	Nullifiers  [][]byte        `json:"nullifiers"`
+
+	Amount         *uint256.Int `json:"amount"`
+	Fee            *uint256.Int `json:"fee"`
+	ToPubKey       []byte       `json:"toPubKey"`
+	NewNoteSalt    []byte       `json:"newNoteSalt"`
+	ChangeNoteSalt []byte       `json:"changeNoteSalt"`
+
+	NewNoteCommitment    []byte `json:"newNoteCommitment"`
+	ChangeNoteCommitment []byte `json:"changeNoteCommitment"`
+
+	// PublicIn, PublicOut is the value shielded and unshielded by the tx. nil means zero.
+	PublicIn  *uint256.Int `json:"publicIn,omitempty"`
+	PublicOut *uint256.Int `json:"publicOut,omitempty"`
+}
+
+// WitnessInput is an input note of `ZKWitness`.
+type WitnessInput struct {
+	Balance    *uint256.Int `json:"balance"`
+	Salt       []byte       `json:"salt"`
+	Commitment []byte       `json:"commitment"`
+	// PathIdx is the directions of the Merkle proof; see `NoteCommitmentMerklePathIndex`.
+	PathIdx    uint64   `json:"pathIdx"`
+	MerklePath [][]byte `json:"merklePath"`
+}
+
+// Assignment returns the circuit assigned with the witness.
+func (wtn *ZKWitness) Assignment() (*ZKCircuit, error) {
+	if len(wtn.Inputs) != NumInputNotes || len(wtn.Nullifiers) != NumInputNotes {
+		return nil, fmt.Errorf("wrong number of input notes: %d", len(wtn.Inputs))
+	}
+	if wtn.Amount == nil || wtn.Fee == nil {
+		return nil, fmt.Errorf("no amount or fee in the witness")
+	}
+
+	assignment := &ZKCircuit{}
+	assignment.SetCurveId(ecc_tedwards.BN254)
+	if err := assignPubKey(&assignment.Ak, wtn.Ak); err != nil {
+		return nil, fmt.Errorf("wrong ak: %w", err)
+	}
+	if err := assignPubKey(&assignment.Rk, wtn.Rk); err != nil {
+		return nil, fmt.Errorf("wrong rk: %w", err)
+	}
+	if err := assignPubKey(&assignment.ToPub, wtn.ToPubKey); err != nil {
+		return nil, fmt.Errorf("wrong receiver's public key: %w", err)
+	}
+	assignment.Nk = wtn.Nk
+	assignment.Alpha = wtn.Alpha
+	assignment.NoteVer = wtn.NoteVersion
+	assignment.NoteMerkleRoot = wtn.MerkleRoot
+
+	for i, in := range wtn.Inputs {
+		if in == nil || in.Balance == nil {
+			return nil, fmt.Errorf("empty input note: %d", i)
+		}
+		if len(in.MerklePath) > wtn.Depth+1 {
+			return nil, fmt.Errorf("too long Merkle proof: %d", len(in.MerklePath))
+		}
+		_in := &assignment.Inputs[i]
+		_in.Balance = in.Balance.Bytes()
+		_in.Salt = in.Salt
+		_in.NoteCommitment = in.Commitment
+		_in.NoteIdx = in.PathIdx
+
+		// the proof shorter than the depth is padded with zeros.
+		_in.NoteMerklePath = make([]frontend.Variable, wtn.Depth+1)
+		for j := range _in.NoteMerklePath {
+			var v []byte
+			if j < len(in.MerklePath) {
+				v = in.MerklePath[j]
+			} else {
+				v = []byte{0x0}
+			}
+			_in.NoteMerklePath[j] = v
+		}
+		assignment.Nullifiers[i] = wtn.Nullifiers[i]
+	}
+	assignment.Amount = wtn.Amount.Bytes()
+	assignment.Fee = wtn.Fee.Bytes()
+	assignment.Salt1 = wtn.NewNoteSalt
+	assignment.Salt2 = wtn.ChangeNoteSalt
+	assignment.NewNoteCommitment = wtn.NewNoteCommitment
+	assignment.ChangeNoteCommitment = wtn.ChangeNoteCommitment
+	assignment.PublicIn = valueOrZero(wtn.PublicIn).Bytes()
+	assignment.PublicOut = valueOrZero(wtn.PublicOut).Bytes()
+	return assignment, nil
+}
+
+func valueOrZero(v *uint256.Int) *uint256.Int {
+	if v == nil {
+		return uint256.NewInt(0)
+	}
+	return v
+}
+
+func assignPubKey(pub interface {
+	Assign(ecc_tedwards.ID, []byte)
+}, bz []byte) (err error) {
+	// `Assign` panics on a wrong point.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	pub.Assign(ecc_tedwards.BN254, bz)
+	return nil
+}
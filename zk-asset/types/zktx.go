@@ -1,11 +1,51 @@
 package types
 
+import (
+	"errors"
+
+	"github.com/consensys/gnark-crypto/hash"
+	"github.com/holiman/uint256"
+	"github.com/kysee/zkp/utils"
+	"github.com/kysee/zkp/zk-asset/crypto"
+)
+
+var (
+	ErrWrongSpendAuthSig   = errors.New("wrong spend authorizing signature")
+	ErrWrongTransparentSig = errors.New("wrong transparent signature")
+)
+
 type ZKTx struct {
 	ProofBytes         []byte
 	MerkleRoot         []byte
-	Nullifier          NoteNullifier
+	Nullifiers         []NoteNullifier
 	NewNoteCommitments []NoteCommitment
 	NewSecretNotes     []SecretNote
+
+	// Rk is the randomized spend authorizing key, a public input of the proof.
+	Rk []byte
+	// SpendAuthSig is the signature of `SigHash()` by the randomized spending key.
+	SpendAuthSig []byte
+
+	// PublicIn is the value moved from the transparent account `TransparentFrom` into the notes.
+	// `TransparentSig` is the signature of `SigHash()` by the key of the account.
+	// If `TransparentFrom` is empty, the tx mints `PublicIn` and `IssuerSigs` authorizes it.
+	PublicIn        *uint256.Int `json:",omitempty"`
+	TransparentFrom string       `json:",omitempty"`
+	TransparentSig  []byte       `json:",omitempty"`
+	IssuerSigs      []*IssuerSig `json:",omitempty"`
+	// PublicOut is the value moved from the notes to the transparent account `TransparentTo`.
+	// If `TransparentTo` is empty, the tx burns `PublicOut`.
+	PublicOut     *uint256.Int `json:",omitempty"`
+	TransparentTo string       `json:",omitempty"`
+
+	// Fee is paid from the notes to the producer of the block including the tx.
+	Fee *uint256.Int `json:",omitempty"`
+}
+
+// IssuerSig is the signature of `ZKTx.SigHash()` by an issuance key.
+type IssuerSig struct {
+	PubKey []byte
+	Sig    []byte
 }
 
 func NewZKTx() *ZKTx {
@@ -14,3 +54,85 @@ func NewZKTx() *ZKTx {
 		NewSecretNotes:     make([]SecretNote, 2),
 	}
 }
+
+// SigHash returns the message signed by the spend authorizing signature.
+// It covers all fields of the tx except the proof and the signature,
+// so neither the prover nor anyone else can change the tx after it is signed.
+func (tx *ZKTx) SigHash() []byte {
+	ins := [][]byte{tx.MerkleRoot}
+	for _, nf := range tx.Nullifiers {
+		ins = append(ins, utils.DefaultHashSum(nf))
+	}
+	for _, cm := range tx.NewNoteCommitments {
+		ins = append(ins, utils.DefaultHashSum(cm))
+	}
+	for _, sn := range tx.NewSecretNotes {
+		ins = append(ins, utils.DefaultHashSum(sn))
+	}
+	ins = append(ins, tx.Rk)
+	ins = append(ins, tx.GetPublicIn().Bytes(), []byte(tx.TransparentFrom))
+	ins = append(ins, tx.GetPublicOut().Bytes(), []byte(tx.TransparentTo))
+	ins = append(ins, tx.GetFee().Bytes())
+	return utils.DefaultHashSum(ins...)
+}
+
+// Verify verifies the signature with `PubKey`.
+func (is *IssuerSig) Verify(sigHash []byte) bool {
+	pubKey := crypto.NewPub()
+	if _, err := pubKey.SetBytes(is.PubKey); err != nil {
+		return false
+	}
+	ok, err := pubKey.Verify(is.Sig, sigHash, hash.MIMC_BN254.New())
+	return err == nil && ok
+}
+
+// IsMint reports whether the tx mints `PublicIn`.
+func (tx *ZKTx) IsMint() bool {
+	return !tx.GetPublicIn().IsZero() && tx.TransparentFrom == ""
+}
+
+// IsBurn reports whether the tx burns `PublicOut`.
+func (tx *ZKTx) IsBurn() bool {
+	return !tx.GetPublicOut().IsZero() && tx.TransparentTo == ""
+}
+
+// GetPublicIn returns `PublicIn`, or zero if it is nil.
+func (tx *ZKTx) GetPublicIn() *uint256.Int {
+	return valueOrZero(tx.PublicIn)
+}
+
+// GetPublicOut returns `PublicOut`, or zero if it is nil.
+func (tx *ZKTx) GetPublicOut() *uint256.Int {
+	return valueOrZero(tx.PublicOut)
+}
+
+// GetFee returns `Fee`, or zero if it is nil.
+func (tx *ZKTx) GetFee() *uint256.Int {
+	return valueOrZero(tx.Fee)
+}
+
+// VerifyTransparentSig verifies `TransparentSig` with the key of `TransparentFrom`.
+func (tx *ZKTx) VerifyTransparentSig() error {
+	pubKey, err := TransparentAddr2Pub(tx.TransparentFrom)
+	if err != nil {
+		return err
+	}
+	ok, err := pubKey.Verify(tx.TransparentSig, tx.SigHash(), hash.MIMC_BN254.New())
+	if err != nil || !ok {
+		return ErrWrongTransparentSig
+	}
+	return nil
+}
+
+// VerifySpendAuthSig verifies `SpendAuthSig` with `Rk`.
+func (tx *ZKTx) VerifySpendAuthSig() error {
+	rk := crypto.NewPub()
+	if _, err := rk.SetBytes(tx.Rk); err != nil {
+		return ErrWrongSpendAuthSig
+	}
+	ok, err := rk.Verify(tx.SpendAuthSig, tx.SigHash(), hash.MIMC_BN254.New())
+	if err != nil || !ok {
+		return ErrWrongSpendAuthSig
+	}
+	return nil
+}
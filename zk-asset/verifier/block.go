@@ -0,0 +1,58 @@
+package verifier
+
+import (
+	"github.com/holiman/uint256"
+	"github.com/kysee/zkp/zk-asset/types"
+)
+
+var (
+	ledgerBlocks []*types.Block
+
+	// pendingFees is the fees of the txs which are not in a block yet.
+	pendingFees = uint256.NewInt(0)
+)
+
+// SealBlock closes the block of the txs submitted after the last block,
+// and pays their fees to the transparent account `producer`.
+func SealBlock(producer string) (*types.Block, error) {
+	if _, err := types.TransparentAddr2Pub(producer); err != nil {
+		return nil, err
+	}
+
+	ledgerMtx.Lock()
+	defer ledgerMtx.Unlock()
+
+	from := 0
+	if len(ledgerBlocks) > 0 {
+		from = ledgerBlocks[len(ledgerBlocks)-1].ToTxIdx
+	}
+	blk := &types.Block{
+		Height:    len(ledgerBlocks),
+		FromTxIdx: from,
+		ToTxIdx:   len(ledgerZKTx),
+		Fees:      pendingFees,
+		Producer:  producer,
+	}
+	transparentBalances[producer] = new(uint256.Int).Add(getTransparentBalance(producer), pendingFees)
+	pendingFees = uint256.NewInt(0)
+
+	ledgerBlocks = append(ledgerBlocks, blk)
+	return copyBlock(blk), nil
+}
+
+// GetBlock returns the block at `height`, or nil if it does not exist.
+func GetBlock(height int) *types.Block {
+	ledgerMtx.RLock()
+	defer ledgerMtx.RUnlock()
+
+	if height < 0 || height >= len(ledgerBlocks) {
+		return nil
+	}
+	return copyBlock(ledgerBlocks[height])
+}
+
+func copyBlock(blk *types.Block) *types.Block {
+	ret := *blk
+	ret.Fees = blk.Fees.Clone()
+	return &ret
+}
@@ -0,0 +1,66 @@
+package verifier
+
+import (
+	"github.com/holiman/uint256"
+	"github.com/kysee/zkp/zk-asset/types"
+)
+
+// LocalChain serves the ledger of this process to wallets.
+// It implements `prover.ChainSource`.
+type LocalChain struct{}
+
+func NewLocalChain() *LocalChain {
+	return &LocalChain{}
+}
+
+func (*LocalChain) GetZKTxs(from, to int) ([]*types.ZKTx, error) {
+	return GetZKTxs(from, to), nil
+}
+
+func (*LocalChain) GetCompactBlock(from, to int) (*types.CompactBlock, error) {
+	return GetCompactBlock(from, to), nil
+}
+
+func (*LocalChain) GetNoteCommitments(from, to int) ([]types.NoteCommitment, error) {
+	return GetNoteCommitments(from, to), nil
+}
+
+func (*LocalChain) GetSecretNotes(from, to int) ([]types.SecretNote, error) {
+	return GetSecretNotes(from, to), nil
+}
+
+func (*LocalChain) HasNullifier(nullifier types.NoteNullifier) (bool, error) {
+	return FindNoteNullifier(nullifier) != nil, nil
+}
+
+func (*LocalChain) GetMerkleRoot() (*types.MerkleRootResult, error) {
+	ledgerMtx.RLock()
+	defer ledgerMtx.RUnlock()
+
+	return &types.MerkleRootResult{
+		Root:      merkleNoteCommitments.Root(),
+		NumLeaves: len(ledgerNoteCommitments),
+		Depth:     noteMerkleDepth,
+	}, nil
+}
+
+// GetTransparentBalance returns the balance of the transparent account `addr`.
+func (*LocalChain) GetTransparentBalance(addr string) (*uint256.Int, error) {
+	return GetTransparentBalance(addr), nil
+}
+
+// GetSupply returns the total supply and the values of the pools.
+func (*LocalChain) GetSupply() (*types.SupplyResult, error) {
+	return GetSupply(), nil
+}
+
+// GetBlock returns the block at `height`, or nil if it does not exist.
+func (*LocalChain) GetBlock(height int) (*types.Block, error) {
+	return GetBlock(height), nil
+}
+
+// SubmitZKTx verifies the zktx and appends it to the ledger.
+// It returns the index of the zktx in the ledger.
+func (*LocalChain) SubmitZKTx(zktx *types.ZKTx) (int, error) {
+	return submitZKTx(zktx)
+}
@@ -2,11 +2,11 @@ package verifier
 
 import (
 	"bytes"
+	"sync"
 
 	"github.com/consensys/gnark-crypto/accumulator/merkletree"
 	"github.com/consensys/gnark/backend/plonk"
 	"github.com/consensys/gnark/constraint"
-	"github.com/holiman/uint256"
 	"github.com/kysee/zkp/utils"
 	"github.com/kysee/zkp/zk-asset/types"
 )
@@ -21,6 +21,10 @@ var (
 	ZKProvingKey   plonk.ProvingKey
 	ZKVerifyingKey plonk.VerifyingKey
 
+	// ledgerMtx guards all ledger state below.
+	// The ledger is shared between in-process callers and the RPC server.
+	ledgerMtx sync.RWMutex
+
 	merkleNoteCommitments *merkletree.Tree
 	ledgerNoteCommitments []types.NoteCommitment
 	ledgerNoteNullifiers  []types.NoteNullifier
@@ -31,42 +35,6 @@ func init() {
 	ZKCSS, ZKProvingKey, ZKVerifyingKey = types.CompileCircuit(noteMerkleDepth)
 }
 
-func InitMint(addr string, amount *uint256.Int) {
-	// initial minting...
-
-	zktx := types.NewZKTx()
-	salt := types.RandBytes(32)
-
-	pubKey := types.Addr2Pub(addr)
-	note := &types.Note{
-		Version: 1,
-		PubKey:  pubKey,
-		Balance: amount,
-		Salt:    salt,
-	}
-	zktx.NewNoteCommitments[0] = note.Commitment()
-	addNoteCommitment(zktx.NewNoteCommitments[0])
-
-	sharedNote := &types.SharedNote{
-		Version: 1,
-		Balance: amount,
-		Salt:    salt,
-		Memo:    nil,
-	}
-
-	//
-	// Encrypt the SharedNote
-
-	secretNote, err := types.EncryptSharedNote(sharedNote, nil, pubKey)
-	if err != nil {
-		panic(err)
-	}
-	zktx.NewSecretNotes[0] = secretNote
-	addSecretNote(zktx.NewSecretNotes[0])
-
-	addZKTx(zktx)
-}
-
 func addNoteCommitment(commitment types.NoteCommitment) int {
 	//fmt.Printf("addNoteCommitment: %x\n", commitment)
 	ledgerNoteCommitments = append(ledgerNoteCommitments, commitment)
@@ -80,6 +48,13 @@ func addNoteNullifier(nullifier types.NoteNullifier) {
 }
 
 func FindNoteNullifier(nullifier types.NoteNullifier) types.NoteNullifier {
+	ledgerMtx.RLock()
+	defer ledgerMtx.RUnlock()
+
+	return findNoteNullifier(nullifier)
+}
+
+func findNoteNullifier(nullifier types.NoteNullifier) types.NoteNullifier {
 	for _, n := range ledgerNoteNullifiers {
 		if bytes.Equal(n, nullifier) {
 			ret := make([]byte, len(n))
@@ -91,6 +66,9 @@ func FindNoteNullifier(nullifier types.NoteNullifier) types.NoteNullifier {
 }
 
 func VerifyNoteCommitmentProof(commitment types.NoteCommitment, root []byte, idx uint64) bool {
+	ledgerMtx.RLock()
+	defer ledgerMtx.RUnlock()
+
 	// Build the proof from scratch for verification
 	var buf bytes.Buffer
 	for _, c := range ledgerNoteCommitments {
@@ -119,22 +97,94 @@ func addSecretNote(enc []byte) {
 }
 
 func GetSecretNote(idx int) []byte {
+	ledgerMtx.RLock()
+	defer ledgerMtx.RUnlock()
+
 	if idx < len(ledgerSecretNotes) {
 		return ledgerSecretNotes[idx]
 	}
 	return nil
 }
 
+// GetSecretNotes returns the secret notes in the range [from, to).
+// The i-th secret note is paired with the i-th note commitment.
+func GetSecretNotes(from, to int) []types.SecretNote {
+	ledgerMtx.RLock()
+	defer ledgerMtx.RUnlock()
+
+	from, to = clampRange(from, to, len(ledgerSecretNotes))
+	ret := make([]types.SecretNote, 0, to-from)
+	for _, sn := range ledgerSecretNotes[from:to] {
+		ret = append(ret, append([]byte(nil), sn...))
+	}
+	return ret
+}
+
 // for ZKTx
-var ledgerZKTx []*types.ZKTx
+var (
+	ledgerZKTx []*types.ZKTx
+	// ledgerZKTxCommitmentIdx[i] is the leaf index of the first new note commitment of ledgerZKTx[i].
+	ledgerZKTxCommitmentIdx []int
+)
 
-func addZKTx(zkTx *types.ZKTx) {
+// addZKTx should be called after the new note commitments of the zkTx are added.
+func addZKTx(zkTx *types.ZKTx) int {
+	cmtIdx := len(ledgerNoteCommitments)
+	for _, cm := range zkTx.NewNoteCommitments {
+		if len(cm) > 0 {
+			cmtIdx--
+		}
+	}
 	ledgerZKTx = append(ledgerZKTx, zkTx)
+	ledgerZKTxCommitmentIdx = append(ledgerZKTxCommitmentIdx, cmtIdx)
+	return len(ledgerZKTx) - 1
 }
 
 func GetZKTx(idx int) *types.ZKTx {
+	ledgerMtx.RLock()
+	defer ledgerMtx.RUnlock()
+
 	if idx < len(ledgerZKTx) {
 		return ledgerZKTx[idx]
 	}
 	return nil
 }
+
+// GetZKTxs returns the transactions in the range [from, to).
+func GetZKTxs(from, to int) []*types.ZKTx {
+	ledgerMtx.RLock()
+	defer ledgerMtx.RUnlock()
+
+	from, to = clampRange(from, to, len(ledgerZKTx))
+	ret := make([]*types.ZKTx, to-from)
+	copy(ret, ledgerZKTx[from:to])
+	return ret
+}
+
+// GetZKTxCount returns the number of transactions in the ledger.
+func GetZKTxCount() int {
+	ledgerMtx.RLock()
+	defer ledgerMtx.RUnlock()
+
+	return len(ledgerZKTx)
+}
+
+// GetCompactBlock returns the compact representation of the transactions in the range [from, to).
+func GetCompactBlock(from, to int) *types.CompactBlock {
+	ledgerMtx.RLock()
+	defer ledgerMtx.RUnlock()
+
+	from, to = clampRange(from, to, len(ledgerZKTx))
+	blk := &types.CompactBlock{
+		FromTxIdx:         from,
+		FromCommitmentIdx: len(ledgerNoteCommitments),
+		Txs:               make([]*types.CompactTx, 0, to-from),
+	}
+	if from < to {
+		blk.FromCommitmentIdx = ledgerZKTxCommitmentIdx[from]
+	}
+	for i := from; i < to; i++ {
+		blk.Txs = append(blk.Txs, types.NewCompactTx(ledgerZKTx[i], i))
+	}
+	return blk
+}
@@ -0,0 +1,77 @@
+package verifier
+
+import (
+	"github.com/kysee/zkp/zk-asset/types"
+)
+
+//
+// For Merkle Tree
+//
+
+func GetNoteCommitment(idx int) types.NoteCommitment {
+	ledgerMtx.RLock()
+	defer ledgerMtx.RUnlock()
+
+	ret := make([]byte, len(ledgerNoteCommitments[idx]))
+	copy(ret, ledgerNoteCommitments[idx])
+	return ret
+}
+
+// GetNoteCommitments returns the note commitments in the range [from, to).
+// `to` is clamped to the number of commitments in the ledger.
+func GetNoteCommitments(from, to int) []types.NoteCommitment {
+	ledgerMtx.RLock()
+	defer ledgerMtx.RUnlock()
+
+	from, to = clampRange(from, to, len(ledgerNoteCommitments))
+	ret := make([]types.NoteCommitment, 0, to-from)
+	for _, c := range ledgerNoteCommitments[from:to] {
+		ret = append(ret, append([]byte(nil), c...))
+	}
+	return ret
+}
+
+// GetNoteCommitmentsCount returns the number of leaves in the note commitment tree.
+func GetNoteCommitmentsCount() int {
+	ledgerMtx.RLock()
+	defer ledgerMtx.RUnlock()
+
+	return len(ledgerNoteCommitments)
+}
+
+// GetNoteCommitmentRoot returns the current root hash of the note commitment tree.
+func GetNoteCommitmentRoot() []byte {
+	ledgerMtx.RLock()
+	defer ledgerMtx.RUnlock()
+
+	return merkleNoteCommitments.Root()
+}
+
+func GetNoteCommitmentMerkle(commitment types.NoteCommitment) (root []byte, proofSet [][]byte, depth int, idx, numLeaves uint64, err error) {
+	ledgerMtx.RLock()
+	defer ledgerMtx.RUnlock()
+
+	root, proofSet, idx, numLeaves, err = types.NoteCommitmentMerkleProof(ledgerNoteCommitments, commitment)
+	if err != nil {
+		return
+	}
+	depth = noteMerkleDepth
+	return
+}
+
+func GetNoteCommitmentMerkleDepth() int {
+	return noteMerkleDepth
+}
+
+func clampRange(from, to, n int) (int, int) {
+	if from < 0 {
+		from = 0
+	}
+	if to > n {
+		to = n
+	}
+	if from > to {
+		from = to
+	}
+	return from, to
+}
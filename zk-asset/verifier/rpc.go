@@ -2,50 +2,199 @@ package verifier
 
 import (
 	"bytes"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
 
-	"github.com/consensys/gnark-crypto/accumulator/merkletree"
-	"github.com/kysee/zkp/utils"
 	"github.com/kysee/zkp/zk-asset/types"
 )
 
+// maxRangeSize limits the number of items returned by one range query.
+const maxRangeSize = 1024
+
+// maxRequestBodySize limits the size of a submitted ZKTx.
+const maxRequestBodySize = 1 << 20
+
+// NewRPCHandler returns the HTTP+JSON RPC service of the ledger.
 //
-// For Merkle Tree
-//
+//	POST /zktx                      submit a ZKTx
+//	GET  /zktx/{idx}                get the ZKTx at `idx`
+//	GET  /zktxs?from=&to=           get the ZKTxs in [from, to)
+//	GET  /compactblock?from=&to=    get the compact representation of the ZKTxs in [from, to)
+//	GET  /commitments?from=&to=     get the note commitments in [from, to)
+//	GET  /secretnotes?from=&to=     get the secret notes in [from, to)
+//	GET  /nullifiers/{nullifier}    check whether the hex encoded nullifier exists
+//	GET  /root                      get the current root of the note commitment tree
+//	GET  /transparent/{addr}        get the balance of the transparent account
+//	GET  /supply                    get the total supply and the values of the pools
+//	GET  /block/{height}            get the block at `height`
+//	GET  /vk                        get the verifying key
+func NewRPCHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /zktx", handleSubmitZKTx)
+	mux.HandleFunc("GET /zktx/{idx}", handleGetZKTx)
+	mux.HandleFunc("GET /zktxs", handleGetZKTxs)
+	mux.HandleFunc("GET /compactblock", handleGetCompactBlock)
+	mux.HandleFunc("GET /commitments", handleGetNoteCommitments)
+	mux.HandleFunc("GET /secretnotes", handleGetSecretNotes)
+	mux.HandleFunc("GET /nullifiers/{nullifier}", handleFindNullifier)
+	mux.HandleFunc("GET /root", handleGetRoot)
+	mux.HandleFunc("GET /transparent/{addr}", handleGetTransparentBalance)
+	mux.HandleFunc("GET /supply", handleGetSupply)
+	mux.HandleFunc("GET /block/{height}", handleGetBlock)
+	mux.HandleFunc("GET /vk", handleGetVerifyingKey)
+	return mux
+}
 
-func GetNoteCommitment(idx int) types.NoteCommitment {
-	ret := make([]byte, len(ledgerNoteCommitments[idx]))
-	copy(ret, ledgerNoteCommitments[idx])
-	return ret
+// ListenAndServeRPC serves the RPC service on `addr` until it fails.
+func ListenAndServeRPC(addr string) error {
+	return http.ListenAndServe(addr, NewRPCHandler())
+}
+
+func handleSubmitZKTx(w http.ResponseWriter, r *http.Request) {
+	zktx := &types.ZKTx{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodySize)).Decode(zktx); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	txIdx, err := submitZKTx(zktx)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	writeResult(w, &types.SubmitZKTxResult{TxIdx: txIdx})
 }
 
-func GetNoteCommitmentMerkle(commitment types.NoteCommitment) (root []byte, proofSet [][]byte, depth int, idx, numLeaves uint64, err error) {
-	var buf bytes.Buffer
-	found := false
-	for i, c := range ledgerNoteCommitments {
-		if bytes.Equal(c, commitment) {
-			idx = uint64(i)
-			found = true
-		}
-		buf.Write(c)
+func handleGetZKTx(w http.ResponseWriter, r *http.Request) {
+	idx, err := strconv.Atoi(r.PathValue("idx"))
+	if err != nil || idx < 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("wrong tx index: %s", r.PathValue("idx")))
+		return
 	}
-	if !found {
-		err = errors.New("commitment not found")
+	zktx := GetZKTx(idx)
+	if zktx == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("tx not found: %d", idx))
+		return
+	}
+	writeResult(w, zktx)
+}
+
+func handleGetZKTxs(w http.ResponseWriter, r *http.Request) {
+	from, to, err := parseRange(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeResult(w, &types.ZKTxsResult{From: from, ZKTxs: GetZKTxs(from, to)})
+}
+
+func handleGetCompactBlock(w http.ResponseWriter, r *http.Request) {
+	from, to, err := parseRange(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeResult(w, GetCompactBlock(from, to))
+}
+
+func handleGetNoteCommitments(w http.ResponseWriter, r *http.Request) {
+	from, to, err := parseRange(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeResult(w, &types.NoteCommitmentsResult{From: from, Commitments: GetNoteCommitments(from, to)})
+}
+
+func handleGetSecretNotes(w http.ResponseWriter, r *http.Request) {
+	from, to, err := parseRange(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
 		return
 	}
-	root, proofSet, numLeaves, err = merkletree.BuildReaderProof(
-		&buf,
-		utils.DefaultHasher(),
-		utils.DefaultHasher().Size(),
-		idx,
-	)
+	writeResult(w, &types.SecretNotesResult{From: from, SecretNotes: GetSecretNotes(from, to)})
+}
+
+func handleFindNullifier(w http.ResponseWriter, r *http.Request) {
+	nullifier, err := hex.DecodeString(r.PathValue("nullifier"))
 	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeResult(w, &types.NullifierResult{Exists: FindNoteNullifier(nullifier) != nil})
+}
+
+func handleGetRoot(w http.ResponseWriter, r *http.Request) {
+	ret, _ := NewLocalChain().GetMerkleRoot()
+	writeResult(w, ret)
+}
+
+func handleGetTransparentBalance(w http.ResponseWriter, r *http.Request) {
+	addr := r.PathValue("addr")
+	if _, err := types.TransparentAddr2Pub(addr); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeResult(w, &types.TransparentBalanceResult{Balance: GetTransparentBalance(addr)})
+}
+
+func handleGetSupply(w http.ResponseWriter, r *http.Request) {
+	writeResult(w, GetSupply())
+}
+
+func handleGetBlock(w http.ResponseWriter, r *http.Request) {
+	height, err := strconv.Atoi(r.PathValue("height"))
+	if err != nil || height < 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("wrong block height: %s", r.PathValue("height")))
 		return
 	}
-	depth = noteMerkleDepth
-	return
+	blk := GetBlock(height)
+	if blk == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("block not found: %d", height))
+		return
+	}
+	writeResult(w, blk)
+}
+
+func handleGetVerifyingKey(w http.ResponseWriter, r *http.Request) {
+	buf := bytes.NewBuffer(nil)
+	if _, err := ZKVerifyingKey.WriteTo(buf); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResult(w, &types.VerifyingKeyResult{VerifyingKey: buf.Bytes()})
+}
+
+// parseRange reads the `from` and `to` query parameters.
+// The range is capped at `maxRangeSize` items.
+func parseRange(r *http.Request) (int, int, error) {
+	from, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil {
+		return 0, 0, fmt.Errorf("wrong `from`: %w", err)
+	}
+	to, err := strconv.Atoi(r.URL.Query().Get("to"))
+	if err != nil {
+		return 0, 0, fmt.Errorf("wrong `to`: %w", err)
+	}
+	if from < 0 || to < from {
+		return 0, 0, errors.New("wrong range")
+	}
+	if to-from > maxRangeSize {
+		to = from + maxRangeSize
+	}
+	return from, to, nil
+}
+
+func writeResult(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
 }
 
-func GetNoteCommitmentMerkleDepth() int {
-	return noteMerkleDepth
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(&types.RPCError{Error: err.Error()})
 }
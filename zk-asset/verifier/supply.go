@@ -0,0 +1,103 @@
+package verifier
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/consensys/gnark-crypto/signature"
+	"github.com/holiman/uint256"
+	"github.com/kysee/zkp/zk-asset/types"
+)
+
+// The supply is changed only by the mint and burn txs.
+// Every value minted and not burned is in the notes, in the transparent accounts
+// or in the fees of the txs not in a block yet, which `AuditSupply` checks.
+var (
+	// issuers is the set of the issuance keys, and `issuerThreshold` of them should sign a mint tx.
+	// If it is empty, no tx can mint.
+	issuers         = make(map[string]bool)
+	issuerThreshold int
+
+	mintedSupply = uint256.NewInt(0)
+	burnedSupply = uint256.NewInt(0)
+)
+
+var ErrNotAuthorizedMint = errors.New("the mint is not authorized by the issuers")
+
+// SetIssuers replaces the issuance keys.
+// A mint tx should be signed by `threshold` keys of `pubKeys`.
+func SetIssuers(threshold int, pubKeys ...signature.PublicKey) error {
+	if threshold <= 0 || threshold > len(pubKeys) {
+		return fmt.Errorf("wrong threshold: %d of %d", threshold, len(pubKeys))
+	}
+
+	ledgerMtx.Lock()
+	defer ledgerMtx.Unlock()
+
+	issuers = make(map[string]bool)
+	for _, pubKey := range pubKeys {
+		issuers[string(pubKey.Bytes())] = true
+	}
+	issuerThreshold = threshold
+	return nil
+}
+
+// verifyIssuerSigs checks that `zktx` is signed by enough issuance keys.
+func verifyIssuerSigs(zktx *types.ZKTx) error {
+	if len(issuers) == 0 {
+		return ErrNotAuthorizedMint
+	}
+	sigHash := zktx.SigHash()
+	signed := make(map[string]bool)
+	for _, is := range zktx.IssuerSigs {
+		key := string(is.PubKey)
+		if !issuers[key] || signed[key] {
+			continue
+		}
+		if !is.Verify(sigHash) {
+			return fmt.Errorf("%w: wrong signature", ErrNotAuthorizedMint)
+		}
+		signed[key] = true
+	}
+	if len(signed) < issuerThreshold {
+		return fmt.Errorf("%w: %d of %d signatures", ErrNotAuthorizedMint, len(signed), issuerThreshold)
+	}
+	return nil
+}
+
+// GetSupply returns the total supply and where it is.
+func GetSupply() *types.SupplyResult {
+	ledgerMtx.RLock()
+	defer ledgerMtx.RUnlock()
+
+	return getSupply()
+}
+
+func getSupply() *types.SupplyResult {
+	transparent := uint256.NewInt(0)
+	for _, bal := range transparentBalances {
+		transparent.Add(transparent, bal)
+	}
+	return &types.SupplyResult{
+		Minted:          mintedSupply.Clone(),
+		Burned:          burnedSupply.Clone(),
+		Total:           new(uint256.Int).Sub(mintedSupply, burnedSupply),
+		ShieldedPool:    shieldedPoolValue.Clone(),
+		TransparentPool: transparent,
+		PendingFees:     pendingFees.Clone(),
+	}
+}
+
+// AuditSupply checks that the value minted and not burned equals the value in the pools and the fees to be paid.
+func AuditSupply() error {
+	ledgerMtx.RLock()
+	defer ledgerMtx.RUnlock()
+
+	s := getSupply()
+	inPools := new(uint256.Int).Add(s.ShieldedPool, s.TransparentPool)
+	inPools.Add(inPools, s.PendingFees)
+	if !s.Total.Eq(inPools) {
+		return fmt.Errorf("the supply does not match the pools and the fees: supply(%s), pools(%s)", s.Total.Dec(), inPools.Dec())
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package verifier
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/holiman/uint256"
+	"github.com/kysee/zkp/zk-asset/types"
+)
+
+// The transparent pool keeps the public balances of the transparent accounts.
+// The value moves between the pool and the notes only by the shielding (`ZKTx.PublicIn`)
+// and unshielding (`ZKTx.PublicOut`) txs. The mint and burn txs use the same fields; see supply.go.
+var (
+	// transparentBalances maps a transparent address to its balance.
+	transparentBalances = make(map[string]*uint256.Int)
+
+	// shieldedPoolValue is the total value which has been minted into or shielded to the notes and not left them yet.
+	// No tx can unshield more than it, so a bug in the circuit can not inflate the transparent pool.
+	shieldedPoolValue = uint256.NewInt(0)
+)
+
+// GetTransparentBalance returns the balance of the transparent account `addr`.
+func GetTransparentBalance(addr string) *uint256.Int {
+	ledgerMtx.RLock()
+	defer ledgerMtx.RUnlock()
+
+	return getTransparentBalance(addr).Clone()
+}
+
+func getTransparentBalance(addr string) *uint256.Int {
+	if bal, ok := transparentBalances[addr]; ok {
+		return bal
+	}
+	return uint256.NewInt(0)
+}
+
+// GetShieldedPoolValue returns the total value in the notes.
+func GetShieldedPoolValue() *uint256.Int {
+	ledgerMtx.RLock()
+	defer ledgerMtx.RUnlock()
+
+	return shieldedPoolValue.Clone()
+}
+
+// verifyValueBalance checks that the transparent part of `zktx` can be applied to the ledger.
+func verifyValueBalance(zktx *types.ZKTx) error {
+	publicIn, publicOut := zktx.GetPublicIn(), zktx.GetPublicOut()
+	// the circuit reduces the public inputs modulo the scalar field,
+	// so a value out of the range would be proven as its residue.
+	for _, v := range []*uint256.Int{publicIn, publicOut, zktx.GetFee()} {
+		if v.BitLen() > types.MaxValueBits {
+			return fmt.Errorf("too large value: %s", v.Dec())
+		}
+	}
+
+	switch {
+	case zktx.IsMint():
+		if len(zktx.TransparentSig) > 0 {
+			return errors.New("the transparent signature in a mint tx")
+		}
+		if err := verifyIssuerSigs(zktx); err != nil {
+			return err
+		}
+	case !publicIn.IsZero():
+		if len(zktx.IssuerSigs) > 0 {
+			return errors.New("the issuer signatures in a shielding tx")
+		}
+		if err := zktx.VerifyTransparentSig(); err != nil {
+			return err
+		}
+		if bal := getTransparentBalance(zktx.TransparentFrom); bal.Lt(publicIn) {
+			return fmt.Errorf("insufficient transparent balance: balance(%s), need(%s)", bal.Dec(), publicIn.Dec())
+		}
+	default:
+		if zktx.TransparentFrom != "" || len(zktx.TransparentSig) > 0 || len(zktx.IssuerSigs) > 0 {
+			return errors.New("the transparent input without value")
+		}
+	}
+
+	if !publicOut.IsZero() {
+		if !zktx.IsBurn() {
+			if _, err := types.TransparentAddr2Pub(zktx.TransparentTo); err != nil {
+				return err
+			}
+		}
+	} else if zktx.TransparentTo != "" {
+		return errors.New("the transparent output without value")
+	}
+
+	// the value shielded by this tx can be unshielded in it.
+	pool := new(uint256.Int).Add(shieldedPoolValue, publicIn)
+	if need := new(uint256.Int).Add(publicOut, zktx.GetFee()); pool.Lt(need) {
+		return fmt.Errorf("insufficient value in the shielded pool: pool(%s), need(%s)", pool.Dec(), need.Dec())
+	}
+	return nil
+}
+
+// applyValueBalance moves the value of `zktx` between the transparent pool and the notes,
+// and mints or burns it. The fee is kept until the block including `zktx` is sealed.
+// It should be called after `verifyValueBalance` and the proof are verified.
+func applyValueBalance(zktx *types.ZKTx) {
+	publicIn, publicOut := zktx.GetPublicIn(), zktx.GetPublicOut()
+	if !publicIn.IsZero() {
+		if zktx.IsMint() {
+			mintedSupply = new(uint256.Int).Add(mintedSupply, publicIn)
+		} else {
+			transparentBalances[zktx.TransparentFrom] = new(uint256.Int).Sub(getTransparentBalance(zktx.TransparentFrom), publicIn)
+		}
+		shieldedPoolValue = new(uint256.Int).Add(shieldedPoolValue, publicIn)
+	}
+	if !publicOut.IsZero() {
+		if zktx.IsBurn() {
+			burnedSupply = new(uint256.Int).Add(burnedSupply, publicOut)
+		} else {
+			transparentBalances[zktx.TransparentTo] = new(uint256.Int).Add(getTransparentBalance(zktx.TransparentTo), publicOut)
+		}
+		shieldedPoolValue = new(uint256.Int).Sub(shieldedPoolValue, publicOut)
+	}
+	if fee := zktx.GetFee(); !fee.IsZero() {
+		shieldedPoolValue = new(uint256.Int).Sub(shieldedPoolValue, fee)
+		pendingFees = new(uint256.Int).Add(pendingFees, fee)
+	}
+}
@@ -3,37 +3,101 @@ package verifier
 import (
 	"bytes"
 	"errors"
+	"fmt"
 
 	"github.com/consensys/gnark-crypto/ecc"
+	ecc_tedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
 	"github.com/consensys/gnark/backend/plonk"
 	"github.com/consensys/gnark/frontend"
+	"github.com/holiman/uint256"
+	"github.com/kysee/zkp/zk-asset/crypto"
 	"github.com/kysee/zkp/zk-asset/types"
 )
 
 func VerifyZKTx(zktx *types.ZKTx) error {
+	_, err := submitZKTx(zktx)
+	return err
+}
+
+// submitZKTx verifies the zktx and appends it to the ledger.
+// It returns the index of the zktx in the ledger.
+func submitZKTx(zktx *types.ZKTx) (int, error) {
+	if len(zktx.NewSecretNotes) != len(zktx.NewNoteCommitments) {
+		return -1, errors.New("the number of secret notes and note commitments are different")
+	}
+
+	if err := zktx.VerifySpendAuthSig(); err != nil {
+		return -1, err
+	}
 
-	if err := VerifyZKProof(
+	ledgerMtx.Lock()
+	defer ledgerMtx.Unlock()
+
+	if err := verifyValueBalance(zktx); err != nil {
+		return -1, err
+	}
+	if err := verifyZKProof(
 		zktx.ProofBytes,
 		merkleNoteCommitments.Root(),
-		zktx.Nullifier,
-		zktx.NewNoteCommitments); err != nil {
-		return err
+		zktx.Nullifiers,
+		zktx.NewNoteCommitments,
+		zktx.Rk,
+		zktx.GetPublicIn(),
+		zktx.GetPublicOut(),
+		zktx.GetFee()); err != nil {
+		return -1, err
+	}
+
+	applyValueBalance(zktx)
+
+	for _, nf := range zktx.Nullifiers {
+		addNoteNullifier(nf)
+	}
+	for i, cm := range zktx.NewNoteCommitments {
+		// an empty commitment (e.g. no change) is not added to the tree.
+		// wallets mirror this rule to track the positions of their notes.
+		if len(cm) == 0 {
+			continue
+		}
+		addNoteCommitment(cm)
+		addSecretNote(zktx.NewSecretNotes[i])
 	}
+	return addZKTx(zktx), nil
+}
+
+// VerifyZKProof verifies the proof of a tx.
+// `rk` is the randomized spend authorizing key of the tx; the signature made with it is not verified here.
+// `publicIn`, `publicOut` is the value shielded and unshielded by the tx, and `fee` is the fee of it.
+func VerifyZKProof(bzProof []byte, merkleRootHash []byte, nullifiers, newCommitments [][]byte, rk []byte, publicIn, publicOut, fee *uint256.Int) error {
+	ledgerMtx.RLock()
+	defer ledgerMtx.RUnlock()
 
-	addNoteNullifier(zktx.Nullifier)
-	addNoteCommitment(zktx.NewNoteCommitments[0])
-	addNoteCommitment(zktx.NewNoteCommitments[1])
-	addSecretNote(zktx.NewSecretNotes[0])
-	addSecretNote(zktx.NewSecretNotes[1])
-	addZKTx(zktx)
-	return nil
+	return verifyZKProof(bzProof, merkleRootHash, nullifiers, newCommitments, rk, publicIn, publicOut, fee)
 }
 
-func VerifyZKProof(bzProof []byte, merkleRootHash, nullifier []byte, newCommitments [][]byte) error {
+func verifyZKProof(bzProof []byte, merkleRootHash []byte, nullifiers, newCommitments [][]byte, rk []byte, publicIn, publicOut, fee *uint256.Int) error {
 	// verify zk proof and handdles nullifier, new note commitments
 
-	if FindNoteNullifier(nullifier) != nil {
-		return errors.New("nullifier already exists")
+	if len(nullifiers) != types.NumInputNotes {
+		return errors.New("wrong number of nullifiers")
+	}
+	for i, nf := range nullifiers {
+		if findNoteNullifier(nf) != nil {
+			return errors.New("nullifier already exists")
+		}
+		// the same note should not be spent twice in a tx.
+		for _, _nf := range nullifiers[:i] {
+			if bytes.Equal(nf, _nf) {
+				return errors.New("duplicated nullifier")
+			}
+		}
+	}
+	if len(newCommitments) != 2 {
+		return errors.New("wrong number of new note commitments")
+	}
+	_rk := crypto.NewPub()
+	if _, err := _rk.SetBytes(rk); err != nil {
+		return fmt.Errorf("wrong rk: %w", err)
 	}
 
 	proof := plonk.NewProof(ecc.BN254)
@@ -46,9 +110,15 @@ func VerifyZKProof(bzProof []byte, merkleRootHash, nullifier []byte, newCommitme
 	// when zktx was made, the merkle root hash may be different from the latest one (`merkleNoteCommitmentsRoot`).
 	tmpAssignment := types.ZKCircuit{
 		NoteMerkleRoot:       merkleRootHash, // don't use the zktx.MerkleRoot; it may be faked.
-		Nullifier:            nullifier,
 		NewNoteCommitment:    newCommitments[0],
 		ChangeNoteCommitment: newCommitments[1],
+		PublicIn:             publicIn.Bytes(),
+		PublicOut:            publicOut.Bytes(),
+		Fee:                  fee.Bytes(),
+	}
+	tmpAssignment.Rk.Assign(ecc_tedwards.BN254, rk)
+	for i, nf := range nullifiers {
+		tmpAssignment.Nullifiers[i] = nf
 	}
 	pubWtn, err := frontend.NewWitness(&tmpAssignment, ecc.BN254.ScalarField(), frontend.PublicOnly())
 	if err != nil {
@@ -0,0 +1,79 @@
+package zk_asset
+
+import (
+	"fmt"
+
+	"github.com/consensys/gnark-crypto/signature"
+	"github.com/holiman/uint256"
+	"github.com/kysee/zkp/zk-asset/crypto"
+	"github.com/kysee/zkp/zk-asset/prover"
+	"github.com/kysee/zkp/zk-asset/verifier"
+)
+
+var _ prover.ChainSource = (*verifier.LocalChain)(nil)
+
+var (
+	// wallets are the funded wallets shared by the tests.
+	wallets []*prover.Wallet
+	// issuerKey is the issuance key of the ledger of the tests.
+	issuerKey signature.Signer
+)
+
+func init() {
+	var err error
+	if issuerKey, err = crypto.NewKey(); err != nil {
+		panic(err)
+	}
+	if err := verifier.SetIssuers(1, issuerKey.Public()); err != nil {
+		panic(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		w := prover.NewWallet(verifier.NewLocalChain())
+		wallets = append(wallets, w)
+	}
+	// a mint tx makes two notes, so the wallets are funded in pairs.
+	for i := 0; i < len(wallets); i += 2 {
+		if err := mintTo(wallets[i], wallets[i+1].Address, 100, 100); err != nil {
+			panic(err)
+		}
+	}
+
+	for _, w := range wallets {
+		if _, err := w.SyncSharedNotes(); err != nil {
+			panic(err)
+		}
+		b := w.GetBalance()
+		fmt.Printf("prover=%s, balance=%s\n", w.Address, b.Dec())
+	}
+}
+
+// mintTo mints `amount` to `toAddr` and `change` to `minter` by a tx built by `minter` and signed by `issuerKey`.
+func mintTo(minter *prover.Wallet, toAddr string, amount, change uint64) error {
+	info, err := minter.Chain().GetMerkleRoot()
+	if err != nil {
+		return err
+	}
+	b := prover.NewTxBuilder(minter.ProofAuthorizingKey())
+	b.SetAnchor(info.Root, info.Depth)
+	if err := b.AddMint(uint256.NewInt(amount + change)); err != nil {
+		return err
+	}
+	if err := b.AddOutput(toAddr, uint256.NewInt(amount), nil); err != nil {
+		return err
+	}
+	utx, err := b.Build()
+	if err != nil {
+		return err
+	}
+	if utx.Tx.ProofBytes, err = prover.Prove(utx.Witness, verifier.ZKProvingKey, verifier.ZKCSS); err != nil {
+		return err
+	}
+	if err := prover.SignZKTx(utx.Tx, minter.PrivateKey, utx.Witness.Alpha); err != nil {
+		return err
+	}
+	if err := prover.SignMint(utx.Tx, issuerKey); err != nil {
+		return err
+	}
+	return verifier.VerifyZKTx(utx.Tx)
+}
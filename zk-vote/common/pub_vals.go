@@ -1,5 +0,0 @@
-package common
-
-var (
-	MerkleCitizensRootHash []byte
-)
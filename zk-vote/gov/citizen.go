@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/consensys/gnark-crypto/accumulator/merkletree"
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
 	"github.com/consensys/gnark-crypto/ecc/bn254/twistededwards/eddsa"
@@ -17,34 +16,62 @@ import (
 	"github.com/consensys/gnark/constraint/solver"
 	"github.com/consensys/gnark/frontend"
 	"github.com/kysee/zkp/utils"
-	"github.com/kysee/zkp/zk-vote/common"
 	"github.com/kysee/zkp/zk-vote/vote"
 	"github.com/rs/zerolog"
 )
 
+// Citizen is the wallet of a citizen.
 type Citizen struct {
-	Name string
-	SN   string
-
 	DIDPrvKey signature.Signer
 	DIDPubKey signature.PublicKey
+	// Credential is issued for DIDPubKey. Only the citizen holds its attributes.
+	Credential *Credential
 
+	// ElectionID is the election which VotePaperID is made for.
+	ElectionID    []byte
 	VotePaperID   []byte
 	TmpVotePrvKey signature.Signer
 	TmpVotePubKey signature.PublicKey
+
+	// roll tracks the roll of the election ElectionID.
+	roll *RollTracker
 }
 
-func NewCitizen(name, sn string) *Citizen {
+func NewCitizen() *Citizen {
 	didPrvKey, _ := eddsa.GenerateKey(rand.Reader)
 
 	return &Citizen{
-		Name:      name,
-		SN:        sn,
 		DIDPrvKey: didPrvKey,
 		DIDPubKey: didPrvKey.Public(),
 	}
 }
 
+// RequestCredential gets the credential of `attrs` for the DID public key from `issuer`.
+func (c *Citizen) RequestCredential(issuer *Issuer, attrs Attributes) error {
+	cr, err := issuer.Issue(c.DIDPubKey, attrs)
+	if err != nil {
+		return err
+	}
+	c.Credential = cr
+	return nil
+}
+
+// RotateDIDKey replaces the DID key of the citizen with a new one
+// and returns the hash of the former public key, which the roll is updated from.
+// The credential of the former key is dropped; a new one should be requested for the new key.
+// The vote paper id is made again with the new key.
+func (c *Citizen) RotateDIDKey() []byte {
+	from := c.HashDIDPubKey()
+	didPrvKey, _ := eddsa.GenerateKey(rand.Reader)
+	c.DIDPrvKey = didPrvKey
+	c.DIDPubKey = didPrvKey.Public()
+	c.Credential = nil
+	if c.ElectionID != nil {
+		c.MakeVotePaperID(c.ElectionID)
+	}
+	return from
+}
+
 func (c *Citizen) HashDIDPubKey() []byte {
 	var p twistededwards.PointAffine
 	_, _ = p.SetBytes(c.DIDPubKey.Bytes())
@@ -54,12 +81,8 @@ func (c *Citizen) HashDIDPubKey() []byte {
 	return utils.MiMCHash(x[:], y[:])
 }
 
-func (c *Citizen) GetIndex() int {
-	return GetCitizenIdx(c)
-}
-
 func (c *Citizen) String() string {
-	return fmt.Sprintf("Name:%s, SN:%s, did:%x, tmp:%x, VotePaperID:%x", c.Name, c.SN, c.DIDPubKey.Bytes(), c.TmpVotePubKey.Bytes(), c.VotePaperID)
+	return fmt.Sprintf("did:%x, tmp:%x, VotePaperID:%x", c.DIDPubKey.Bytes(), c.TmpVotePubKey.Bytes(), c.VotePaperID)
 }
 
 func (c *Citizen) GetPrvScalar() ([]byte, []byte) {
@@ -67,51 +90,70 @@ func (c *Citizen) GetPrvScalar() ([]byte, []byte) {
 	return s[:16], s[16:32]
 }
 
-func (c *Citizen) MakeVotePaperID() {
+// MakeVotePaperID makes the vote paper id of the citizen for the election `electionID`.
+// The citizen has a different vote paper id in every election.
+func (c *Citizen) MakeVotePaperID(electionID []byte) {
 	tmpPrvKey, _ := eddsa.GenerateKey(rand.Reader)
 	c.TmpVotePrvKey = tmpPrvKey
 	c.TmpVotePubKey = tmpPrvKey.Public()
 
+	c.ElectionID = electionID
+	c.VotePaperID = c.VotePaperIDOf(electionID)
+	c.roll = nil
+}
+
+// SyncRoll applies the new updates of the roll of the election `e` to the roll the citizen tracks.
+func (c *Citizen) SyncRoll(e *vote.Election) error {
+	if !bytes.Equal(c.ElectionID, e.ID) {
+		return errors.New("VotePaperID is not made for the election")
+	}
+	if c.roll == nil {
+		c.roll = NewRollTracker(e.Keys.Depth, c.HashDIDPubKey())
+	}
+	return c.roll.Apply(e.RollUpdates(c.roll.Applied()))
+}
+
+// VotePaperIDOf returns Hash(PrvKeyScalar, DIDPubKey.A.X, DIDPubKey.A.Y, electionID).
+func (c *Citizen) VotePaperIDOf(electionID []byte) []byte {
 	s1, s2 := c.GetPrvScalar()
 	x := c.DIDPubKey.(*eddsa.PublicKey).A.X.Bytes()
 	y := c.DIDPubKey.(*eddsa.PublicKey).A.Y.Bytes()
 
-	c.VotePaperID = utils.MiMCHash(s1[:], s2[:], x[:], y[:])
+	return utils.MiMCHash(s1[:], s2[:], x[:], y[:], electionID)
 }
 
 var gnarkLogger = zerolog.New(os.Stdout).Level(zerolog.DebugLevel).With().Timestamp().Logger()
 
-func (c *Citizen) VoteProof(choice []byte) (groth16.Proof, error) {
+// VoteProof encrypts the ballot of `choice` in the election `e` and proves it.
+// The vote paper id should be made for `e` by `MakeVotePaperID`.
+func (c *Citizen) VoteProof(e *vote.Election, choice []byte) (groth16.Proof, *vote.EncryptedBallot, error) {
 	if c.VotePaperID == nil {
-		return nil, errors.New("VotePaperID should not be nil")
+		return nil, nil, errors.New("VotePaperID should not be nil")
 	}
-
-	cidx := c.GetIndex()
-	if cidx < 0 {
-		return nil, errors.New("not found index in merkle")
+	if !bytes.Equal(c.ElectionID, e.ID) {
+		return nil, nil, errors.New("VotePaperID is not made for the election")
 	}
-	citizenIdx := uint64(cidx)
 
-	rootHash, proofPath, numLeaves, err := merkletree.BuildReaderProof(
-		bytes.NewBuffer(MerkleCitizensBytes),
-		utils.MiMCHasher(),
-		utils.MiMCHasher().Size(),
-		citizenIdx,
-	)
-	if err != nil {
-		return nil, err
+	// the membership proof is made from the roll tracked by the citizen.
+	root := e.CitizenMerkleRoot()
+	if root == nil {
+		return nil, nil, fmt.Errorf("%w: the roll is not frozen", vote.ErrWrongPhase)
 	}
-
-	// verify the proof in plain go
-	verified := merkletree.VerifyProof(utils.MiMCHasher(), rootHash, proofPath, citizenIdx, numLeaves)
-	if !verified {
-		return nil, errors.New("the merkle proof in plain go should pass")
+	if err := c.SyncRoll(e); err != nil {
+		return nil, nil, err
+	}
+	if !bytes.Equal(c.roll.Root(), root) {
+		return nil, nil, errors.New("the tracked roll is not the snapshot")
+	}
+	citizenIdx, proofPath, err := c.roll.Proof()
+	if err != nil {
+		return nil, nil, err
 	}
 
 	var assignment vote.VoteCircuit
 	assignment.SetCurveId(utils.CURVEID)
 	assignment.LeafIdx = citizenIdx
-	assignment.CitizenMerkleRoot = common.MerkleCitizensRootHash
+	assignment.CitizenMerkleRoot = root
 	assignment.CitizenMerklePath = make([]frontend.Variable, len(proofPath))
 	for i := 0; i < len(proofPath); i++ {
 		assignment.CitizenMerklePath[i] = proofPath[i]
@@ -121,32 +163,44 @@ func (c *Citizen) VoteProof(choice []byte) (groth16.Proof, error) {
 	s0, s1 := c.GetPrvScalar()
 	assignment.S0, assignment.S1 = s0, s1
 	assignment.AssignPubKey(c.DIDPubKey)
+	assignment.ElectionID = e.ID
 	assignment.VotePaperID = c.VotePaperID
 	assignment.Choice = choice
+	assignment.AssignChoices(e.Choices)
+
+	// the choice is encrypted as a one-hot vector.
+	ballot, randoms, err := vote.EncryptBallot(e.PubKey(), vote.OneHot(e.Choices, choice))
+	if err != nil {
+		return nil, nil, err
+	}
+	assignment.AssignBallot(e.PubKey(), ballot)
+	for i, r := range randoms {
+		assignment.Randoms[i] = r
+	}
 
 	sig, err := c.DIDPrvKey.Sign(choice, utils.MiMCHasher())
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	assignment.AssignSig(sig)
 
 	wtn, err := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	proof, err := groth16.Prove(
-		vote.R1CS,
-		vote.ProvingKey,
+		e.Keys.R1CS,
+		e.Keys.ProvingKey,
 		wtn,
 		backend.WithSolverOptions(
 			solver.WithLogger(gnarkLogger),
 		),
 	)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return proof, nil
+	return proof, ballot, nil
 }
 
 //func (c *Citizen) ProofVoting(selection string) (groth16.Proof, error) {
@@ -1,37 +1,192 @@
 package gov
 
 import (
-	"github.com/consensys/gnark-crypto/accumulator/merkletree"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+	"github.com/consensys/gnark-crypto/ecc/bn254/twistededwards/eddsa"
+	"github.com/consensys/gnark-crypto/signature"
 	"github.com/kysee/zkp/utils"
-	"github.com/kysee/zkp/zk-vote/common"
+	"github.com/kysee/zkp/zk-vote/smt"
+	"github.com/kysee/zkp/zk-vote/vote"
 )
 
-var (
-	totalCitizens       []*Citizen
-	merkleCitizens      *merkletree.Tree
-	MerkleCitizensBytes []byte
-)
+// Attributes are the personal information of a citizen certified by the issuer.
+type Attributes struct {
+	Name string
+	SN   string
+}
+
+func (a *Attributes) Hash() []byte {
+	return utils.MiMCHash(hashString(a.Name), hashString(a.SN))
+}
+
+// hashString hashes `s` with its length, since the last chunk of `s` is padded by MiMC.
+func hashString(s string) []byte {
+	return utils.MiMCHash(big.NewInt(int64(len(s))).FillBytes(make([]byte, smt.LeafSize)), []byte(s))
+}
+
+// Credential binds the DID public key of a citizen to its attributes; it is signed by the issuer.
+// The citizen holds the credential, and the roll keeps only `Commitment` of it.
+type Credential struct {
+	DIDPubKey  []byte
+	Attributes Attributes
+	Sig        []byte
+}
+
+func (cr *Credential) SigHash() []byte {
+	return utils.MiMCHash(cr.Commitment(), cr.Attributes.Hash())
+}
+
+// Commitment returns the hash of the DID public key, the leaf of the citizen in the roll.
+// It reveals none of the attributes.
+func (cr *Credential) Commitment() []byte {
+	var p twistededwards.PointAffine
+	if _, err := p.SetBytes(cr.DIDPubKey); err != nil {
+		return nil
+	}
+	x := p.X.Bytes()
+	y := p.Y.Bytes()
+	return utils.MiMCHash(x[:], y[:])
+}
+
+// Verify checks that the credential is signed by the issuer of `issuerPubKey`.
+func (cr *Credential) Verify(issuerPubKey signature.PublicKey) error {
+	if cr.Commitment() == nil {
+		return errors.New("wrong DID public key of the credential")
+	}
+	ok, err := issuerPubKey.Verify(cr.Sig, cr.SigHash(), utils.MiMCHasher())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("wrong signature of the credential")
+	}
+	return nil
+}
+
+// Issuer certifies the attributes of the citizens.
+// It does not keep the credentials it issues.
+type Issuer struct {
+	key signature.Signer
+}
+
+func NewIssuer() (*Issuer, error) {
+	key, err := eddsa.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Issuer{key: key}, nil
+}
+
+func (i *Issuer) PubKey() signature.PublicKey {
+	return i.key.Public()
+}
+
+// Issue returns the credential which binds `didPubKey` to `attrs`.
+func (i *Issuer) Issue(didPubKey signature.PublicKey, attrs Attributes) (*Credential, error) {
+	cr := &Credential{
+		DIDPubKey:  didPubKey.Bytes(),
+		Attributes: attrs,
+	}
+	if cr.Commitment() == nil {
+		return nil, errors.New("wrong DID public key")
+	}
+	sig, err := i.key.Sign(cr.SigHash(), utils.MiMCHasher())
+	if err != nil {
+		return nil, err
+	}
+	cr.Sig = sig
+	return cr, nil
+}
 
-func RegisterCitizen(c *Citizen) int {
-	totalCitizens = append(totalCitizens, c)
+// Roll is the register of the citizens.
+// The citizens are in the slots of `vote.Roll`, which is updated by registration, revocation and key rotation.
+// It accepts only the credentials of its issuer and keeps only their commitments, so it holds no attributes of the citizens.
+// An election takes a snapshot of it by `Election.Freeze`, which replays its `Updates`.
+type Roll struct {
+	mtx    sync.RWMutex
+	issuer signature.PublicKey
+	roll   *vote.Roll
+}
 
-	d := c.HashDIDPubKey()
+// NewRoll returns the empty roll of 2^depth slots for the credentials of `issuer`.
+func NewRoll(depth int, issuer signature.PublicKey) *Roll {
+	return &Roll{
+		issuer: issuer,
+		roll:   vote.NewRoll(depth),
+	}
+}
 
-	if merkleCitizens == nil {
-		merkleCitizens = merkletree.New(utils.MiMCHasher())
+// RegisterCitizen adds the citizen of `cr` in the next slot of the roll and returns the slot.
+func (r *Roll) RegisterCitizen(cr *Credential) (uint64, error) {
+	if err := cr.Verify(r.issuer); err != nil {
+		return 0, err
 	}
-	merkleCitizens.Push(d)
-	MerkleCitizensBytes = append(MerkleCitizensBytes, d...)
-	common.MerkleCitizensRootHash = merkleCitizens.Root()
 
-	return len(totalCitizens)
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return r.roll.Insert(cr.Commitment())
+}
+
+// RevokeCitizen removes the citizen of `commitment` from the roll.
+func (r *Roll) RevokeCitizen(commitment []byte) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return r.roll.Revoke(commitment)
 }
 
-func GetCitizenIdx(c *Citizen) int {
-	for i, _c := range totalCitizens {
-		if c.SN == _c.SN {
-			return i
-		}
+// RotateCitizen replaces the citizen of the commitment `from` with the one of `cr`,
+// the credential issued for the new DID public key. See `Citizen.RotateDIDKey`.
+func (r *Roll) RotateCitizen(from []byte, cr *Credential) error {
+	if err := cr.Verify(r.issuer); err != nil {
+		return err
 	}
-	return -1
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return r.roll.Rotate(from, cr.Commitment())
+}
+
+func (r *Roll) Depth() int {
+	return r.roll.Depth()
+}
+
+// MerkleRootHash returns the current root of the roll.
+func (r *Roll) MerkleRootHash() []byte {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	return r.roll.Root()
+}
+
+// Updates returns the updates of the roll from the `from`-th one, which the citizens follow.
+func (r *Roll) Updates(from int) []vote.RollUpdate {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	return r.roll.Updates(from)
+}
+
+// VerifyCitizen checks that `commitment` is in the slot `idx` of the roll of `root`,
+// which may be the root before the latest updates.
+func (r *Roll) VerifyCitizen(root []byte, idx uint64, commitment []byte, proof smt.Proof) error {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	return r.roll.Verify(root, idx, commitment, proof)
+}
+
+// Leaves returns the commitments of the citizens in the order of their slots.
+func (r *Roll) Leaves() [][]byte {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	return r.roll.Leaves()
 }
@@ -0,0 +1,67 @@
+package gov
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+
+	"github.com/kysee/zkp/zk-vote/smt"
+	"github.com/kysee/zkp/zk-vote/vote"
+)
+
+// RollTracker follows the public updates of a roll in the wallet of a citizen,
+// so the citizen makes its membership proof by itself without telling the roll who it is.
+type RollTracker struct {
+	tree *smt.Tree
+	// applied is the number of the updates applied.
+	applied int
+	leaf    []byte
+	slot    uint64
+	found   bool
+}
+
+// NewRollTracker returns the tracker of `leaf`, the commitment of the citizen, in the roll of `depth`.
+func NewRollTracker(depth int, leaf []byte) *RollTracker {
+	return &RollTracker{
+		tree: smt.New(depth),
+		leaf: leaf,
+	}
+}
+
+// Applied returns the number of the updates applied, where the next updates start.
+func (t *RollTracker) Applied() int {
+	return t.applied
+}
+
+// Apply applies `updates`, which should follow the ones applied.
+func (t *RollTracker) Apply(updates []vote.RollUpdate) error {
+	for _, u := range updates {
+		if err := t.tree.Set(new(big.Int).SetUint64(u.Slot), u.Leaf); err != nil {
+			return err
+		}
+		if bytes.Equal(u.Leaf, t.leaf) {
+			t.slot, t.found = u.Slot, true
+		} else if t.found && u.Slot == t.slot {
+			// revoked or rotated to another key
+			t.found = false
+		}
+		t.applied++
+	}
+	return nil
+}
+
+func (t *RollTracker) Root() []byte {
+	return t.tree.Root()
+}
+
+// Proof returns the slot of the citizen and its membership proof against `Root`.
+func (t *RollTracker) Proof() (uint64, smt.Proof, error) {
+	if !t.found {
+		return 0, nil, errors.New("the citizen is not in the roll")
+	}
+	proof, err := t.tree.Prove(new(big.Int).SetUint64(t.slot))
+	if err != nil {
+		return 0, nil, err
+	}
+	return t.slot, proof, nil
+}
@@ -0,0 +1,21 @@
+package smt
+
+import (
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/hash"
+)
+
+// AssertProof asserts in the circuit that `leaf` is the leaf of `key` in the tree of `root`,
+// where `path` is the `Proof` of the leaf. `h` should be MiMC.
+// The key should be less than 2^len(path), so the depth of the tree is fixed by the circuit.
+func AssertProof(api frontend.API, h hash.FieldHasher, root, key, leaf frontend.Variable, path []frontend.Variable) {
+	bits := api.ToBinary(key, len(path))
+
+	n := leaf
+	for i, sibling := range path {
+		h.Reset()
+		h.Write(api.Select(bits[i], sibling, n), api.Select(bits[i], n, sibling))
+		n = h.Sum()
+	}
+	api.AssertIsEqual(root, n)
+}
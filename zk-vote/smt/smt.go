@@ -0,0 +1,157 @@
+// Package smt is a sparse Merkle tree of MiMC.
+//
+// A tree of depth `d` has 2^d leaves, which are all empty (zero) at first.
+// The leaf of a key is at the path of the bits of the key: the bit `i` of the key chooses
+// the right child at the height `i`, so a key is the index of its leaf.
+// Only the nodes on the paths of non-empty leaves are kept,
+// so a leaf can be set, updated and removed at the cost of `d` hashes.
+package smt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	_ "github.com/consensys/gnark-crypto/ecc/bn254/fr/mimc"
+	"github.com/kysee/zkp/utils"
+)
+
+// LeafSize is the size of a leaf; it is a field element.
+var LeafSize = utils.MiMCHasher().Size()
+
+type nodeKey struct {
+	height int
+	// path is the key of a leaf under the node shifted by `height`.
+	path string
+}
+
+type Tree struct {
+	depth int
+	nodes map[nodeKey][]byte
+	// empties[h] is the hash of the empty subtree of height h.
+	empties [][]byte
+}
+
+func New(depth int) *Tree {
+	empties := make([][]byte, depth+1)
+	empties[0] = make([]byte, LeafSize)
+	for h := 1; h <= depth; h++ {
+		empties[h] = hashNode(empties[h-1], empties[h-1])
+	}
+	return &Tree{
+		depth:   depth,
+		nodes:   make(map[nodeKey][]byte),
+		empties: empties,
+	}
+}
+
+func hashNode(left, right []byte) []byte {
+	return utils.MiMCHash(left, right)
+}
+
+func (t *Tree) Depth() int {
+	return t.depth
+}
+
+func (t *Tree) Root() []byte {
+	return t.node(t.depth, new(big.Int))
+}
+
+func (t *Tree) node(height int, path *big.Int) []byte {
+	if n, ok := t.nodes[nodeKey{height, path.Text(16)}]; ok {
+		return n
+	}
+	return t.empties[height]
+}
+
+func (t *Tree) setNode(height int, path *big.Int, n []byte) {
+	k := nodeKey{height, path.Text(16)}
+	if bytes.Equal(n, t.empties[height]) {
+		delete(t.nodes, k)
+	} else {
+		t.nodes[k] = n
+	}
+}
+
+func (t *Tree) checkKey(key *big.Int) error {
+	if key.Sign() < 0 || key.BitLen() > t.depth {
+		return fmt.Errorf("the key is out of the tree of depth %d", t.depth)
+	}
+	return nil
+}
+
+// Get returns the leaf of `key`. It is zero if the leaf is empty.
+func (t *Tree) Get(key *big.Int) ([]byte, error) {
+	if err := t.checkKey(key); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), t.node(0, key)...), nil
+}
+
+// Set sets the leaf of `key` to `leaf`. A zero leaf removes the leaf.
+func (t *Tree) Set(key *big.Int, leaf []byte) error {
+	if err := t.checkKey(key); err != nil {
+		return err
+	}
+	if len(leaf) != LeafSize {
+		return fmt.Errorf("wrong size of the leaf: %d", len(leaf))
+	}
+
+	n := append([]byte(nil), leaf...)
+	path := new(big.Int).Set(key)
+	for h := 0; h < t.depth; h++ {
+		t.setNode(h, path, n)
+		sibling := t.node(h, new(big.Int).Xor(path, big.NewInt(1)))
+		if path.Bit(0) == 0 {
+			n = hashNode(n, sibling)
+		} else {
+			n = hashNode(sibling, n)
+		}
+		path.Rsh(path, 1)
+	}
+	t.setNode(t.depth, path, n)
+	return nil
+}
+
+// Remove empties the leaf of `key`.
+func (t *Tree) Remove(key *big.Int) error {
+	return t.Set(key, t.empties[0])
+}
+
+// Proof is the siblings of the path of a leaf from the bottom.
+type Proof [][]byte
+
+// Prove returns the proof of the leaf of `key`, which may be empty.
+func (t *Tree) Prove(key *big.Int) (Proof, error) {
+	if err := t.checkKey(key); err != nil {
+		return nil, err
+	}
+	proof := make(Proof, t.depth)
+	path := new(big.Int).Set(key)
+	for h := 0; h < t.depth; h++ {
+		proof[h] = append([]byte(nil), t.node(h, new(big.Int).Xor(path, big.NewInt(1)))...)
+		path.Rsh(path, 1)
+	}
+	return proof, nil
+}
+
+// Verify checks that `leaf` is the leaf of `key` in the tree of `root`.
+// The depth of the tree is the length of the proof.
+func (proof Proof) Verify(root []byte, key *big.Int, leaf []byte) error {
+	if key.Sign() < 0 || key.BitLen() > len(proof) {
+		return errors.New("the key is out of the tree")
+	}
+	n := leaf
+	for h, sibling := range proof {
+		if key.Bit(h) == 0 {
+			n = hashNode(n, sibling)
+		} else {
+			n = hashNode(sibling, n)
+		}
+	}
+	if !bytes.Equal(n, root) {
+		return errors.New("wrong merkle proof")
+	}
+	return nil
+}
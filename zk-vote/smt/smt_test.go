@@ -0,0 +1,78 @@
+package smt_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/kysee/zkp/utils"
+	"github.com/kysee/zkp/zk-vote/smt"
+	"github.com/stretchr/testify/require"
+)
+
+func leafOf(i int) []byte {
+	return utils.MiMCHash(big.NewInt(int64(i)).FillBytes(make([]byte, smt.LeafSize)))
+}
+
+func TestTree_SetAndRemove(t *testing.T) {
+	tree := smt.New(16)
+	emptyRoot := tree.Root()
+
+	keys := []int64{0, 1, 2, 0xff, 0x1234, 0xffff}
+	for i, k := range keys {
+		require.NoError(t, tree.Set(big.NewInt(k), leafOf(i)))
+	}
+	root := tree.Root()
+	require.NotEqual(t, emptyRoot, root)
+
+	// the root does not depend on the order of the updates.
+	other := smt.New(16)
+	for i := len(keys) - 1; i >= 0; i-- {
+		require.NoError(t, other.Set(big.NewInt(keys[i]), leafOf(i)))
+	}
+	require.Equal(t, root, other.Root())
+
+	// update a leaf in place
+	require.NoError(t, tree.Set(big.NewInt(keys[0]), leafOf(100)))
+	require.NotEqual(t, root, tree.Root())
+	leaf, err := tree.Get(big.NewInt(keys[0]))
+	require.NoError(t, err)
+	require.Equal(t, leafOf(100), leaf)
+	require.NoError(t, tree.Set(big.NewInt(keys[0]), leafOf(0)))
+	require.Equal(t, root, tree.Root())
+
+	for _, k := range keys {
+		require.NoError(t, tree.Remove(big.NewInt(k)))
+	}
+	require.Equal(t, emptyRoot, tree.Root())
+
+	require.Error(t, tree.Set(big.NewInt(1<<16), leafOf(0)))
+	require.Error(t, tree.Set(big.NewInt(-1), leafOf(0)))
+	require.Error(t, tree.Set(big.NewInt(1), []byte{1}))
+}
+
+func TestTree_Proof(t *testing.T) {
+	tree := smt.New(16)
+	for i := int64(0); i < 10; i++ {
+		require.NoError(t, tree.Set(big.NewInt(i*7), leafOf(int(i))))
+	}
+	root := tree.Root()
+
+	for i := int64(0); i < 10; i++ {
+		key := big.NewInt(i * 7)
+		proof, err := tree.Prove(key)
+		require.NoError(t, err)
+		require.Len(t, proof, 16)
+		require.NoError(t, proof.Verify(root, key, leafOf(int(i))))
+
+		require.Error(t, proof.Verify(root, key, leafOf(int(i)+1)))
+		require.Error(t, proof.Verify(root, big.NewInt(i*7+1), leafOf(int(i))))
+		require.Error(t, proof.Verify(leafOf(0), key, leafOf(int(i))))
+	}
+
+	// the proof of an empty leaf proves that the key is not in the tree.
+	key := big.NewInt(1)
+	proof, err := tree.Prove(key)
+	require.NoError(t, err)
+	require.NoError(t, proof.Verify(root, key, make([]byte, smt.LeafSize)))
+	require.Error(t, proof.Verify(root, key, leafOf(0)))
+}
@@ -0,0 +1,434 @@
+package vote
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+	"github.com/consensys/gnark-crypto/ecc/bn254/twistededwards/eddsa"
+	"github.com/consensys/gnark-crypto/signature"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/frontend"
+	"github.com/kysee/zkp/zk-vote/smt"
+)
+
+// Election is a vote on a question among the citizens of a roll snapshot.
+// Every election has its own ballots, so many elections can run at once.
+//
+// An election goes through the phases in order:
+// the citizens join the roll in the registration phase, `Freeze` takes the snapshot of the roll,
+// the ballots are accepted in the voting window set by `OpenVoting`,
+// and `Seal` makes the tally final after the window is closed.
+type Election struct {
+	ID       []byte
+	Question string
+	Choices  [][]byte
+	Keys     *CircuitKeys
+
+	choicesHash []byte
+	// the ballots are encrypted under pubKey.
+	pubKey twistededwards.PointAffine
+
+	mtx sync.RWMutex
+
+	// one of PhaseRegistration, PhaseFrozen and PhaseSealed.
+	// PhaseVoting and PhaseClosed are decided by the voting window.
+	state  Phase
+	window *VotingWindow
+	// height is the latest block height seen by the election.
+	height uint64
+	tally  []int
+	// decryption is the proof of the tally.
+	decryption *ThresholdDecryption
+
+	// the snapshot of the citizen roll, and its root.
+	roll              *Roll
+	citizenMerkleRoot []byte
+
+	votePapers map[[32]byte]*VotePaper
+	// ballots is all the accepted ballots in order, including the ones replaced by revotes.
+	ballots []*BallotRecord
+	// ballotBox is the tree of the latest ballots keyed by the vote paper ids.
+	// Its receipts are signed with boxKey.
+	ballotBox *smt.Tree
+	boxKey    signature.Signer
+}
+
+// NewElection returns the election named `name` in the registration phase.
+// The ballots are encrypted under `pubKey`, the public key of the election.
+// The proofs of the election are made and verified with `keys`, which can be shared by the elections.
+func NewElection(name, question string, choices [][]byte, pubKey *twistededwards.PointAffine, keys *CircuitKeys) (*Election, error) {
+	if err := checkChoices(choices); err != nil {
+		return nil, err
+	}
+	if !inSubgroup(pubKey) || pubKey.IsZero() {
+		return nil, errors.New("wrong public key of the election")
+	}
+	if keys == nil {
+		return nil, errors.New("no circuit keys")
+	}
+
+	boxKey, err := eddsa.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Election{
+		ID:          NewElectionID(name),
+		Question:    question,
+		Choices:     choices,
+		choicesHash: HashChoices(choices),
+		pubKey:      *pubKey,
+		Keys:        keys,
+		votePapers:  make(map[[32]byte]*VotePaper),
+		ballotBox:   smt.New(BallotBoxDepth),
+		boxKey:      boxKey,
+	}, nil
+}
+
+// Phase returns the current phase of the election.
+func (e *Election) Phase() Phase {
+	e.mtx.RLock()
+	defer e.mtx.RUnlock()
+
+	return e.phase()
+}
+
+func (e *Election) phase() Phase {
+	if e.state != PhaseFrozen || e.window == nil {
+		return e.state
+	}
+	now := time.Now()
+	if e.window.closed(now, e.height) {
+		return PhaseClosed
+	}
+	if e.window.opened(now, e.height) {
+		return PhaseVoting
+	}
+	return PhaseFrozen
+}
+
+func (e *Election) checkPhase(phase Phase) error {
+	if p := e.phase(); p != phase {
+		return fmt.Errorf("%w: %v, not %v", ErrWrongPhase, p, phase)
+	}
+	return nil
+}
+
+// Freeze ends the registration and takes the snapshot of `src`, the roll of the citizens.
+// The citizens join and leave the roll, not the election, so only the roll decides who can vote.
+// The ballots are proven against the root of the snapshot.
+func (e *Election) Freeze(src RollSource) error {
+	if src == nil {
+		return errors.New("no roll")
+	}
+	if src.Depth() != e.Keys.Depth {
+		return fmt.Errorf("the depth of the roll is not of the circuit: %d", src.Depth())
+	}
+	roll, err := snapshotRoll(src)
+	if err != nil {
+		return err
+	}
+	if roll.Len() == 0 {
+		return errors.New("no citizen")
+	}
+
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	if err := e.checkPhase(PhaseRegistration); err != nil {
+		return err
+	}
+	e.roll = roll
+	e.citizenMerkleRoot = roll.Root()
+	e.state = PhaseFrozen
+	return nil
+}
+
+// OpenVoting sets the voting window of the frozen election.
+func (e *Election) OpenVoting(window VotingWindow) error {
+	if err := window.validate(); err != nil {
+		return err
+	}
+
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	if e.state != PhaseFrozen || e.window != nil {
+		return fmt.Errorf("%w: the voting window can be set only once after the roll is frozen", ErrWrongPhase)
+	}
+	e.window = &window
+	return nil
+}
+
+// SetHeight updates the block height seen by the election, which opens and closes the voting window.
+// The height never goes back.
+func (e *Election) SetHeight(height uint64) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	if height > e.height {
+		e.height = height
+	}
+}
+
+// Seal decrypts the totals of the closed election with `dec` and makes the tally final.
+// Only the totals are decrypted, not the ballots.
+// `dec` is either the key of the election or the partial decryptions of its trustees.
+func (e *Election) Seal(dec TallyDecrypter) error {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	if err := e.checkPhase(PhaseClosed); err != nil {
+		return err
+	}
+	cts := e.encryptedTally()
+	decryption, err := dec.DecryptTally(cts)
+	if err != nil {
+		return err
+	}
+	tally, err := decryptTally(&e.pubKey, cts, decryption, len(e.votePapers))
+	if err != nil {
+		return err
+	}
+	e.tally = tally
+	e.decryption = decryption
+	e.state = PhaseSealed
+	return nil
+}
+
+// Tally returns the sealed tally; the i-th count is of the i-th choice.
+func (e *Election) Tally() ([]int, error) {
+	e.mtx.RLock()
+	defer e.mtx.RUnlock()
+
+	if err := e.checkPhase(PhaseSealed); err != nil {
+		return nil, err
+	}
+	return append([]int(nil), e.tally...), nil
+}
+
+// CitizenMerkleRoot returns the root of the citizen roll snapshot, or nil if the roll is not frozen yet.
+func (e *Election) CitizenMerkleRoot() []byte {
+	e.mtx.RLock()
+	defer e.mtx.RUnlock()
+
+	return e.citizenMerkleRoot
+}
+
+// CitizenMerkleProof returns the slot of `citizen` and its membership proof in the roll snapshot.
+func (e *Election) CitizenMerkleProof(citizen []byte) (idx uint64, proofPath smt.Proof, err error) {
+	e.mtx.RLock()
+	defer e.mtx.RUnlock()
+
+	if e.citizenMerkleRoot == nil {
+		return 0, nil, fmt.Errorf("%w: the roll is not frozen", ErrWrongPhase)
+	}
+	return e.roll.Prove(citizen)
+}
+
+// RollUpdates returns the updates of the roll snapshot from the `from`-th one, or nil if the roll is not frozen yet.
+// The citizens follow them to make their membership proofs by themselves.
+func (e *Election) RollUpdates(from int) []RollUpdate {
+	e.mtx.RLock()
+	defer e.mtx.RUnlock()
+
+	if e.roll == nil {
+		return nil
+	}
+	return e.roll.Updates(from)
+}
+
+// VerifyCitizen checks that `citizen` is in the slot `idx` of the roll snapshot of `root`.
+// The proofs made before the snapshot are checked by the roll, e.g. `gov.Roll.VerifyCitizen`.
+func (e *Election) VerifyCitizen(root []byte, idx uint64, citizen []byte, proofPath smt.Proof) error {
+	e.mtx.RLock()
+	defer e.mtx.RUnlock()
+
+	if e.roll == nil {
+		return fmt.Errorf("%w: the roll is not frozen", ErrWrongPhase)
+	}
+	return e.roll.Verify(root, idx, citizen, proofPath)
+}
+
+// ChoicesHash returns the hash of the allowed choices, which the ballots are proven against.
+func (e *Election) ChoicesHash() []byte {
+	return e.choicesHash
+}
+
+// PubKey returns the public key the ballots are encrypted under.
+func (e *Election) PubKey() *twistededwards.PointAffine {
+	var pk twistededwards.PointAffine
+	pk.Set(&e.pubKey)
+	return &pk
+}
+
+// checkBallot checks that a ballot proven against `citizenMerkleRoot` can be counted now.
+func (e *Election) checkBallot(citizenMerkleRoot []byte) error {
+	if err := e.checkPhase(PhaseVoting); err != nil {
+		return err
+	}
+	if !bytes.Equal(citizenMerkleRoot, e.citizenMerkleRoot) {
+		return ErrNotSnapshotRoot
+	}
+	return nil
+}
+
+// DoVote verifies the proof of the encrypted ballot and counts it.
+// `citizenMerkleRoot` is the root the ballot is proven against; it should be of the roll snapshot.
+// The ballot is accepted only in the voting window,
+// and the ballot of the same vote paper replaces the former one.
+func (e *Election) DoVote(proof groth16.Proof, citizenMerkleRoot, votePaperId []byte, ballot *EncryptedBallot) error {
+	if ballot == nil {
+		return errors.New("no ballot")
+	}
+	if err := checkVotePaperID(votePaperId); err != nil {
+		return err
+	}
+	e.mtx.RLock()
+	err := e.checkBallot(citizenMerkleRoot)
+	e.mtx.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	err = verifyVoteProof(e.Keys.VerifyingKey, proof, citizenMerkleRoot, e.ID, votePaperId, e.choicesHash, &e.pubKey, ballot)
+	if err != nil {
+		return err
+	}
+	var bzProof bytes.Buffer
+	if _, err := proof.WriteTo(&bzProof); err != nil {
+		return err
+	}
+
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	// the window may be closed while the proof is verified.
+	if err := e.checkBallot(citizenMerkleRoot); err != nil {
+		return err
+	}
+	e.addVotePaper(votePaperId, ballot)
+	e.ballots = append(e.ballots, &BallotRecord{
+		Proof:       bzProof.Bytes(),
+		VotePaperID: votePaperId,
+		Ballot:      ballot,
+	})
+	return nil
+}
+
+// verifyVoteProof verifies the proof of `ballot` with its public inputs.
+func verifyVoteProof(
+	vk groth16.VerifyingKey, proof groth16.Proof,
+	citizenMerkleRoot, electionID, votePaperId, choicesHash []byte,
+	pubKey *twistededwards.PointAffine, ballot *EncryptedBallot,
+) error {
+	tmpAssignment := VoteCircuit{
+		CitizenMerkleRoot: citizenMerkleRoot,
+		ElectionID:        electionID,
+		VotePaperID:       votePaperId,
+		ChoicesHash:       choicesHash,
+	}
+	tmpAssignment.AssignBallot(pubKey, ballot)
+	pubWtn, err := frontend.NewWitness(&tmpAssignment, ecc.BN254.ScalarField(), frontend.PublicOnly())
+	if err != nil {
+		return err
+	}
+	return groth16.Verify(proof, vk, pubWtn)
+}
+
+func (e *Election) addVotePaper(id []byte, ballot *EncryptedBallot) {
+	e.votePapers[toVotePaperID(id)] = NewVotePaper(e.ID, id, ballot)
+
+	// the vote paper id is a field element, so it is in the ballot box.
+	_ = e.ballotBox.Set(new(big.Int).SetBytes(id), ballot.Hash())
+}
+
+func (e *Election) FindVotePaper(id []byte) *VotePaper {
+	e.mtx.RLock()
+	defer e.mtx.RUnlock()
+
+	return e.votePapers[toVotePaperID(id)]
+}
+
+func (e *Election) GetVotePaperCnt() int {
+	e.mtx.RLock()
+	defer e.mtx.RUnlock()
+
+	return len(e.votePapers)
+}
+
+// EncryptedTally returns the sums of the current ballots; the i-th sum is the encrypted count of the i-th choice.
+func (e *Election) EncryptedTally() []*Ciphertext {
+	e.mtx.RLock()
+	defer e.mtx.RUnlock()
+
+	return e.encryptedTally()
+}
+
+func (e *Election) encryptedTally() []*Ciphertext {
+	sums := make([]*Ciphertext, len(e.Choices))
+	for i := range sums {
+		sums[i] = zeroCiphertext()
+	}
+	for _, v := range e.votePapers {
+		for i, sum := range sums {
+			sum.Add(sum, &v.Ballot[i])
+		}
+	}
+	return sums
+}
+
+// BallotBoxRoot returns the root of the ballot box.
+func (e *Election) BallotBoxRoot() []byte {
+	e.mtx.RLock()
+	defer e.mtx.RUnlock()
+
+	return e.ballotBox.Root()
+}
+
+// Registry keeps the elections run by a service.
+type Registry struct {
+	mtx       sync.RWMutex
+	elections map[string]*Election
+}
+
+func NewRegistry() *Registry {
+	return &Registry{elections: make(map[string]*Election)}
+}
+
+// Add registers `e`. The id of an election should be unique in the registry.
+func (r *Registry) Add(e *Election) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	key := hex.EncodeToString(e.ID)
+	if _, ok := r.elections[key]; ok {
+		return fmt.Errorf("the election already exists: %x", e.ID)
+	}
+	r.elections[key] = e
+	return nil
+}
+
+// Get returns the election of `id`, or nil if it does not exist.
+func (r *Registry) Get(id []byte) *Election {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	return r.elections[hex.EncodeToString(id)]
+}
+
+// Len returns the number of the elections.
+func (r *Registry) Len() int {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	return len(r.elections)
+}
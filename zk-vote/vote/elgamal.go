@@ -0,0 +1,163 @@
+package vote
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+	"github.com/kysee/zkp/utils"
+)
+
+// The ballots are encrypted with the exponential ElGamal on the twisted Edwards curve of BN254.
+// A vote `v` for an option is encrypted to (r*G, v*G + r*PK),
+// so the sum of the ciphertexts is the encryption of the sum of the votes.
+
+// Ciphertext is an exponential ElGamal ciphertext.
+type Ciphertext struct {
+	C1, C2 twistededwards.PointAffine
+}
+
+// EncryptedBallot is the encrypted one-hot vector of a ballot.
+// The i-th ciphertext is the encryption of 1 if the i-th choice is chosen, 0 otherwise.
+// The slots after the number of the choices are the encryptions of 0.
+type EncryptedBallot [MaxChoices]Ciphertext
+
+// Hash returns the hash of the ciphertexts of the ballot.
+func (b *EncryptedBallot) Hash() []byte {
+	ins := make([][]byte, 0, 4*MaxChoices)
+	for i := range b {
+		ins = append(ins, b[i].C1.X.Marshal(), b[i].C1.Y.Marshal(), b[i].C2.X.Marshal(), b[i].C2.Y.Marshal())
+	}
+	return utils.MiMCHash(ins...)
+}
+
+// ElectionKey is the key pair the ballots of an election are encrypted with.
+type ElectionKey struct {
+	secret *big.Int
+	PubKey twistededwards.PointAffine
+}
+
+func NewElectionKey() (*ElectionKey, error) {
+	secret, err := randScalar()
+	if err != nil {
+		return nil, err
+	}
+	k := &ElectionKey{secret: secret}
+	base := twistededwards.GetEdwardsCurve().Base
+	k.PubKey.ScalarMultiplication(&base, secret)
+	return k, nil
+}
+
+// Decrypt returns v*G of the ciphertext of `v`.
+func (k *ElectionKey) Decrypt(ct *Ciphertext) *twistededwards.PointAffine {
+	var d twistededwards.PointAffine
+	d.ScalarMultiplication(&ct.C1, k.secret)
+	return decryptWith(ct, &d)
+}
+
+// decryptWith returns C2 - d, where d is secret*C1.
+func decryptWith(ct *Ciphertext, d *twistededwards.PointAffine) *twistededwards.PointAffine {
+	var m twistededwards.PointAffine
+	m.Neg(d)
+	m.Add(&m, &ct.C2)
+	return &m
+}
+
+// inSubgroup returns true if `p` is on the curve and in its subgroup of the prime order.
+// The curve has the cofactor 8, so a point on it may have a component of a small order,
+// which is never cancelled by the scalar multiplications in the prime order.
+func inSubgroup(p *twistededwards.PointAffine) bool {
+	if !p.IsOnCurve() {
+		return false
+	}
+	order := twistededwards.GetEdwardsCurve().Order
+	var q twistededwards.PointAffine
+	q.ScalarMultiplication(p, &order)
+	return q.IsZero()
+}
+
+func randScalar() (*big.Int, error) {
+	order := twistededwards.GetEdwardsCurve().Order
+	for {
+		r, err := rand.Int(rand.Reader, &order)
+		if err != nil {
+			return nil, err
+		}
+		if r.Sign() != 0 {
+			return r, nil
+		}
+	}
+}
+
+// Encrypt returns the encryption of `v` under `pk` with the randomness `r`.
+func Encrypt(pk *twistededwards.PointAffine, v uint64, r *big.Int) *Ciphertext {
+	base := twistededwards.GetEdwardsCurve().Base
+	ct := &Ciphertext{}
+	ct.C1.ScalarMultiplication(&base, r)
+
+	var vG, rPK twistededwards.PointAffine
+	vG.ScalarMultiplication(&base, new(big.Int).SetUint64(v))
+	rPK.ScalarMultiplication(pk, r)
+	ct.C2.Add(&vG, &rPK)
+	return ct
+}
+
+// Add sets `ct` to the sum of `a` and `b`, the encryption of the sum of their votes.
+func (ct *Ciphertext) Add(a, b *Ciphertext) *Ciphertext {
+	ct.C1.Add(&a.C1, &b.C1)
+	ct.C2.Add(&a.C2, &b.C2)
+	return ct
+}
+
+// zeroCiphertext returns the encryption of 0 with no randomness, the identity of `Add`.
+func zeroCiphertext() *Ciphertext {
+	ct := &Ciphertext{}
+	ct.C1.X.SetZero()
+	ct.C1.Y.SetOne()
+	ct.C2.Set(&ct.C1)
+	return ct
+}
+
+// OneHot returns the votes of the ballot for `choice`.
+// All the votes are 0 if `choice` is not one of `choices`.
+func OneHot(choices [][]byte, choice []byte) [MaxChoices]uint64 {
+	var votes [MaxChoices]uint64
+	for i, c := range choices {
+		if i < MaxChoices && new(big.Int).SetBytes(c).Cmp(new(big.Int).SetBytes(choice)) == 0 {
+			votes[i] = 1
+		}
+	}
+	return votes
+}
+
+// EncryptBallot encrypts `votes` under `pk`.
+// It returns the randomness of the ciphertexts too, which is the witness of the ballot proof.
+func EncryptBallot(pk *twistededwards.PointAffine, votes [MaxChoices]uint64) (*EncryptedBallot, [MaxChoices]*big.Int, error) {
+	var ballot EncryptedBallot
+	var randoms [MaxChoices]*big.Int
+	for i, v := range votes {
+		r, err := randScalar()
+		if err != nil {
+			return nil, randoms, err
+		}
+		ballot[i] = *Encrypt(pk, v, r)
+		randoms[i] = r
+	}
+	return &ballot, randoms, nil
+}
+
+// discreteLog returns `v` of v*G, searching it in [0, max].
+func discreteLog(m *twistededwards.PointAffine, max int) (int, error) {
+	base := twistededwards.GetEdwardsCurve().Base
+	var vG twistededwards.PointAffine
+	vG.X.SetZero()
+	vG.Y.SetOne()
+	for v := 0; v <= max; v++ {
+		if vG.Equal(m) {
+			return v, nil
+		}
+		vG.Add(&vG, &base)
+	}
+	return 0, errors.New("the discrete log is out of range")
+}
@@ -0,0 +1,74 @@
+package vote
+
+import (
+	"errors"
+	"time"
+)
+
+// Phase is the phase of an election.
+type Phase int
+
+const (
+	// PhaseRegistration is the phase in which the citizens join the roll before its snapshot.
+	PhaseRegistration Phase = iota
+	// PhaseFrozen is the phase after the roll snapshot is taken and before the voting window starts.
+	PhaseFrozen
+	// PhaseVoting is the phase in which the ballots are accepted.
+	PhaseVoting
+	// PhaseClosed is the phase after the voting window ends and before the tally is sealed.
+	PhaseClosed
+	// PhaseSealed is the last phase; the tally is final.
+	PhaseSealed
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseRegistration:
+		return "registration"
+	case PhaseFrozen:
+		return "frozen"
+	case PhaseVoting:
+		return "voting"
+	case PhaseClosed:
+		return "closed"
+	case PhaseSealed:
+		return "sealed"
+	}
+	return "unknown"
+}
+
+var (
+	ErrWrongPhase      = errors.New("wrong phase of the election")
+	ErrNotSnapshotRoot = errors.New("the citizen root is not of the roll snapshot")
+)
+
+// VotingWindow is the period in which the ballots are accepted.
+// It opens when both of the start time and height are reached,
+// and closes when either of the end time or height is reached.
+// A zero bound is not checked, but at least one of the end bounds should be set.
+type VotingWindow struct {
+	StartTime, EndTime     time.Time
+	StartHeight, EndHeight uint64
+}
+
+func (w *VotingWindow) validate() error {
+	if w.EndTime.IsZero() && w.EndHeight == 0 {
+		return errors.New("the voting window never closes")
+	}
+	if !w.EndTime.IsZero() && !w.StartTime.Before(w.EndTime) {
+		return errors.New("the voting window closes before it opens")
+	}
+	if w.EndHeight != 0 && w.StartHeight >= w.EndHeight {
+		return errors.New("the voting window closes before it opens")
+	}
+	return nil
+}
+
+func (w *VotingWindow) opened(now time.Time, height uint64) bool {
+	return !now.Before(w.StartTime) && height >= w.StartHeight
+}
+
+func (w *VotingWindow) closed(now time.Time, height uint64) bool {
+	return (!w.EndTime.IsZero() && !now.Before(w.EndTime)) ||
+		(w.EndHeight != 0 && height >= w.EndHeight)
+}
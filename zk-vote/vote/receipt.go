@@ -0,0 +1,82 @@
+package vote
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/signature"
+	"github.com/kysee/zkp/utils"
+	"github.com/kysee/zkp/zk-vote/smt"
+)
+
+// BallotBoxDepth is the depth of the ballot box; a vote paper id is the key of its leaf.
+const BallotBoxDepth = 256
+
+// Receipt is the evidence that the ballot of a vote paper is the latest one in the ballot box.
+// It is signed by the ballot box of the election.
+type Receipt struct {
+	ElectionID  []byte
+	VotePaperID []byte
+	BallotHash  []byte
+	// BallotBoxRoot is the root of the ballot box when the receipt is issued,
+	// and Proof is the inclusion proof of the ballot in it.
+	BallotBoxRoot []byte
+	Proof         smt.Proof
+	Sig           []byte
+}
+
+func (r *Receipt) SigHash() []byte {
+	return utils.MiMCHash(r.ElectionID, r.VotePaperID, r.BallotHash, r.BallotBoxRoot)
+}
+
+// BallotBoxPubKey returns the public key the receipts of the election are signed with.
+func (e *Election) BallotBoxPubKey() signature.PublicKey {
+	return e.boxKey.Public()
+}
+
+// Receipt returns the signed receipt of the latest ballot of `votePaperId`.
+func (e *Election) Receipt(votePaperId []byte) (*Receipt, error) {
+	e.mtx.RLock()
+	defer e.mtx.RUnlock()
+
+	vp, ok := e.votePapers[toVotePaperID(votePaperId)]
+	if !ok {
+		return nil, errors.New("no ballot of the vote paper")
+	}
+	proof, err := e.ballotBox.Prove(new(big.Int).SetBytes(votePaperId))
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Receipt{
+		ElectionID:    e.ID,
+		VotePaperID:   vp.VotePaperID[:],
+		BallotHash:    vp.Ballot.Hash(),
+		BallotBoxRoot: e.ballotBox.Root(),
+		Proof:         proof,
+	}
+	if r.Sig, err = e.boxKey.Sign(r.SigHash(), utils.MiMCHasher()); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// VerifyReceipt checks that `r` is signed by the ballot box of `boxPubKey`
+// and proves `ballot` is the latest ballot of the vote paper in the ballot box of `r.BallotBoxRoot`.
+func VerifyReceipt(boxPubKey signature.PublicKey, r *Receipt, ballot *EncryptedBallot) error {
+	if !bytes.Equal(r.BallotHash, ballot.Hash()) {
+		return errors.New("the receipt is not of the ballot")
+	}
+	ok, err := boxPubKey.Verify(r.Sig, r.SigHash(), utils.MiMCHasher())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("wrong signature of the receipt")
+	}
+	if len(r.Proof) != BallotBoxDepth {
+		return errors.New("wrong depth of the inclusion proof")
+	}
+	return r.Proof.Verify(r.BallotBoxRoot, new(big.Int).SetBytes(r.VotePaperID), r.BallotHash)
+}
@@ -0,0 +1,74 @@
+package vote_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/kysee/zkp/zk-vote/smt"
+	"github.com/kysee/zkp/zk-vote/vote"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReceipt(t *testing.T) {
+	e, err := newElection("election-receipt", "question-receipt", choices)
+	require.NoError(t, err)
+	boxPubKey := e.BallotBoxPubKey()
+
+	voter := voterOf(citizens[0], e)
+	_, err = e.Receipt(voter.VotePaperID)
+	require.Error(t, err) // no ballot yet
+
+	proof, ballot, err := voter.VoteProof(e, choices[0])
+	require.NoError(t, err)
+	require.NoError(t, e.DoVote(proof, e.CitizenMerkleRoot(), voter.VotePaperID, ballot))
+
+	receipt, err := e.Receipt(voter.VotePaperID)
+	require.NoError(t, err)
+	require.Equal(t, e.BallotBoxRoot(), receipt.BallotBoxRoot)
+	require.NoError(t, vote.VerifyReceipt(boxPubKey, receipt, ballot))
+
+	// the receipt of the ballot box of another election
+	other, err := newElection("election-receipt-other", "question-receipt", choices)
+	require.NoError(t, err)
+	require.Error(t, vote.VerifyReceipt(other.BallotBoxPubKey(), receipt, ballot))
+
+	// a forged receipt
+	forged := *receipt
+	forged.BallotBoxRoot = e.CitizenMerkleRoot()
+	require.Error(t, vote.VerifyReceipt(boxPubKey, &forged, ballot))
+
+	// another voter's ballot does not change the ballot of the voter.
+	voter1 := voterOf(citizens[1], e)
+	proof, ballot1, err := voter1.VoteProof(e, choices[1])
+	require.NoError(t, err)
+	require.NoError(t, e.DoVote(proof, e.CitizenMerkleRoot(), voter1.VotePaperID, ballot1))
+	receipt1, err := e.Receipt(voter.VotePaperID)
+	require.NoError(t, err)
+	require.NotEqual(t, receipt.BallotBoxRoot, receipt1.BallotBoxRoot)
+	require.NoError(t, vote.VerifyReceipt(boxPubKey, receipt1, ballot))
+
+	// the revote replaces the leaf of the vote paper.
+	proof, revote, err := voter.VoteProof(e, choices[2])
+	require.NoError(t, err)
+	require.NoError(t, e.DoVote(proof, e.CitizenMerkleRoot(), voter.VotePaperID, revote))
+	receipt2, err := e.Receipt(voter.VotePaperID)
+	require.NoError(t, err)
+	require.NoError(t, vote.VerifyReceipt(boxPubKey, receipt2, revote))
+	require.Error(t, vote.VerifyReceipt(boxPubKey, receipt2, ballot))
+	// the former receipt is valid only for the former root.
+	require.NoError(t, vote.VerifyReceipt(boxPubKey, receipt1, ballot))
+	require.Error(t, receipt1.Proof.Verify(e.BallotBoxRoot(), new(big.Int).SetBytes(voter.VotePaperID), ballot.Hash()))
+
+	// the ballot box has one leaf for each vote paper.
+	box := smt.New(vote.BallotBoxDepth)
+	require.NoError(t, box.Set(new(big.Int).SetBytes(voter.VotePaperID), revote.Hash()))
+	require.NoError(t, box.Set(new(big.Int).SetBytes(voter1.VotePaperID), ballot1.Hash()))
+	require.Equal(t, box.Root(), e.BallotBoxRoot())
+
+	// the same vote paper id plus the modulus is not accepted as another vote paper.
+	id := new(big.Int).SetBytes(voter.VotePaperID)
+	id.Add(id, ecc.BN254.ScalarField())
+	require.Error(t, e.DoVote(proof, e.CitizenMerkleRoot(), id.FillBytes(make([]byte, 32)), revote))
+	require.Equal(t, 2, e.GetVotePaperCnt())
+}
@@ -0,0 +1,231 @@
+package vote
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/kysee/zkp/zk-vote/smt"
+)
+
+// Roll is the citizen roll: a sparse Merkle tree whose leaves are the hashes of the DID public keys.
+// A citizen is inserted in the next slot, which is the key of its leaf.
+// Revoking a citizen empties its slot, and rotating the key of a citizen replaces the leaf in its slot,
+// so the roll is updated without rebuilding the tree.
+//
+// The updates of the roll are public, since the leaves are only the commitments to the DID public keys.
+// A citizen follows them to keep its own membership proof; see `Updates`.
+//
+// The roll keeps the history of its roots, so a membership proof made before an update
+// is still checkable until the snapshot, which drops the roots before it.
+// Roll is not safe for concurrent use.
+type Roll struct {
+	tree  *smt.Tree
+	slots map[string]uint64
+	next  uint64
+	// roots is the history of the roots since the last snapshot, the latest last.
+	roots [][]byte
+	// updates is all the updates of the leaves in order.
+	updates []RollUpdate
+}
+
+// RollSource is the roll an election takes the snapshot of, e.g. `gov.Roll`,
+// which admits only the citizens with the credentials of its issuer.
+type RollSource interface {
+	Depth() int
+	Updates(from int) []RollUpdate
+}
+
+// snapshotRoll returns the roll made by replaying all the updates of `src`.
+func snapshotRoll(src RollSource) (*Roll, error) {
+	r := NewRoll(src.Depth())
+	for _, u := range src.Updates(0) {
+		if err := r.apply(u); err != nil {
+			return nil, err
+		}
+	}
+	r.Snapshot()
+	return r, nil
+}
+
+// RollUpdate is the update of a slot of the roll; Leaf is zero if the citizen of the slot is revoked.
+type RollUpdate struct {
+	Slot uint64
+	Leaf []byte
+}
+
+// NewRoll returns the empty roll of 2^depth slots.
+// The depth should be the one `VoteCircuit` is compiled with.
+func NewRoll(depth int) *Roll {
+	tree := smt.New(depth)
+	return &Roll{
+		tree:  tree,
+		slots: make(map[string]uint64),
+		roots: [][]byte{tree.Root()},
+	}
+}
+
+func (r *Roll) Depth() int {
+	return r.tree.Depth()
+}
+
+// Len returns the number of the citizens in the roll.
+func (r *Roll) Len() int {
+	return len(r.slots)
+}
+
+func (r *Roll) Root() []byte {
+	return r.tree.Root()
+}
+
+func (r *Roll) set(slot uint64, leaf []byte) {
+	// the slot is always in the tree and the leaf is checked.
+	_ = r.tree.Set(new(big.Int).SetUint64(slot), leaf)
+	r.roots = append(r.roots, r.tree.Root())
+	r.updates = append(r.updates, RollUpdate{Slot: slot, Leaf: append([]byte(nil), leaf...)})
+}
+
+// apply applies `u`, an update of another roll.
+func (r *Roll) apply(u RollUpdate) error {
+	key := new(big.Int).SetUint64(u.Slot)
+	if key.BitLen() > r.Depth() {
+		return errors.New("the slot is out of the roll")
+	}
+	empty := make([]byte, smt.LeafSize)
+	if !bytes.Equal(u.Leaf, empty) {
+		if err := r.checkCitizen(u.Leaf); err != nil {
+			return err
+		}
+	}
+	old, _ := r.tree.Get(key)
+	delete(r.slots, string(old))
+	if !bytes.Equal(u.Leaf, empty) {
+		r.slots[string(u.Leaf)] = u.Slot
+	}
+	if u.Slot >= r.next {
+		r.next = u.Slot + 1
+	}
+	r.set(u.Slot, u.Leaf)
+	return nil
+}
+
+// Updates returns the updates of the roll from the `from`-th one.
+// Applying all of them to the empty tree of the same depth makes the current tree.
+func (r *Roll) Updates(from int) []RollUpdate {
+	if from < 0 || from > len(r.updates) {
+		return nil
+	}
+	return append([]RollUpdate(nil), r.updates[from:]...)
+}
+
+func (r *Roll) checkCitizen(citizen []byte) error {
+	if len(citizen) != smt.LeafSize {
+		return fmt.Errorf("wrong size of the citizen: %d", len(citizen))
+	}
+	if checkVotePaperID(citizen) != nil || bytes.Equal(citizen, make([]byte, smt.LeafSize)) {
+		return errors.New("the citizen is not a hash")
+	}
+	if _, ok := r.slots[string(citizen)]; ok {
+		return fmt.Errorf("duplicated citizen: %x", citizen)
+	}
+	return nil
+}
+
+// Insert adds `citizen`, the hash of a DID public key, in the next slot and returns the slot.
+func (r *Roll) Insert(citizen []byte) (uint64, error) {
+	if err := r.checkCitizen(citizen); err != nil {
+		return 0, err
+	}
+	if new(big.Int).SetUint64(r.next).BitLen() > r.Depth() {
+		return 0, errors.New("the roll is full")
+	}
+	slot := r.next
+	r.next++
+	r.slots[string(citizen)] = slot
+	r.set(slot, citizen)
+	return slot, nil
+}
+
+// Revoke removes `citizen` from the roll. Its slot is never used again.
+func (r *Roll) Revoke(citizen []byte) error {
+	slot, ok := r.slots[string(citizen)]
+	if !ok {
+		return errors.New("not found the citizen")
+	}
+	delete(r.slots, string(citizen))
+	r.set(slot, make([]byte, smt.LeafSize))
+	return nil
+}
+
+// Rotate replaces the citizen `from` with `to`, the hash of its new DID public key, in its slot.
+func (r *Roll) Rotate(from, to []byte) error {
+	slot, ok := r.slots[string(from)]
+	if !ok {
+		return errors.New("not found the citizen")
+	}
+	if err := r.checkCitizen(to); err != nil {
+		return err
+	}
+	delete(r.slots, string(from))
+	r.slots[string(to)] = slot
+	r.set(slot, to)
+	return nil
+}
+
+// Leaves returns the citizens in the order of their slots.
+func (r *Roll) Leaves() [][]byte {
+	leaves := make([][]byte, 0, len(r.slots))
+	for slot := uint64(0); slot < r.next; slot++ {
+		leaf, _ := r.tree.Get(new(big.Int).SetUint64(slot))
+		if !bytes.Equal(leaf, make([]byte, smt.LeafSize)) {
+			leaves = append(leaves, leaf)
+		}
+	}
+	return leaves
+}
+
+// Prove returns the slot of `citizen` and its membership proof against the current root.
+func (r *Roll) Prove(citizen []byte) (uint64, smt.Proof, error) {
+	slot, ok := r.slots[string(citizen)]
+	if !ok {
+		return 0, nil, errors.New("not found the citizen")
+	}
+	proof, err := r.tree.Prove(new(big.Int).SetUint64(slot))
+	if err != nil {
+		return 0, nil, err
+	}
+	return slot, proof, nil
+}
+
+// IsKnownRoot returns true if `root` is one of the roots since the last snapshot.
+func (r *Roll) IsKnownRoot(root []byte) bool {
+	for _, _root := range r.roots {
+		if bytes.Equal(_root, root) {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify checks that `citizen` is in `slot` of the roll of `root`, which should be a known root.
+func (r *Roll) Verify(root []byte, slot uint64, citizen []byte, proof smt.Proof) error {
+	if !r.IsKnownRoot(root) {
+		return errors.New("unknown root of the roll")
+	}
+	if len(proof) != r.Depth() {
+		return errors.New("wrong depth of the membership proof")
+	}
+	if bytes.Equal(citizen, make([]byte, smt.LeafSize)) {
+		return errors.New("the empty slot")
+	}
+	return proof.Verify(root, new(big.Int).SetUint64(slot), citizen)
+}
+
+// Snapshot drops the history of the roots and returns the current root.
+// The proofs made before the snapshot are not checkable any more.
+func (r *Roll) Snapshot() []byte {
+	root := r.tree.Root()
+	r.roots = [][]byte{root}
+	return root
+}
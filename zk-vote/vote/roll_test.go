@@ -0,0 +1,226 @@
+package vote_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"github.com/kysee/zkp/utils"
+	"github.com/kysee/zkp/zk-vote/gov"
+	"github.com/kysee/zkp/zk-vote/vote"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoll_Updates(t *testing.T) {
+	r := vote.NewRoll(2)
+	emptyRoot := r.Root()
+	for i, c := range citizens[:4] {
+		slot, err := r.Insert(c.HashDIDPubKey())
+		require.NoError(t, err)
+		require.Equal(t, uint64(i), slot)
+	}
+	_, err := r.Insert(citizens[4].HashDIDPubKey())
+	require.Error(t, err) // full
+	_, err = r.Insert(citizens[0].HashDIDPubKey())
+	require.Error(t, err) // duplicated
+	_, err = r.Insert(make([]byte, 32))
+	require.Error(t, err)
+
+	root0 := r.Root()
+	slot, proof0, err := r.Prove(citizens[1].HashDIDPubKey())
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), slot)
+	require.NoError(t, r.Verify(root0, slot, citizens[1].HashDIDPubKey(), proof0))
+	require.Error(t, r.Verify(root0, slot, citizens[2].HashDIDPubKey(), proof0))
+	require.Error(t, r.Verify(emptyRoot, slot, citizens[1].HashDIDPubKey(), proof0))
+
+	// revoke
+	require.NoError(t, r.Revoke(citizens[1].HashDIDPubKey()))
+	require.Error(t, r.Revoke(citizens[1].HashDIDPubKey()))
+	_, _, err = r.Prove(citizens[1].HashDIDPubKey())
+	require.Error(t, err)
+	root1 := r.Root()
+	require.NotEqual(t, root0, root1)
+	// the proof made before the revocation is still checked against the former root.
+	require.NoError(t, r.Verify(root0, slot, citizens[1].HashDIDPubKey(), proof0))
+	require.Error(t, r.Verify(root1, slot, citizens[1].HashDIDPubKey(), proof0))
+	_, err = r.Insert(citizens[4].HashDIDPubKey())
+	require.Error(t, err) // the revoked slot is not used again.
+
+	// rotate
+	voter := *citizens[2]
+	from := voter.RotateDIDKey()
+	require.Equal(t, citizens[2].HashDIDPubKey(), from)
+	require.Error(t, r.Rotate(from, citizens[3].HashDIDPubKey()))
+	require.NoError(t, r.Rotate(from, voter.HashDIDPubKey()))
+	slot, proof2, err := r.Prove(voter.HashDIDPubKey())
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), slot)
+	require.NoError(t, r.Verify(r.Root(), slot, voter.HashDIDPubKey(), proof2))
+	_, _, err = r.Prove(from)
+	require.Error(t, err)
+
+	require.Equal(t, [][]byte{citizens[0].HashDIDPubKey(), voter.HashDIDPubKey(), citizens[3].HashDIDPubKey()}, r.Leaves())
+	require.Equal(t, 3, r.Len())
+
+	// the snapshot drops the former roots.
+	root := r.Snapshot()
+	require.Equal(t, r.Root(), root)
+	require.False(t, r.IsKnownRoot(root0))
+	require.False(t, r.IsKnownRoot(root1))
+	require.Error(t, r.Verify(root0, 1, citizens[1].HashDIDPubKey(), proof0))
+	require.NoError(t, r.Verify(root, slot, voter.HashDIDPubKey(), proof2))
+}
+
+func TestElection_RollUpdates(t *testing.T) {
+	e, err := vote.NewElection("election-roll", "question-roll", choices, &electionKey.PubKey, keys)
+	require.NoError(t, err)
+	r, err := rollOf(citizens[:len(citizens)/2])
+	require.NoError(t, err)
+
+	revoked := voterOf(citizens[0], e)
+	rotated := voterOf(citizens[1], e)
+	root0 := r.MerkleRootHash()
+	tracker := gov.NewRollTracker(merkleCitizensDepth, revoked.HashDIDPubKey())
+	require.NoError(t, tracker.Apply(r.Updates(0)))
+	revokedIdx, revokedPath, err := tracker.Proof()
+	require.NoError(t, err)
+
+	// the roll is updated in the registration phase of the election.
+	require.NoError(t, r.RevokeCitizen(revoked.HashDIDPubKey()))
+	from := rotated.RotateDIDKey()
+	require.Error(t, r.RotateCitizen(from, &gov.Credential{DIDPubKey: rotated.DIDPubKey.Bytes()}))
+	require.NoError(t, rotated.RequestCredential(issuer, gov.Attributes{Name: "Name-1", SN: "SN-1"}))
+	require.NoError(t, r.RotateCitizen(from, rotated.Credential))
+	_, err = r.RegisterCitizen(citizens[len(citizens)/2].Credential)
+	require.NoError(t, err)
+	require.NoError(t, r.VerifyCitizen(root0, revokedIdx, revoked.HashDIDPubKey(), revokedPath))
+
+	require.Error(t, e.VerifyCitizen(root0, revokedIdx, revoked.HashDIDPubKey(), revokedPath))
+	require.NoError(t, e.Freeze(r))
+	require.Equal(t, r.MerkleRootHash(), e.CitizenMerkleRoot())
+	require.Error(t, e.VerifyCitizen(root0, revokedIdx, revoked.HashDIDPubKey(), revokedPath))
+	idx, path, err := e.CitizenMerkleProof(citizens[2].HashDIDPubKey())
+	require.NoError(t, err)
+	require.NoError(t, e.VerifyCitizen(e.CitizenMerkleRoot(), idx, citizens[2].HashDIDPubKey(), path))
+
+	// the updates of the roll after the snapshot do not reach the election.
+	require.NoError(t, r.RevokeCitizen(citizens[2].HashDIDPubKey()))
+	require.NotEqual(t, r.MerkleRootHash(), e.CitizenMerkleRoot())
+	require.NoError(t, e.VerifyCitizen(e.CitizenMerkleRoot(), idx, citizens[2].HashDIDPubKey(), path))
+	require.NoError(t, e.OpenVoting(vote.VotingWindow{EndHeight: votingEndHeight}))
+
+	var circuit vote.VoteCircuit
+	circuit.SetCurveId(utils.CURVEID)
+	circuit.CitizenMerklePath = make([]frontend.Variable, merkleCitizensDepth)
+
+	// the revoked citizen is not in the snapshot.
+	_, _, err = revoked.VoteProof(e, choices[0])
+	require.Error(t, err)
+	assignment := voteAssignment(t, citizens[2], e, choices[0])
+	assignment.AssignPubKey(revoked.DIDPubKey)
+	s0, s1 := revoked.GetPrvScalar()
+	assignment.S0, assignment.S1 = s0, s1
+	assignment.VotePaperID = revoked.VotePaperID
+	sig, err := revoked.DIDPrvKey.Sign(choices[0], utils.MiMCHasher())
+	require.NoError(t, err)
+	assignment.AssignSig(sig)
+	assignment.LeafIdx = revokedIdx
+	for i := range revokedPath {
+		assignment.CitizenMerklePath[i] = revokedPath[i]
+	}
+	require.Error(t, test.IsSolved(&circuit, assignment, ecc.BN254.ScalarField()))
+	// the proof before the revocation is only of the former root, which is not of the snapshot.
+	assignment.CitizenMerkleRoot = root0
+	require.NoError(t, test.IsSolved(&circuit, assignment, ecc.BN254.ScalarField()))
+
+	// the former key of the rotated citizen is not in the snapshot, but the new one is.
+	former := voterOf(citizens[1], e)
+	_, _, err = former.VoteProof(e, choices[1])
+	require.Error(t, err)
+
+	proof, ballot, err := rotated.VoteProof(e, choices[1])
+	require.NoError(t, err)
+	require.NoError(t, e.DoVote(proof, e.CitizenMerkleRoot(), rotated.VotePaperID, ballot))
+
+	newcomer := voterOf(citizens[len(citizens)/2], e)
+	proof, ballot, err = newcomer.VoteProof(e, choices[2])
+	require.NoError(t, err)
+	require.NoError(t, e.DoVote(proof, e.CitizenMerkleRoot(), newcomer.VotePaperID, ballot))
+
+	require.Equal(t, []int{0, 1, 1}, sealTally(t, e))
+}
+
+func TestGovRoll_Credentials(t *testing.T) {
+	r := gov.NewRoll(merkleCitizensDepth, issuer.PubKey())
+	cs := make([]*gov.Citizen, 3)
+	for i := range cs {
+		cs[i] = gov.NewCitizen()
+		require.NoError(t, cs[i].RequestCredential(issuer, gov.Attributes{Name: fmt.Sprintf("Name-%d", i), SN: fmt.Sprintf("SN-%d", i)}))
+		require.Equal(t, cs[i].HashDIDPubKey(), cs[i].Credential.Commitment())
+		slot, err := r.RegisterCitizen(cs[i].Credential)
+		require.NoError(t, err)
+		require.Equal(t, uint64(i), slot)
+	}
+	// the roll keeps only the commitments.
+	require.Equal(t, [][]byte{cs[0].HashDIDPubKey(), cs[1].HashDIDPubKey(), cs[2].HashDIDPubKey()}, r.Leaves())
+	_, err := r.RegisterCitizen(cs[0].Credential)
+	require.Error(t, err) // duplicated
+
+	// the credentials which are not issued by the issuer of the roll
+	c := gov.NewCitizen()
+	other, err := gov.NewIssuer()
+	require.NoError(t, err)
+	require.NoError(t, c.RequestCredential(other, gov.Attributes{Name: "Name", SN: "SN"}))
+	_, err = r.RegisterCitizen(c.Credential)
+	require.Error(t, err)
+	require.NoError(t, c.RequestCredential(issuer, gov.Attributes{Name: "Name", SN: "SN"}))
+	forged := *c.Credential
+	forged.Attributes.SN = "SN-forged"
+	_, err = r.RegisterCitizen(&forged)
+	require.Error(t, err)
+	forged = *c.Credential
+	forged.DIDPubKey = cs[0].DIDPubKey.Bytes()
+	_, err = r.RegisterCitizen(&forged)
+	require.Error(t, err)
+	// the attributes are hashed with their lengths, so the padding of MiMC does not make the same hash.
+	forged = *c.Credential
+	forged.Attributes.Name = "\x00Name"
+	require.Error(t, forged.Verify(issuer.PubKey()))
+	require.NoError(t, c.Credential.Verify(issuer.PubKey()))
+
+	root0 := r.MerkleRootHash()
+	tracker := gov.NewRollTracker(merkleCitizensDepth, cs[2].HashDIDPubKey())
+	require.NoError(t, tracker.Apply(r.Updates(0)))
+	require.Equal(t, root0, tracker.Root())
+	idx, path, err := tracker.Proof()
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), idx)
+
+	require.NoError(t, r.RevokeCitizen(cs[0].HashDIDPubKey()))
+	from := cs[1].RotateDIDKey()
+	require.Nil(t, cs[1].Credential)
+	require.NoError(t, cs[1].RequestCredential(issuer, gov.Attributes{Name: "Name-1", SN: "SN-1"}))
+	require.Error(t, r.RotateCitizen(from, &forged))
+	require.NoError(t, r.RotateCitizen(from, cs[1].Credential))
+	require.Equal(t, [][]byte{cs[1].HashDIDPubKey(), cs[2].HashDIDPubKey()}, r.Leaves())
+
+	// the proof made before the updates is checked against the former root.
+	require.NoError(t, r.VerifyCitizen(root0, idx, cs[2].HashDIDPubKey(), path))
+	require.Error(t, r.VerifyCitizen(r.MerkleRootHash(), idx, cs[2].HashDIDPubKey(), path))
+
+	// the citizen follows the updates to make the proof against the current root.
+	require.NoError(t, tracker.Apply(r.Updates(tracker.Applied())))
+	require.Equal(t, r.MerkleRootHash(), tracker.Root())
+	idx, path, err = tracker.Proof()
+	require.NoError(t, err)
+	require.NoError(t, r.VerifyCitizen(r.MerkleRootHash(), idx, cs[2].HashDIDPubKey(), path))
+
+	// the revoked citizen is not in the tracked roll any more.
+	revoked := gov.NewRollTracker(merkleCitizensDepth, cs[0].HashDIDPubKey())
+	require.NoError(t, revoked.Apply(r.Updates(0)))
+	_, _, err = revoked.Proof()
+	require.Error(t, err)
+}
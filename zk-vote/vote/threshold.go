@@ -0,0 +1,443 @@
+package vote
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+	"github.com/kysee/zkp/utils"
+)
+
+// The key of an election can be shared among N trustees, so no one can decrypt the ballots alone.
+// The trustees run the distributed key generation (DKG) of Pedersen with Feldman's commitments:
+// trustee j deals f_j(i) to trustee i, where f_j is a random polynomial of degree t-1,
+// and publishes the commitments f_j's coefficients*G.
+// The secret key is sum of f_j(0), which nobody knows,
+// and the share of trustee i is sum of f_j(i), so any t of the trustees can decrypt.
+
+// TallyDecrypter makes the verifiable decryption of the encrypted tally of an election.
+type TallyDecrypter interface {
+	DecryptTally(cts []*Ciphertext) (*ThresholdDecryption, error)
+}
+
+// ThresholdKey returns the key as the 1-of-1 threshold key, whose polynomial is the constant secret.
+func (k *ElectionKey) ThresholdKey() *ThresholdKey {
+	return &ThresholdKey{
+		Threshold:   1,
+		Commitments: [][]twistededwards.PointAffine{{k.PubKey}},
+	}
+}
+
+// DecryptTally returns the decryption of `cts` by the only trustee, the holder of the key.
+func (k *ElectionKey) DecryptTally(cts []*Ciphertext) (*ThresholdDecryption, error) {
+	pd, err := partialDecrypt(1, k.secret, cts)
+	if err != nil {
+		return nil, err
+	}
+	return &ThresholdDecryption{Key: k.ThresholdKey(), Partials: []*PartialDecryption{pd}}, nil
+}
+
+// ThresholdKey is the public outcome of the DKG.
+type ThresholdKey struct {
+	Threshold int
+	// Commitments[j] is the commitments of the polynomial dealt by the trustee of index j+1.
+	Commitments [][]twistededwards.PointAffine
+}
+
+// validate checks that all the commitments are in the subgroup of the curve.
+func (k *ThresholdKey) validate() error {
+	if k.Threshold < 1 || k.Threshold > len(k.Commitments) {
+		return fmt.Errorf("wrong threshold: %d of %d", k.Threshold, len(k.Commitments))
+	}
+	for _, c := range k.Commitments {
+		if len(c) != k.Threshold {
+			return fmt.Errorf("wrong number of the commitments: %d", len(c))
+		}
+		for i := range c {
+			if !inSubgroup(&c[i]) {
+				return errors.New("the commitment is not in the subgroup of the curve")
+			}
+		}
+	}
+	return nil
+}
+
+// PubKey returns the public key of the election.
+func (k *ThresholdKey) PubKey() *twistededwards.PointAffine {
+	var pk twistededwards.PointAffine
+	pk.X.SetZero()
+	pk.Y.SetOne()
+	for _, c := range k.Commitments {
+		pk.Add(&pk, &c[0])
+	}
+	return &pk
+}
+
+// PubShare returns x_i*G, where x_i is the key share of the trustee of `index`.
+func (k *ThresholdKey) PubShare(index int) *twistededwards.PointAffine {
+	var pk twistededwards.PointAffine
+	pk.X.SetZero()
+	pk.Y.SetOne()
+	for _, c := range k.Commitments {
+		y := evalCommitments(c, index)
+		pk.Add(&pk, y)
+	}
+	return &pk
+}
+
+// evalCommitments returns f(index)*G from the commitments of f.
+func evalCommitments(commitments []twistededwards.PointAffine, index int) *twistededwards.PointAffine {
+	order := twistededwards.GetEdwardsCurve().Order
+	var y, t twistededwards.PointAffine
+	y.X.SetZero()
+	y.Y.SetOne()
+	pow := big.NewInt(1)
+	for i := range commitments {
+		t.ScalarMultiplication(&commitments[i], pow)
+		y.Add(&y, &t)
+		pow.Mul(pow, big.NewInt(int64(index)))
+		pow.Mod(pow, &order)
+	}
+	return &y
+}
+
+// Trustee is one of the N trustees who share the key of an election.
+// Its index is in [1, N].
+type Trustee struct {
+	Index     int
+	threshold int
+	n         int
+
+	mtx  sync.Mutex
+	poly []*big.Int
+	// the commitments and the shares dealt to this trustee, by the index of the dealer.
+	commitments map[int][]twistededwards.PointAffine
+	shares      map[int]*big.Int
+	secret      *big.Int
+	key         *ThresholdKey
+}
+
+// NewTrustee returns the trustee of `index` among `n` trustees, any `threshold` of whom can decrypt.
+// It samples the polynomial to deal.
+func NewTrustee(index, threshold, n int) (*Trustee, error) {
+	if threshold < 1 || threshold > n {
+		return nil, fmt.Errorf("wrong threshold: %d of %d", threshold, n)
+	}
+	if index < 1 || index > n {
+		return nil, fmt.Errorf("wrong index of the trustee: %d", index)
+	}
+
+	tr := &Trustee{
+		Index:       index,
+		threshold:   threshold,
+		n:           n,
+		poly:        make([]*big.Int, threshold),
+		commitments: make(map[int][]twistededwards.PointAffine),
+		shares:      make(map[int]*big.Int),
+	}
+	for i := range tr.poly {
+		a, err := randScalar()
+		if err != nil {
+			return nil, err
+		}
+		tr.poly[i] = a
+	}
+	tr.commitments[index] = tr.Commitments()
+	tr.shares[index] = tr.ShareFor(index)
+	return tr, nil
+}
+
+// Commitments returns the commitments of the polynomial of the trustee, which are broadcast to all.
+func (tr *Trustee) Commitments() []twistededwards.PointAffine {
+	base := twistededwards.GetEdwardsCurve().Base
+	commitments := make([]twistededwards.PointAffine, len(tr.poly))
+	for i, a := range tr.poly {
+		commitments[i].ScalarMultiplication(&base, a)
+	}
+	return commitments
+}
+
+// ShareFor returns the share dealt to the trustee of `index`. It should be sent in private.
+func (tr *Trustee) ShareFor(index int) *big.Int {
+	order := twistededwards.GetEdwardsCurve().Order
+	x := big.NewInt(int64(index))
+	share := new(big.Int)
+	for i := len(tr.poly) - 1; i >= 0; i-- {
+		share.Mul(share, x)
+		share.Add(share, tr.poly[i])
+		share.Mod(share, &order)
+	}
+	return share
+}
+
+// ReceiveShare verifies the share dealt by the trustee of `from` with its commitments and keeps it.
+func (tr *Trustee) ReceiveShare(from int, commitments []twistededwards.PointAffine, share *big.Int) error {
+	if from < 1 || from > tr.n {
+		return fmt.Errorf("wrong index of the dealer: %d", from)
+	}
+	if len(commitments) != tr.threshold {
+		return fmt.Errorf("wrong number of the commitments: %d", len(commitments))
+	}
+	for i := range commitments {
+		if !inSubgroup(&commitments[i]) {
+			return errors.New("the commitment is not in the subgroup of the curve")
+		}
+	}
+
+	base := twistededwards.GetEdwardsCurve().Base
+	var y twistededwards.PointAffine
+	y.ScalarMultiplication(&base, share)
+	if !y.Equal(evalCommitments(commitments, tr.Index)) {
+		return fmt.Errorf("the share of the trustee %d does not match its commitments", from)
+	}
+
+	tr.mtx.Lock()
+	defer tr.mtx.Unlock()
+
+	if tr.key != nil {
+		return errors.New("the DKG is already finished")
+	}
+	if _, ok := tr.shares[from]; ok {
+		return fmt.Errorf("the share of the trustee %d is already received", from)
+	}
+	tr.commitments[from] = commitments
+	tr.shares[from] = new(big.Int).Set(share)
+	return nil
+}
+
+// Finish makes the key share of the trustee from the shares of all trustees.
+func (tr *Trustee) Finish() (*ThresholdKey, error) {
+	tr.mtx.Lock()
+	defer tr.mtx.Unlock()
+
+	if tr.key != nil {
+		return tr.key, nil
+	}
+	if len(tr.shares) != tr.n {
+		return nil, fmt.Errorf("received %d of %d shares", len(tr.shares), tr.n)
+	}
+
+	order := twistededwards.GetEdwardsCurve().Order
+	key := &ThresholdKey{Threshold: tr.threshold, Commitments: make([][]twistededwards.PointAffine, tr.n)}
+	secret := new(big.Int)
+	for j := 1; j <= tr.n; j++ {
+		key.Commitments[j-1] = tr.commitments[j]
+		secret.Add(secret, tr.shares[j])
+	}
+	tr.secret = secret.Mod(secret, &order)
+	tr.key = key
+	return key, nil
+}
+
+// PartialDecryption is the share of the decryption made by a trustee.
+type PartialDecryption struct {
+	Index int
+	// D[i] is x*C1 of the i-th ciphertext, where x is the key share of the trustee.
+	D      []twistededwards.PointAffine
+	Proofs []*ChaumPedersenProof
+}
+
+// PartialDecrypt returns the partial decryption of `cts` with the proofs of its correctness.
+func (tr *Trustee) PartialDecrypt(cts []*Ciphertext) (*PartialDecryption, error) {
+	tr.mtx.Lock()
+	defer tr.mtx.Unlock()
+
+	if tr.secret == nil {
+		return nil, errors.New("the DKG is not finished")
+	}
+	return partialDecrypt(tr.Index, tr.secret, cts)
+}
+
+func partialDecrypt(index int, secret *big.Int, cts []*Ciphertext) (*PartialDecryption, error) {
+	base := twistededwards.GetEdwardsCurve().Base
+	var pubShare twistededwards.PointAffine
+	pubShare.ScalarMultiplication(&base, secret)
+
+	pd := &PartialDecryption{
+		Index:  index,
+		D:      make([]twistededwards.PointAffine, len(cts)),
+		Proofs: make([]*ChaumPedersenProof, len(cts)),
+	}
+	for i, ct := range cts {
+		pd.D[i].ScalarMultiplication(&ct.C1, secret)
+		proof, err := proveChaumPedersen(secret, &pubShare, &ct.C1, &pd.D[i])
+		if err != nil {
+			return nil, err
+		}
+		pd.Proofs[i] = proof
+	}
+	return pd, nil
+}
+
+// Verify checks that `pd` is the partial decryption of `cts` made with the key share of its trustee.
+func (pd *PartialDecryption) Verify(key *ThresholdKey, cts []*Ciphertext) error {
+	if pd.Index < 1 || pd.Index > len(key.Commitments) {
+		return fmt.Errorf("wrong index of the trustee: %d", pd.Index)
+	}
+	if len(pd.D) != len(cts) || len(pd.Proofs) != len(cts) {
+		return errors.New("wrong number of the partial decryptions")
+	}
+	pubShare := key.PubShare(pd.Index)
+	for i, ct := range cts {
+		if !inSubgroup(&pd.D[i]) {
+			return errors.New("the partial decryption is not in the subgroup of the curve")
+		}
+		if pd.Proofs[i] == nil || !pd.Proofs[i].verify(pubShare, &ct.C1, &pd.D[i]) {
+			return fmt.Errorf("wrong partial decryption of the trustee %d", pd.Index)
+		}
+	}
+	return nil
+}
+
+// ThresholdDecryption combines the partial decryptions of at least `Key.Threshold` trustees.
+type ThresholdDecryption struct {
+	Key      *ThresholdKey
+	Partials []*PartialDecryption
+}
+
+// DecryptTally returns `td` itself; its partial decryptions are verified when they are combined.
+func (td *ThresholdDecryption) DecryptTally(cts []*Ciphertext) (*ThresholdDecryption, error) {
+	return td, nil
+}
+
+// Combine verifies the partial decryptions of `cts` and combines them with the Lagrange coefficients.
+// It returns v*G of the ciphertext of v for each of `cts`.
+// The partial decryptions which are wrong or duplicated are ignored.
+func (td *ThresholdDecryption) Combine(cts []*Ciphertext) ([]*twistededwards.PointAffine, error) {
+	if td.Key == nil {
+		return nil, errors.New("no threshold key")
+	}
+	if err := td.Key.validate(); err != nil {
+		return nil, err
+	}
+	var partials []*PartialDecryption
+	seen := make(map[int]bool)
+	for _, pd := range td.Partials {
+		if len(partials) == td.Key.Threshold {
+			break
+		}
+		if pd == nil || seen[pd.Index] || pd.Verify(td.Key, cts) != nil {
+			continue
+		}
+		seen[pd.Index] = true
+		partials = append(partials, pd)
+	}
+	if len(partials) < td.Key.Threshold {
+		return nil, fmt.Errorf("not enough partial decryptions: %d of %d", len(partials), td.Key.Threshold)
+	}
+
+	indices := make([]int, len(partials))
+	for i, pd := range partials {
+		indices[i] = pd.Index
+	}
+	ms := make([]*twistededwards.PointAffine, len(cts))
+	for i, ct := range cts {
+		var d, t twistededwards.PointAffine
+		d.X.SetZero()
+		d.Y.SetOne()
+		for _, pd := range partials {
+			t.ScalarMultiplication(&pd.D[i], lagrangeAtZero(pd.Index, indices))
+			d.Add(&d, &t)
+		}
+		ms[i] = decryptWith(ct, &d)
+	}
+	return ms, nil
+}
+
+// decryptTally returns the counts of `cts` encrypted under `pubKey` by `decryption`.
+// Every count is at most `max`, the number of the ballots.
+func decryptTally(pubKey *twistededwards.PointAffine, cts []*Ciphertext, decryption *ThresholdDecryption, max int) ([]int, error) {
+	if decryption == nil || decryption.Key == nil {
+		return nil, errors.New("no decryption of the tally")
+	}
+	if !decryption.Key.PubKey().Equal(pubKey) {
+		return nil, errors.New("not the key of the election")
+	}
+	ms, err := decryption.Combine(cts)
+	if err != nil {
+		return nil, err
+	}
+	tally := make([]int, len(ms))
+	for i, m := range ms {
+		if tally[i], err = discreteLog(m, max); err != nil {
+			return nil, err
+		}
+	}
+	return tally, nil
+}
+
+// lagrangeAtZero returns the Lagrange coefficient of `index` among `indices` at 0.
+func lagrangeAtZero(index int, indices []int) *big.Int {
+	order := twistededwards.GetEdwardsCurve().Order
+	num, den := big.NewInt(1), big.NewInt(1)
+	for _, j := range indices {
+		if j == index {
+			continue
+		}
+		num.Mul(num, big.NewInt(int64(j)))
+		num.Mod(num, &order)
+		den.Mul(den, big.NewInt(int64(j-index)))
+		den.Mod(den, &order)
+	}
+	den.ModInverse(den, &order)
+	return num.Mul(num, den).Mod(num, &order)
+}
+
+// ChaumPedersenProof proves log_G(X) == log_C(D) without revealing it.
+type ChaumPedersenProof struct {
+	A, B twistededwards.PointAffine
+	Z    *big.Int
+}
+
+// proveChaumPedersen proves X = x*G and D = x*C.
+func proveChaumPedersen(x *big.Int, X, C, D *twistededwards.PointAffine) (*ChaumPedersenProof, error) {
+	order := twistededwards.GetEdwardsCurve().Order
+	base := twistededwards.GetEdwardsCurve().Base
+	w, err := randScalar()
+	if err != nil {
+		return nil, err
+	}
+	proof := &ChaumPedersenProof{}
+	proof.A.ScalarMultiplication(&base, w)
+	proof.B.ScalarMultiplication(C, w)
+
+	c := chaumPedersenChallenge(X, C, D, &proof.A, &proof.B)
+	proof.Z = c.Mul(c, x)
+	proof.Z.Add(proof.Z, w)
+	proof.Z.Mod(proof.Z, &order)
+	return proof, nil
+}
+
+// verify checks z*G == A + c*X and z*C == B + c*D.
+func (p *ChaumPedersenProof) verify(X, C, D *twistededwards.PointAffine) bool {
+	if p.Z == nil || !inSubgroup(&p.A) || !inSubgroup(&p.B) {
+		return false
+	}
+	base := twistededwards.GetEdwardsCurve().Base
+	c := chaumPedersenChallenge(X, C, D, &p.A, &p.B)
+
+	var l, r twistededwards.PointAffine
+	l.ScalarMultiplication(&base, p.Z)
+	r.ScalarMultiplication(X, c)
+	r.Add(&r, &p.A)
+	if !l.Equal(&r) {
+		return false
+	}
+	l.ScalarMultiplication(C, p.Z)
+	r.ScalarMultiplication(D, c)
+	r.Add(&r, &p.B)
+	return l.Equal(&r)
+}
+
+// chaumPedersenChallenge is the Fiat-Shamir challenge of the proof.
+func chaumPedersenChallenge(points ...*twistededwards.PointAffine) *big.Int {
+	order := twistededwards.GetEdwardsCurve().Order
+	var ins [][]byte
+	for _, p := range points {
+		ins = append(ins, p.X.Marshal(), p.Y.Marshal())
+	}
+	c := new(big.Int).SetBytes(utils.MiMCHash(ins...))
+	return c.Mod(c, &order)
+}
@@ -0,0 +1,296 @@
+package vote_test
+
+import (
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+	"github.com/kysee/zkp/utils"
+	"github.com/kysee/zkp/zk-vote/vote"
+	"github.com/stretchr/testify/require"
+)
+
+type dkgMsg struct {
+	from        int
+	commitments []twistededwards.PointAffine
+	share       *big.Int
+}
+
+// runDKG runs the DKG among `n` trustees, each of whom is a goroutine exchanging messages over channels.
+func runDKG(t *testing.T, threshold, n int) ([]*vote.Trustee, *vote.ThresholdKey) {
+	trustees := make([]*vote.Trustee, n)
+	inboxes := make([]chan dkgMsg, n)
+	for i := range trustees {
+		tr, err := vote.NewTrustee(i+1, threshold, n)
+		require.NoError(t, err)
+		trustees[i] = tr
+		inboxes[i] = make(chan dkgMsg, n)
+	}
+
+	thresholdKeys := make([]*vote.ThresholdKey, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i, tr := range trustees {
+		wg.Add(1)
+		go func(i int, tr *vote.Trustee) {
+			defer wg.Done()
+			for j := range trustees {
+				if j != i {
+					inboxes[j] <- dkgMsg{from: tr.Index, commitments: tr.Commitments(), share: tr.ShareFor(j + 1)}
+				}
+			}
+			for k := 0; k < n-1; k++ {
+				msg := <-inboxes[i]
+				if errs[i] = tr.ReceiveShare(msg.from, msg.commitments, msg.share); errs[i] != nil {
+					return
+				}
+			}
+			thresholdKeys[i], errs[i] = tr.Finish()
+		}(i, tr)
+	}
+	wg.Wait()
+
+	for i := range trustees {
+		require.NoError(t, errs[i])
+		// all trustees agree on the key.
+		require.Equal(t, thresholdKeys[0], thresholdKeys[i])
+	}
+	return trustees, thresholdKeys[0]
+}
+
+// partialDecrypt returns the partial decryptions of `cts` made by `trustees` at once.
+func partialDecrypt(t *testing.T, trustees []*vote.Trustee, cts []*vote.Ciphertext) []*vote.PartialDecryption {
+	partials := make([]*vote.PartialDecryption, len(trustees))
+	errs := make([]error, len(trustees))
+	var wg sync.WaitGroup
+	for i, tr := range trustees {
+		wg.Add(1)
+		go func(i int, tr *vote.Trustee) {
+			defer wg.Done()
+			partials[i], errs[i] = tr.PartialDecrypt(cts)
+		}(i, tr)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	return partials
+}
+
+func TestThresholdTally(t *testing.T) {
+	threshold, n := 3, 5
+	trustees, thresholdKey := runDKG(t, threshold, n)
+
+	e, err := newElectionWithKey("election-threshold", "question-threshold", choices, thresholdKey.PubKey())
+	require.NoError(t, err)
+
+	expected := make([]int, len(choices))
+	for i, c := range citizens[:6] {
+		r := i % len(choices)
+		voter := voterOf(c, e)
+		proof, ballot, err := voter.VoteProof(e, choices[r])
+		require.NoError(t, err)
+		require.NoError(t, e.DoVote(proof, e.CitizenMerkleRoot(), voter.VotePaperID, ballot))
+		expected[r]++
+	}
+	e.SetHeight(votingEndHeight)
+
+	cts := e.EncryptedTally()
+	partials := partialDecrypt(t, trustees, cts)
+	for _, pd := range partials {
+		require.NoError(t, pd.Verify(thresholdKey, cts))
+	}
+
+	// any `threshold` of the trustees decrypt the same totals.
+	ms0, err := (&vote.ThresholdDecryption{Key: thresholdKey, Partials: partials[:3]}).Combine(cts)
+	require.NoError(t, err)
+	ms1, err := (&vote.ThresholdDecryption{Key: thresholdKey, Partials: partials[2:]}).Combine(cts)
+	require.NoError(t, err)
+	require.Equal(t, ms0, ms1)
+
+	// the partial decryption of a wrong share
+	tampered := *partials[0]
+	tampered.D = append([]twistededwards.PointAffine(nil), partials[0].D...)
+	tampered.D[0].Add(&tampered.D[0], &tampered.D[1])
+	require.Error(t, tampered.Verify(thresholdKey, cts))
+	// the proof of another trustee
+	forged := *partials[1]
+	forged.Index = partials[0].Index
+	require.Error(t, forged.Verify(thresholdKey, cts))
+
+	// less than `threshold` of valid and distinct partial decryptions
+	for _, pds := range [][]*vote.PartialDecryption{
+		partials[:threshold-1],
+		{partials[1], partials[2], &tampered},
+		{partials[1], partials[2], partials[2]},
+		{partials[1], partials[2], &forged},
+	} {
+		require.Error(t, e.Seal(&vote.ThresholdDecryption{Key: thresholdKey, Partials: pds}))
+		require.Equal(t, vote.PhaseClosed, e.Phase())
+	}
+
+	// the trustees of another DKG
+	otherTrustees, otherKey := runDKG(t, threshold, n)
+	require.Error(t, e.Seal(&vote.ThresholdDecryption{Key: otherKey, Partials: partialDecrypt(t, otherTrustees, cts)}))
+
+	require.NoError(t, e.Seal(&vote.ThresholdDecryption{
+		Key:      thresholdKey,
+		Partials: []*vote.PartialDecryption{&tampered, partials[1], partials[3], partials[4]},
+	}))
+	tally, err := e.Tally()
+	require.NoError(t, err)
+	require.Equal(t, expected, tally)
+}
+
+// challenge is the Fiat-Shamir challenge of `vote.ChaumPedersenProof`.
+func challenge(points ...*twistededwards.PointAffine) *big.Int {
+	order := twistededwards.GetEdwardsCurve().Order
+	var ins [][]byte
+	for _, p := range points {
+		ins = append(ins, p.X.Marshal(), p.Y.Marshal())
+	}
+	c := new(big.Int).SetBytes(utils.MiMCHash(ins...))
+	return c.Mod(c, &order)
+}
+
+// smallOrderPartial returns the partial decryption of `cts` by the trustee of `index` whose key share is `x`,
+// where a point of the order 2 is added to every D and the proofs are made to pass the equations.
+func smallOrderPartial(t *testing.T, index int, x *big.Int, cts []*vote.Ciphertext) *vote.PartialDecryption {
+	curve := twistededwards.GetEdwardsCurve()
+	var torsion twistededwards.PointAffine
+	torsion.X.SetZero()
+	torsion.Y.SetOne()
+	torsion.Y.Neg(&torsion.Y)
+	require.True(t, torsion.IsOnCurve())
+
+	var pubShare twistededwards.PointAffine
+	pubShare.ScalarMultiplication(&curve.Base, x)
+
+	pd := &vote.PartialDecryption{
+		Index:  index,
+		D:      make([]twistededwards.PointAffine, len(cts)),
+		Proofs: make([]*vote.ChaumPedersenProof, len(cts)),
+	}
+	for i, ct := range cts {
+		pd.D[i].ScalarMultiplication(&ct.C1, x)
+		pd.D[i].Add(&pd.D[i], &torsion)
+
+		// c*torsion is cancelled by the torsion in B when the challenge is odd.
+		for w := int64(1); ; w++ {
+			proof := &vote.ChaumPedersenProof{}
+			proof.A.ScalarMultiplication(&curve.Base, big.NewInt(w))
+			proof.B.ScalarMultiplication(&ct.C1, big.NewInt(w))
+			proof.B.Add(&proof.B, &torsion)
+			c := challenge(&pubShare, &ct.C1, &pd.D[i], &proof.A, &proof.B)
+			if c.Bit(0) == 0 {
+				continue
+			}
+			proof.Z = c.Mul(c, x)
+			proof.Z.Add(proof.Z, big.NewInt(w))
+			proof.Z.Mod(proof.Z, &curve.Order)
+
+			// z*C == B + c*D holds.
+			var l, r twistededwards.PointAffine
+			l.ScalarMultiplication(&ct.C1, proof.Z)
+			r.ScalarMultiplication(&pd.D[i], challenge(&pubShare, &ct.C1, &pd.D[i], &proof.A, &proof.B))
+			r.Add(&r, &proof.B)
+			require.True(t, l.Equal(&r))
+
+			pd.Proofs[i] = proof
+			break
+		}
+	}
+	return pd
+}
+
+func TestThresholdTally_SmallOrderPartial(t *testing.T) {
+	threshold, n := 2, 3
+	trustees, thresholdKey := runDKG(t, threshold, n)
+
+	e, err := newElectionWithKey("election-small-order", "question-small-order", choices, thresholdKey.PubKey())
+	require.NoError(t, err)
+	voter := voterOf(citizens[0], e)
+	proof, ballot, err := voter.VoteProof(e, choices[1])
+	require.NoError(t, err)
+	require.NoError(t, e.DoVote(proof, e.CitizenMerkleRoot(), voter.VotePaperID, ballot))
+	e.SetHeight(votingEndHeight)
+
+	cts := e.EncryptedTally()
+	partials := partialDecrypt(t, trustees, cts)
+
+	// the key share of the trustee 1 is the sum of the shares dealt to it.
+	order := twistededwards.GetEdwardsCurve().Order
+	x := new(big.Int)
+	for _, tr := range trustees {
+		x.Add(x, tr.ShareFor(1))
+	}
+	x.Mod(x, &order)
+	malicious := smallOrderPartial(t, 1, x, cts)
+	require.Error(t, malicious.Verify(thresholdKey, cts))
+
+	// the small order commitment
+	var torsion twistededwards.PointAffine
+	torsion.X.SetZero()
+	torsion.Y.SetOne()
+	torsion.Y.Neg(&torsion.Y)
+	commitments := trustees[1].Commitments()
+	commitments[0].Add(&commitments[0], &torsion)
+	require.Error(t, trustees[0].ReceiveShare(2, commitments, trustees[1].ShareFor(1)))
+	forgedKey := &vote.ThresholdKey{Threshold: threshold, Commitments: append([][]twistededwards.PointAffine(nil), thresholdKey.Commitments...)}
+	forgedKey.Commitments[1] = commitments
+	_, err = (&vote.ThresholdDecryption{Key: forgedKey, Partials: partials}).Combine(cts)
+	require.Error(t, err)
+
+	// the malicious partial decryption is not counted in the threshold.
+	require.Error(t, e.Seal(&vote.ThresholdDecryption{Key: thresholdKey, Partials: []*vote.PartialDecryption{malicious, partials[1]}}))
+	require.NoError(t, e.Seal(&vote.ThresholdDecryption{Key: thresholdKey, Partials: []*vote.PartialDecryption{malicious, partials[1], partials[2]}}))
+	tally, err := e.Tally()
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 1, 0}, tally)
+
+	tr, err := e.Transcript()
+	require.NoError(t, err)
+	tally, err = vote.VerifyTranscript(tr, keys.VerifyingKey)
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 1, 0}, tally)
+}
+
+func TestDKG_WrongShare(t *testing.T) {
+	_, err := vote.NewTrustee(1, 3, 2)
+	require.Error(t, err)
+	_, err = vote.NewTrustee(3, 2, 2)
+	require.Error(t, err)
+
+	tr1, err := vote.NewTrustee(1, 2, 3)
+	require.NoError(t, err)
+	tr2, err := vote.NewTrustee(2, 2, 3)
+	require.NoError(t, err)
+	tr3, err := vote.NewTrustee(3, 2, 3)
+	require.NoError(t, err)
+
+	_, err = tr1.PartialDecrypt(nil)
+	require.Error(t, err)
+
+	// the share which does not match the commitments of the dealer
+	share := tr2.ShareFor(1)
+	require.Error(t, tr1.ReceiveShare(2, tr2.Commitments(), new(big.Int).Add(share, big.NewInt(1))))
+	require.Error(t, tr1.ReceiveShare(2, tr3.Commitments(), share))
+	require.Error(t, tr1.ReceiveShare(2, tr2.Commitments()[:1], share))
+
+	require.NoError(t, tr1.ReceiveShare(2, tr2.Commitments(), share))
+	require.Error(t, tr1.ReceiveShare(2, tr2.Commitments(), share))
+	_, err = tr1.Finish()
+	require.Error(t, err) // the share of tr3 is not received yet.
+
+	require.NoError(t, tr1.ReceiveShare(3, tr3.Commitments(), tr3.ShareFor(1)))
+	thresholdKey, err := tr1.Finish()
+	require.NoError(t, err)
+	require.False(t, thresholdKey.PubShare(1).Equal(thresholdKey.PubShare(2)))
+
+	// the key share of tr1 matches its public share.
+	cts := []*vote.Ciphertext{vote.Encrypt(thresholdKey.PubKey(), 1, big.NewInt(7))}
+	pd, err := tr1.PartialDecrypt(cts)
+	require.NoError(t, err)
+	require.NoError(t, pd.Verify(thresholdKey, cts))
+}
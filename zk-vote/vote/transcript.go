@@ -0,0 +1,130 @@
+package vote
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/kysee/zkp/zk-vote/smt"
+)
+
+// BallotRecord is an accepted ballot with its proof.
+type BallotRecord struct {
+	Proof       []byte
+	VotePaperID []byte
+	Ballot      *EncryptedBallot
+}
+
+// Transcript is the public record of a sealed election.
+// Anyone can replay it with `VerifyTranscript` to audit the tally.
+type Transcript struct {
+	ElectionID        []byte
+	Question          string
+	Choices           [][]byte
+	PubKey            twistededwards.PointAffine
+	CitizenMerkleRoot []byte
+	// Ballots is all the accepted ballots in order.
+	// The later ballot of a vote paper overrides the former one.
+	Ballots []*BallotRecord
+	// BallotBoxRoot is the root of the ballot box of the latest ballots,
+	// which the receipts of the voters can be checked against.
+	BallotBoxRoot []byte
+	Decryption    *ThresholdDecryption
+	Tally         []int
+}
+
+// Transcript returns the transcript of the sealed election.
+func (e *Election) Transcript() (*Transcript, error) {
+	e.mtx.RLock()
+	defer e.mtx.RUnlock()
+
+	if err := e.checkPhase(PhaseSealed); err != nil {
+		return nil, err
+	}
+	return &Transcript{
+		ElectionID:        e.ID,
+		Question:          e.Question,
+		Choices:           e.Choices,
+		PubKey:            e.pubKey,
+		CitizenMerkleRoot: e.citizenMerkleRoot,
+		Ballots:           append([]*BallotRecord(nil), e.ballots...),
+		BallotBoxRoot:     e.ballotBox.Root(),
+		Decryption:        e.decryption,
+		Tally:             append([]int(nil), e.tally...),
+	}, nil
+}
+
+func (t *Transcript) Marshal() ([]byte, error) {
+	return json.Marshal(t)
+}
+
+func UnmarshalTranscript(bz []byte) (*Transcript, error) {
+	t := &Transcript{}
+	if err := json.Unmarshal(bz, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// VerifyTranscript replays the transcript:
+// it verifies the proofs of all the ballots with `vk`, the verifying key of `VoteCircuit`,
+// adds up the last ballot of every vote paper and decrypts the sums with the proofs of the decryption.
+// It returns the tally if it is the one of the transcript.
+func VerifyTranscript(t *Transcript, vk groth16.VerifyingKey) ([]int, error) {
+	if err := checkChoices(t.Choices); err != nil {
+		return nil, err
+	}
+	if !inSubgroup(&t.PubKey) || t.PubKey.IsZero() {
+		return nil, errors.New("wrong public key of the election")
+	}
+	choicesHash := HashChoices(t.Choices)
+
+	votePapers := make(map[[32]byte]*EncryptedBallot)
+	ballotBox := smt.New(BallotBoxDepth)
+	for i, b := range t.Ballots {
+		if b == nil || b.Ballot == nil || checkVotePaperID(b.VotePaperID) != nil {
+			return nil, fmt.Errorf("wrong ballot %d", i)
+		}
+		proof := groth16.NewProof(ecc.BN254)
+		if _, err := proof.ReadFrom(bytes.NewReader(b.Proof)); err != nil {
+			return nil, fmt.Errorf("wrong proof of the ballot %d: %w", i, err)
+		}
+		err := verifyVoteProof(vk, proof, t.CitizenMerkleRoot, t.ElectionID, b.VotePaperID, choicesHash, &t.PubKey, b.Ballot)
+		if err != nil {
+			return nil, fmt.Errorf("wrong proof of the ballot %d: %w", i, err)
+		}
+		votePapers[toVotePaperID(b.VotePaperID)] = b.Ballot
+		if err := ballotBox.Set(new(big.Int).SetBytes(b.VotePaperID), b.Ballot.Hash()); err != nil {
+			return nil, err
+		}
+	}
+	if !bytes.Equal(ballotBox.Root(), t.BallotBoxRoot) {
+		return nil, errors.New("the ballot box is different from the transcript")
+	}
+
+	cts := make([]*Ciphertext, len(t.Choices))
+	for i := range cts {
+		cts[i] = zeroCiphertext()
+		for _, ballot := range votePapers {
+			cts[i].Add(cts[i], &ballot[i])
+		}
+	}
+	tally, err := decryptTally(&t.PubKey, cts, t.Decryption, len(votePapers))
+	if err != nil {
+		return nil, err
+	}
+	if len(tally) != len(t.Tally) {
+		return nil, errors.New("the tally is different from the transcript")
+	}
+	for i := range tally {
+		if tally[i] != t.Tally[i] {
+			return nil, errors.New("the tally is different from the transcript")
+		}
+	}
+	return tally, nil
+}
@@ -0,0 +1,103 @@
+package vote_test
+
+import (
+	"testing"
+
+	"github.com/kysee/zkp/zk-vote/gov"
+	"github.com/kysee/zkp/zk-vote/vote"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranscript_Verify(t *testing.T) {
+	e, err := newElection("election-transcript", "question-transcript", choices)
+	require.NoError(t, err)
+
+	// the voters vote for choices[i%3] and then the first two revote for choices[2].
+	expected := make([]int, len(choices))
+	voters := make([]*gov.Citizen, 6)
+	for i := range voters {
+		voters[i] = voterOf(citizens[i], e)
+		proof, ballot, err := voters[i].VoteProof(e, choices[i%len(choices)])
+		require.NoError(t, err)
+		require.NoError(t, e.DoVote(proof, e.CitizenMerkleRoot(), voters[i].VotePaperID, ballot))
+		expected[i%len(choices)]++
+	}
+	for _, voter := range voters[:2] {
+		proof, ballot, err := voter.VoteProof(e, choices[2])
+		require.NoError(t, err)
+		require.NoError(t, e.DoVote(proof, e.CitizenMerkleRoot(), voter.VotePaperID, ballot))
+	}
+	expected[0]--
+	expected[1]--
+	expected[2] += 2
+
+	_, err = e.Transcript()
+	require.ErrorIs(t, err, vote.ErrWrongPhase)
+	require.Equal(t, expected, sealTally(t, e))
+
+	tr, err := e.Transcript()
+	require.NoError(t, err)
+	require.Len(t, tr.Ballots, len(voters)+2)
+	bz, err := tr.Marshal()
+	require.NoError(t, err)
+
+	// a third party replays the exported transcript.
+	parse := func() *vote.Transcript {
+		tr, err := vote.UnmarshalTranscript(bz)
+		require.NoError(t, err)
+		return tr
+	}
+	tally, err := vote.VerifyTranscript(parse(), keys.VerifyingKey)
+	require.NoError(t, err)
+	require.Equal(t, expected, tally)
+
+	otherKey, err := vote.NewElectionKey()
+	require.NoError(t, err)
+
+	for name, tamper := range map[string]func(tr *vote.Transcript){
+		"tally": func(tr *vote.Transcript) {
+			tr.Tally[0]++
+		},
+		"dropped revote": func(tr *vote.Transcript) {
+			tr.Ballots = tr.Ballots[:len(tr.Ballots)-1]
+		},
+		"reordered revote": func(tr *vote.Transcript) {
+			tr.Ballots[0], tr.Ballots[len(tr.Ballots)-2] = tr.Ballots[len(tr.Ballots)-2], tr.Ballots[0]
+		},
+		"ballot": func(tr *vote.Transcript) {
+			tr.Ballots[0].Ballot[0], tr.Ballots[0].Ballot[1] = tr.Ballots[0].Ballot[1], tr.Ballots[0].Ballot[0]
+		},
+		"vote paper id": func(tr *vote.Transcript) {
+			tr.Ballots[0].VotePaperID = tr.Ballots[1].VotePaperID
+		},
+		"proof": func(tr *vote.Transcript) {
+			tr.Ballots[0].Proof = tr.Ballots[1].Proof
+		},
+		"citizen root": func(tr *vote.Transcript) {
+			tr.CitizenMerkleRoot = e.ID
+		},
+		"choices": func(tr *vote.Transcript) {
+			tr.Choices = tr.Choices[:2]
+			tr.Tally = tr.Tally[:2]
+		},
+		"partial decryption": func(tr *vote.Transcript) {
+			d := &tr.Decryption.Partials[0].D[0]
+			d.Add(d, &tr.Decryption.Partials[0].D[1])
+		},
+		"decryption by another key": func(tr *vote.Transcript) {
+			tr.Decryption, err = otherKey.DecryptTally(nil)
+			require.NoError(t, err)
+		},
+		"ballot box root": func(tr *vote.Transcript) {
+			tr.BallotBoxRoot = tr.CitizenMerkleRoot
+		},
+		"no decryption": func(tr *vote.Transcript) {
+			tr.Decryption = nil
+		},
+	} {
+		tr := parse()
+		tamper(tr)
+		_, err := vote.VerifyTranscript(tr, keys.VerifyingKey)
+		require.Error(t, err, name)
+	}
+}
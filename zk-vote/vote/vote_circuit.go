@@ -1,40 +1,71 @@
 package vote
 
 import (
+	"errors"
+	"fmt"
 	"math/big"
 
 	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
 	ecc_tedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
 	"github.com/consensys/gnark-crypto/signature"
 	"github.com/consensys/gnark/backend/groth16"
 	"github.com/consensys/gnark/constraint"
 	"github.com/consensys/gnark/frontend"
 	"github.com/consensys/gnark/frontend/cs/r1cs"
-	"github.com/consensys/gnark/std/accumulator/merkle"
 	std_tedwards "github.com/consensys/gnark/std/algebra/native/twistededwards"
 	"github.com/consensys/gnark/std/hash/mimc"
 	"github.com/consensys/gnark/std/signature/eddsa"
 	"github.com/kysee/zkp/utils"
+	"github.com/kysee/zkp/zk-vote/smt"
 )
 
 var (
+	E128 = new(big.Int).Lsh(big.NewInt(1), 128)
+)
+
+// MaxChoices is the maximum number of the choices of an election.
+const MaxChoices = 8
+
+// CircuitKeys is the compiled `VoteCircuit` and its keys.
+type CircuitKeys struct {
+	// Depth is the depth of the citizen roll the circuit is compiled for.
+	Depth        int
 	R1CS         constraint.ConstraintSystem
 	ProvingKey   groth16.ProvingKey
 	VerifyingKey groth16.VerifyingKey
-	E128         = new(big.Int).Lsh(big.NewInt(1), 128)
-)
+}
 
 type VoteCircuit struct {
 	curveID           ecc_tedwards.ID
 	CitizenMerkleRoot frontend.Variable `gnark:",public"`
+	// CitizenMerklePath is the membership proof, `smt.Proof`, of the citizen in the slot LeafIdx of the roll.
 	CitizenMerklePath []frontend.Variable
 	LeafIdx           frontend.Variable
 	S0                frontend.Variable
 	S1                frontend.Variable
 	DIDPubKey         eddsa.PublicKey
-	VotePaperID       frontend.Variable `gnark:",public"`
-	Choice            frontend.Variable `gnark:",public"`
-	ChoiceSig         eddsa.Signature
+	// ElectionID scopes VotePaperID to an election,
+	// so the vote papers of a citizen in different elections are not linkable.
+	ElectionID  frontend.Variable `gnark:",public"`
+	VotePaperID frontend.Variable `gnark:",public"`
+	// Choice is secret; only its encryption, Ballot, is public.
+	Choice    frontend.Variable
+	ChoiceSig eddsa.Signature
+	// ChoicesHash commits to the allowed choices of the election; see `HashChoices`.
+	// Choice should be one of the first NumChoices of Choices.
+	ChoicesHash frontend.Variable `gnark:",public"`
+	NumChoices  frontend.Variable
+	Choices     [MaxChoices]frontend.Variable
+	// Ballot is the one-hot vector of Choice encrypted under ElectionPubKey with Randoms.
+	ElectionPubKey std_tedwards.Point             `gnark:",public"`
+	Ballot         [MaxChoices]CiphertextVariable `gnark:",public"`
+	Randoms        [MaxChoices]frontend.Variable
+}
+
+// CiphertextVariable is `Ciphertext` in the circuit.
+type CiphertextVariable struct {
+	C1, C2 std_tedwards.Point
 }
 
 func (cc *VoteCircuit) Define(api frontend.API) error {
@@ -43,28 +74,13 @@ func (cc *VoteCircuit) Define(api frontend.API) error {
 		return err
 	}
 
-	merkleProof := merkle.MerkleProof{
-		RootHash: cc.CitizenMerkleRoot,
-		Path:     cc.CitizenMerklePath,
-	}
-
 	//
-	// 0. a prover should be a citizen
+	// 0. a prover should be a citizen: the hash of DIDPubKey is in the slot LeafIdx of the roll.
+	// A revoked citizen leaves its slot empty (zero), which is never the hash.
 	hFunc.Write(cc.DIDPubKey.A.X, cc.DIDPubKey.A.Y)
 	h0 := hFunc.Sum()
-
-	api.AssertIsEqual(h0, merkleProof.Path[0])
-
-	//api.Println("h0        ", h0)
-	//api.Println("M.Path[0] ", cc.M.Path[0])
-	//api.Println("M.RootHash", cc.M.RootHash)
-	//api.Println("LeafIdx", cc.LeafIdx)
-
-	hFunc.Reset()
-	merkleProof.VerifyProof(api, &hFunc, cc.LeafIdx)
-
-	//// Verify that the computed Merkle root matches the public Merkle root
-	//api.AssertIsEqual(merkleProof.RootHash, cc.CitizenMerkleRoot)
+	api.AssertIsDifferent(h0, 0)
+	smt.AssertProof(api, &hFunc, cc.CitizenMerkleRoot, cc.LeafIdx, h0, cc.CitizenMerklePath)
 
 	//
 	// 1. DIDPubKey should be driven from PrvKeyScalar : check that a prover owns a private key of a DIDPubKey
@@ -108,9 +124,9 @@ func (cc *VoteCircuit) Define(api frontend.API) error {
 	api.AssertIsEqual(cc.DIDPubKey.A.Y, computedPub.Y)
 
 	//
-	// 2. check VotePaperID == Hash(PrvKeyScalar, DIDPubKey.A.X, DIDPubKey.A.Y)
+	// 2. check VotePaperID == Hash(PrvKeyScalar, DIDPubKey.A.X, DIDPubKey.A.Y, ElectionID)
 	hFunc.Reset()
-	hFunc.Write(cc.S0, cc.S1, cc.DIDPubKey.A.X, cc.DIDPubKey.A.Y)
+	hFunc.Write(cc.S0, cc.S1, cc.DIDPubKey.A.X, cc.DIDPubKey.A.Y, cc.ElectionID)
 	h1 := hFunc.Sum()
 
 	//cs.Println("h1         ", h1)
@@ -124,6 +140,42 @@ func (cc *VoteCircuit) Define(api frontend.API) error {
 		return err
 	}
 
+	//
+	// 3. check Choice is one of the allowed choices committed by ChoicesHash
+	hFunc.Reset()
+	hFunc.Write(cc.NumChoices)
+	hFunc.Write(cc.Choices[:]...)
+	api.AssertIsEqual(cc.ChoicesHash, hFunc.Sum())
+
+	// `active` is 1 for the first NumChoices slots and 0 for the rest.
+	// votes[i] is 1 only in the slot of Choice, so `votes` is one-hot if Choice is found.
+	var votes [MaxChoices]frontend.Variable
+	active := frontend.Variable(1)
+	found := frontend.Variable(0)
+	for i := 0; i < MaxChoices; i++ {
+		active = api.Sub(active, api.IsZero(api.Sub(cc.NumChoices, i)))
+		votes[i] = api.Mul(active, api.IsZero(api.Sub(cc.Choice, cc.Choices[i])))
+		found = api.Add(found, votes[i])
+	}
+	api.AssertIsEqual(found, 1)
+
+	//
+	// 4. check Ballot[i] == (Randoms[i]*G, votes[i]*G + Randoms[i]*ElectionPubKey)
+	curve.AssertIsOnCurve(cc.ElectionPubKey)
+	for i := 0; i < MaxChoices; i++ {
+		c1 := curve.ScalarMul(base, cc.Randoms[i])
+		api.AssertIsEqual(cc.Ballot[i].C1.X, c1.X)
+		api.AssertIsEqual(cc.Ballot[i].C1.Y, c1.Y)
+
+		vG := std_tedwards.Point{
+			X: api.Select(votes[i], base.X, 0),
+			Y: api.Select(votes[i], base.Y, 1),
+		}
+		c2 := curve.Add(vG, curve.ScalarMul(cc.ElectionPubKey, cc.Randoms[i]))
+		api.AssertIsEqual(cc.Ballot[i].C2.X, c2.X)
+		api.AssertIsEqual(cc.Ballot[i].C2.Y, c2.Y)
+	}
+
 	return nil
 }
 
@@ -143,17 +195,86 @@ func (cc *VoteCircuit) AssignSig(sigBytes []byte) {
 	cc.ChoiceSig.Assign(cc.curveID, sigBytes)
 }
 
-func CompileCircuit(depth int) error {
+// AssignChoices assigns the allowed choices and their hash.
+// `choices` should be the choices of an election.
+func (cc *VoteCircuit) AssignChoices(choices [][]byte) {
+	cc.NumChoices = len(choices)
+	for i := range cc.Choices {
+		cc.Choices[i] = 0
+		if i < len(choices) {
+			cc.Choices[i] = choices[i]
+		}
+	}
+	cc.ChoicesHash = HashChoices(choices)
+}
+
+// AssignBallot assigns the ballot encrypted under `pk`.
+// The randomness of the ballot, Randoms, is assigned by the prover.
+func (cc *VoteCircuit) AssignBallot(pk *twistededwards.PointAffine, ballot *EncryptedBallot) {
+	cc.ElectionPubKey = pointVariable(pk)
+	for i := range ballot {
+		cc.Ballot[i].C1 = pointVariable(&ballot[i].C1)
+		cc.Ballot[i].C2 = pointVariable(&ballot[i].C2)
+	}
+}
+
+func pointVariable(p *twistededwards.PointAffine) std_tedwards.Point {
+	return std_tedwards.Point{
+		X: p.X.BigInt(new(big.Int)),
+		Y: p.Y.BigInt(new(big.Int)),
+	}
+}
+
+// HashChoices returns the hash of the number of `choices` and `choices` padded with zeros to `MaxChoices`.
+func HashChoices(choices [][]byte) []byte {
+	size := utils.MiMCHasher().Size()
+	elems := make([][]byte, 0, MaxChoices+1)
+	elems = append(elems, big.NewInt(int64(len(choices))).FillBytes(make([]byte, size)))
+	for i := 0; i < MaxChoices; i++ {
+		elem := make([]byte, size)
+		if i < len(choices) {
+			new(big.Int).SetBytes(choices[i]).FillBytes(elem)
+		}
+		elems = append(elems, elem)
+	}
+	return utils.MiMCHash(elems...)
+}
+
+// checkChoices checks that `choices` fit in the circuit and are different from each other as field elements.
+func checkChoices(choices [][]byte) error {
+	if len(choices) == 0 {
+		return errors.New("no choice")
+	}
+	if len(choices) > MaxChoices {
+		return fmt.Errorf("too many choices: %d > %d", len(choices), MaxChoices)
+	}
+	for i, c := range choices {
+		// shorter than a field element, so it is never reduced in the circuit.
+		if len(c) == 0 || len(c) >= utils.MiMCHasher().Size() {
+			return fmt.Errorf("wrong size of the choice: %d", len(c))
+		}
+		for _, _c := range choices[:i] {
+			if new(big.Int).SetBytes(c).Cmp(new(big.Int).SetBytes(_c)) == 0 {
+				return fmt.Errorf("duplicated choice: %x", c)
+			}
+		}
+	}
+	return nil
+}
+
+func CompileCircuit(depth int) (*CircuitKeys, error) {
 	var err error
 	var cc VoteCircuit
+	keys := &CircuitKeys{}
 
 	cc.curveID = utils.CURVEID
-	cc.CitizenMerklePath = make([]frontend.Variable, depth+1)
-	if R1CS, err = frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &cc); err != nil {
-		return err
+	cc.CitizenMerklePath = make([]frontend.Variable, depth)
+	keys.Depth = depth
+	if keys.R1CS, err = frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &cc); err != nil {
+		return nil, err
 	}
-	if ProvingKey, VerifyingKey, err = groth16.Setup(R1CS); err != nil {
-		return err
+	if keys.ProvingKey, keys.VerifyingKey, err = groth16.Setup(keys.R1CS); err != nil {
+		return nil, err
 	}
-	return nil
+	return keys, nil
 }
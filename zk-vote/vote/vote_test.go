@@ -1,18 +1,19 @@
 package vote_test
 
 import (
-	"bytes"
 	"fmt"
+	"math/big"
 	"math/rand"
+	"sync"
 	"testing"
 	"time"
 
-	"github.com/consensys/gnark-crypto/accumulator/merkletree"
 	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
 	"github.com/consensys/gnark/backend/groth16"
 	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
 	"github.com/kysee/zkp/utils"
-	"github.com/kysee/zkp/zk-vote/common"
 	"github.com/kysee/zkp/zk-vote/gov"
 	"github.com/kysee/zkp/zk-vote/vote"
 	"github.com/stretchr/testify/require"
@@ -28,99 +29,165 @@ var (
 	merkleCitizensDepth = 4
 	citizens            []*gov.Citizen
 
-	choices       = [][]byte{{0x1}, {0x2}, {0x3}}
-	choiceResults = []int{0, 0, 0}
+	choices = [][]byte{{0x1}, {0x2}, {0x3}}
+
+	keys        *vote.CircuitKeys
+	electionKey *vote.ElectionKey
+	issuer      *gov.Issuer
+	roll        *gov.Roll
+	election    *vote.Election
 )
 
+// the voting window of the elections made by `newElection` is closed at this height.
+const votingEndHeight = 100
+
 func init() {
 	cnt := 2 << (merkleCitizensDepth - 1)
 	citizens = make([]*gov.Citizen, cnt)
+	var err error
+	if issuer, err = gov.NewIssuer(); err != nil {
+		panic(err)
+	}
+	roll = gov.NewRoll(merkleCitizensDepth, issuer.PubKey())
 	for i := 0; i < cnt; i++ {
-		c := gov.NewCitizen(fmt.Sprintf("Name-%d", i), fmt.Sprintf("SN-%d", i))
-		gov.RegisterCitizen(c)
+		c := gov.NewCitizen()
+		if err := c.RequestCredential(issuer, gov.Attributes{Name: fmt.Sprintf("Name-%d", i), SN: fmt.Sprintf("SN-%d", i)}); err != nil {
+			panic(err)
+		}
+		if _, err := roll.RegisterCitizen(c.Credential); err != nil {
+			panic(err)
+		}
 		citizens[i] = c
-		//fmt.Printf("citizen[%d] Name=%s, SN=%s\n", i, c.Name, c.SN)
 	}
 
-	vote.InitializeVotePapers(len(citizens))
-	if err := vote.CompileCircuit(merkleCitizensDepth); err != nil {
+	if keys, err = vote.CompileCircuit(merkleCitizensDepth); err != nil {
+		panic(err)
+	}
+	if electionKey, err = vote.NewElectionKey(); err != nil {
+		panic(err)
+	}
+	if election, err = newElection("election-0", "question-0", choices); err != nil {
 		panic(err)
 	}
 
 	for _, c := range citizens {
-		c.MakeVotePaperID()
+		c.MakeVotePaperID(election.ID)
+	}
+}
+
+// newElection returns the election among all citizens, which is open until `votingEndHeight`.
+func newElection(name, question string, choices [][]byte) (*vote.Election, error) {
+	return newElectionWithKey(name, question, choices, &electionKey.PubKey)
+}
+
+// newElectionWithKey is `newElection` whose ballots are encrypted under `pubKey`.
+func newElectionWithKey(name, question string, choices [][]byte, pubKey *twistededwards.PointAffine) (*vote.Election, error) {
+	e, err := vote.NewElection(name, question, choices, pubKey, keys)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.Freeze(roll); err != nil {
+		return nil, err
+	}
+	if err := e.OpenVoting(vote.VotingWindow{EndHeight: votingEndHeight}); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// rollOf returns the roll of `cs`, which have the credentials of `issuer`.
+func rollOf(cs []*gov.Citizen) (*gov.Roll, error) {
+	r := gov.NewRoll(merkleCitizensDepth, issuer.PubKey())
+	for _, c := range cs {
+		if _, err := r.RegisterCitizen(c.Credential); err != nil {
+			return nil, err
+		}
 	}
+	return r, nil
+}
+
+// voterOf returns the copy of `c` whose vote paper id is made for `e`.
+func voterOf(c *gov.Citizen, e *vote.Election) *gov.Citizen {
+	voter := *c
+	voter.MakeVotePaperID(e.ID)
+	return &voter
+}
+
+// sealTally closes the election made by `newElection` and returns its tally.
+func sealTally(t *testing.T, e *vote.Election) []int {
+	e.SetHeight(votingEndHeight)
+	require.NoError(t, e.Seal(electionKey))
+	tally, err := e.Tally()
+	require.NoError(t, err)
+	return tally
 }
 
 func TestVote(t *testing.T) {
+	e, err := newElection("election-vote", "question-vote", choices)
+	require.NoError(t, err)
+
 	rand.Seed(time.Now().UnixNano())
 
+	voters := make([]*gov.Citizen, len(citizens))
+	lastChoices := make([]int, len(citizens))
+	choiceResults := make([]int, len(choices))
 	for i, c := range citizens {
+		voters[i] = voterOf(c, e)
 		r := rand.Intn(len(choices))
 		choice := choices[r]
 
-		proof, err := c.VoteProof(choice)
+		proof, ballot, err := voters[i].VoteProof(e, choice)
 		require.NoError(t, err)
 
-		err = vote.DoVote(proof, c.VotePaperID, choice)
+		err = e.DoVote(proof, e.CitizenMerkleRoot(), voters[i].VotePaperID, ballot)
 		require.NoError(t, err)
-		require.Equal(t, i+1, vote.GetVotePaperCnt())
+		require.Equal(t, i+1, e.GetVotePaperCnt())
 
+		lastChoices[i] = r
 		choiceResults[r] = choiceResults[r] + 1
 	}
-	totalChoiceCnt := 0
-	for i, cho := range choices {
-		require.Equal(t, choiceResults[i], vote.GetChoiceCnt(cho))
-		totalChoiceCnt += choiceResults[i]
-		//log.Printf("choice=%x, score=%d\n", cho, choiceResults[i])
-	}
 
-	vpcnt := vote.GetVotePaperCnt()
-	require.Equal(t, totalChoiceCnt, vpcnt)
+	vpcnt := e.GetVotePaperCnt()
+	require.Equal(t, len(citizens), vpcnt)
 
-	fchoice := []byte{0xff}
-	fcnt := 0
-	for _, c := range citizens {
+	for i, c := range voters {
 		r := rand.Intn(len(choices))
 		choice := choices[r]
 
-		proof, err := c.VoteProof(choice)
+		proof, ballot, err := c.VoteProof(e, choice)
 		require.NoError(t, err)
 
-		r = rand.Intn(3)
-		if r == 0 {
+		if rand.Intn(3) == 0 {
 			// Revoting should be allowed.
-			vpaper := vote.FindVotePaper(c.VotePaperID)
-			require.NotNil(t, vpaper)
-			oriChoice := vpaper.GetChoice()
-			choiceResults[int(oriChoice[0])-1] -= 1
+			require.NotNil(t, e.FindVotePaper(c.VotePaperID))
+			choiceResults[lastChoices[i]] -= 1
 
-			err = vote.DoVote(proof, c.VotePaperID, choice)
+			err = e.DoVote(proof, e.CitizenMerkleRoot(), c.VotePaperID, ballot)
 			require.NoError(t, err)
-			choiceResults[int(choice[0])-1] += 1
+			lastChoices[i] = r
+			choiceResults[r] += 1
 		} else {
-			// Try with an `fchoice` not used in the proof generation; expected to fail.
-			err = vote.DoVote(proof, c.VotePaperID, fchoice)
+			// Try with a ballot not used in the proof generation; expected to fail.
+			fballot, _, err := vote.EncryptBallot(e.PubKey(), vote.OneHot(choices, choice))
+			require.NoError(t, err)
+			err = e.DoVote(proof, e.CitizenMerkleRoot(), c.VotePaperID, fballot)
 			require.Error(t, err)
-			fcnt++
 		}
 
-		require.Equal(t, vpcnt, vote.GetVotePaperCnt())
+		require.Equal(t, vpcnt, e.GetVotePaperCnt())
 	}
 
-	totalChoiceCnt = 0
-	for i, cho := range choices {
-		require.Equal(t, choiceResults[i], vote.GetChoiceCnt(cho))
-		totalChoiceCnt += choiceResults[i]
-		//log.Printf("choice=%x, score=%d\n", cho, choiceResults[i])
-	}
+	// only the totals are decrypted by the key of the election.
+	e.SetHeight(votingEndHeight)
+	otherKey, err := vote.NewElectionKey()
+	require.NoError(t, err)
+	require.Error(t, e.Seal(otherKey))
 
-	vpcnt = vote.GetVotePaperCnt()
-	require.Equal(t, totalChoiceCnt, vpcnt)
+	require.Equal(t, choiceResults, sealTally(t, e))
 }
 
 func TestDupVote(t *testing.T) {
-	backupVotePaperCnt := vote.GetVotePaperCnt()
+	backupVotePaperCnt := election.GetVotePaperCnt()
 
 	rand.Seed(time.Now().UnixNano())
 	for i := 0; i < 100; i++ {
@@ -140,7 +207,7 @@ func TestDupVote(t *testing.T) {
 		// This is an attempt to cast extra ballots by fabricating them,
 		// i.e., an attempt at one person voting multiple times.
 		// This means duplicate voting, which must not be allowed.
-		proof, err := c.VoteProof(choice)
+		proof, _, err := c.VoteProof(election, choice)
 		require.Error(t, err)
 		require.Nil(t, proof)
 
@@ -152,7 +219,7 @@ func TestDupVote(t *testing.T) {
 		c := citizens[rand.Intn(len(citizens))]
 		choice := choices[rand.Intn(len(choices))]
 
-		proof, err := c.VoteProof(choice)
+		proof, ballot, err := c.VoteProof(election, choice)
 		require.NoError(t, err)
 
 		otherVotingPaperID := make([]byte, len(c.VotePaperID))
@@ -163,35 +230,30 @@ func TestDupVote(t *testing.T) {
 
 		// `otherVotingPaperID` is not used in the proof generation.
 		// This means duplicate voting, which must not be allowed.
-		err = vote.DoVote(proof, otherVotingPaperID, choice)
+		err = election.DoVote(proof, election.CitizenMerkleRoot(), otherVotingPaperID, ballot)
 		require.Error(t, err)
 	}
 
-	require.Equal(t, backupVotePaperCnt, vote.GetVotePaperCnt())
+	require.Equal(t, backupVotePaperCnt, election.GetVotePaperCnt())
 }
 
 func TestFakeVote(t *testing.T) {
-	hackerChoice := []byte{0xf}
-	backupVotePaperCnt := vote.GetVotePaperCnt()
-	backupChoiceResults := make([]int, len(choiceResults))
-	copy(backupChoiceResults, choiceResults)
+	// an allowed choice, so the proof fails only by the hacker's key.
+	hackerChoice := choices[0]
+	backupVotePaperCnt := election.GetVotePaperCnt()
 
 	rand.Seed(time.Now().UnixNano())
 	for i := 0; i < 100; i++ {
 		r := rand.Intn(len(citizens))
 		hacker := citizens[r]
 		victim := citizens[(r+1)%len(citizens)]
-		victimIdx := uint64(victim.GetIndex())
-
-		_, proofPath, _, err := merkletree.BuildReaderProof(
-			bytes.NewBuffer(gov.MerkleCitizensBytes),
-			utils.MiMCHasher(), utils.MiMCHasher().Size(), victimIdx)
+		victimIdx, proofPath, err := election.CitizenMerkleProof(victim.HashDIDPubKey())
 		require.NoError(t, err)
 
 		var assignment vote.VoteCircuit
 		assignment.SetCurveId(utils.CURVEID)
 		assignment.LeafIdx = victimIdx
-		assignment.CitizenMerkleRoot = common.MerkleCitizensRootHash
+		assignment.CitizenMerkleRoot = election.CitizenMerkleRoot()
 		assignment.CitizenMerklePath = make([]frontend.Variable, len(proofPath))
 		for i := 0; i < len(proofPath); i++ {
 			assignment.CitizenMerklePath[i] = proofPath[i]
@@ -204,9 +266,12 @@ func TestFakeVote(t *testing.T) {
 		s1, s2 := hacker.GetPrvScalar()
 		assignment.S0 = s1[:]
 		assignment.S1 = s2[:]
+		assignment.ElectionID = election.ID
 		assignment.AssignPubKey(victim.DIDPubKey)   // use the victim's DIDPubKey
 		assignment.VotePaperID = victim.VotePaperID // use the victim's VotePaperID
 		assignment.Choice = hackerChoice
+		assignment.AssignChoices(election.Choices)
+		assignBallot(t, &assignment, election, election.Choices, hackerChoice)
 
 		// Hackers have no choice but sign by using their own DIDPrvKey
 		// because hackers CAN NOT know the victim's DIDPrvKey.
@@ -217,19 +282,366 @@ func TestFakeVote(t *testing.T) {
 		wtn, err := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
 		require.NoError(t, err)
 
-		proof, err := groth16.Prove(vote.R1CS, vote.ProvingKey, wtn)
+		proof, err := groth16.Prove(keys.R1CS, keys.ProvingKey, wtn)
+		require.Error(t, err)
+		require.Nil(t, proof)
+	}
+
+	require.Equal(t, backupVotePaperCnt, election.GetVotePaperCnt())
+}
+
+func TestVotePaperID_ScopedToElection(t *testing.T) {
+	other, err := newElection("election-1", "question-1", choices)
+	require.NoError(t, err)
+	require.NotEqual(t, election.ID, other.ID)
+
+	// the vote paper ids of a citizen are different in every election.
+	ids := make(map[string]bool)
+	for _, c := range citizens {
+		id0, id1 := c.VotePaperIDOf(election.ID), c.VotePaperIDOf(other.ID)
+		require.Equal(t, c.VotePaperID, id0)
+		require.NotEqual(t, id0, id1)
+		require.False(t, ids[string(id0)])
+		require.False(t, ids[string(id1)])
+		ids[string(id0)], ids[string(id1)] = true, true
+	}
+
+	c := citizens[0]
+	defer c.MakeVotePaperID(election.ID)
+	choice := choices[0]
+	_, _, err = c.VoteProof(other, choice)
+	require.Error(t, err)
+
+	c.MakeVotePaperID(other.ID)
+	proof, ballot, err := c.VoteProof(other, choice)
+	require.NoError(t, err)
+
+	// the proof is bound to its election.
+	require.Error(t, election.DoVote(proof, election.CitizenMerkleRoot(), c.VotePaperID, ballot))
+	require.Error(t, election.DoVote(proof, election.CitizenMerkleRoot(), c.VotePaperIDOf(election.ID), ballot))
+
+	cnt := election.GetVotePaperCnt()
+	require.NoError(t, other.DoVote(proof, other.CitizenMerkleRoot(), c.VotePaperID, ballot))
+	require.Equal(t, 1, other.GetVotePaperCnt())
+	require.Equal(t, cnt, election.GetVotePaperCnt())
+	require.Equal(t, []int{1, 0, 0}, sealTally(t, other))
+}
+
+func TestRegistry_ConcurrentElections(t *testing.T) {
+	registry := vote.NewRegistry()
+	questions := []struct {
+		name    string
+		choices [][]byte
+	}{
+		{"budget", [][]byte{{0x1}, {0x2}}},
+		{"mayor", [][]byte{{0x1}, {0x2}, {0x3}, {0x4}}},
+	}
+	for _, q := range questions {
+		e, err := newElection(q.name, "question of "+q.name, q.choices)
+		require.NoError(t, err)
+		require.NoError(t, registry.Add(e))
+	}
+	dup, err := vote.NewElection("budget", "", choices, &electionKey.PubKey, keys)
+	require.NoError(t, err)
+	require.Error(t, registry.Add(dup))
+	require.Equal(t, 2, registry.Len())
+
+	// the citizens vote in both elections at once.
+	voters := citizens[:4]
+	var wg sync.WaitGroup
+	errs := make(chan error, len(voters)*len(questions))
+	for i, q := range questions {
+		e := registry.Get(vote.NewElectionID(q.name))
+		require.NotNil(t, e)
+		for _, c := range voters {
+			// every goroutine has its own citizen, since the vote paper id is kept in it.
+			voter := voterOf(c, e)
+			wg.Add(1)
+			go func(c *gov.Citizen, choice []byte) {
+				defer wg.Done()
+				proof, ballot, err := c.VoteProof(e, choice)
+				if err == nil {
+					err = e.DoVote(proof, e.CitizenMerkleRoot(), c.VotePaperID, ballot)
+				}
+				errs <- err
+			}(voter, q.choices[i])
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	for i, q := range questions {
+		e := registry.Get(vote.NewElectionID(q.name))
+		require.Equal(t, len(voters), e.GetVotePaperCnt())
+
+		expected := make([]int, len(q.choices))
+		expected[i] = len(voters)
+		require.Equal(t, expected, sealTally(t, e))
+	}
+	require.Nil(t, registry.Get(vote.NewElectionID("unknown")))
+}
+
+func TestElection_Lifecycle(t *testing.T) {
+	_, err := vote.NewElection("lifecycle", "question", choices, &twistededwards.PointAffine{}, keys)
+	require.Error(t, err) // not on the curve
+
+	e, err := vote.NewElection("lifecycle", "question", choices, &electionKey.PubKey, keys)
+	require.NoError(t, err)
+	require.Equal(t, vote.PhaseRegistration, e.Phase())
+	require.Error(t, e.Freeze(nil))
+	require.Error(t, e.Freeze(gov.NewRoll(merkleCitizensDepth, issuer.PubKey()))) // no citizen
+	deep := gov.NewRoll(merkleCitizensDepth+1, issuer.PubKey())
+	_, err = deep.RegisterCitizen(citizens[0].Credential)
+	require.NoError(t, err)
+	require.Error(t, e.Freeze(deep)) // not the depth of the circuit
+
+	c := voterOf(citizens[0], e)
+	choice := choices[0]
+
+	// registration
+	_, _, err = c.VoteProof(e, choice)
+	require.ErrorIs(t, err, vote.ErrWrongPhase)
+	require.ErrorIs(t, e.OpenVoting(vote.VotingWindow{EndHeight: 20}), vote.ErrWrongPhase)
+
+	// the roll snapshot
+	require.NoError(t, e.Freeze(roll))
+	require.Equal(t, vote.PhaseFrozen, e.Phase())
+	require.ErrorIs(t, e.Freeze(roll), vote.ErrWrongPhase)
+	require.Equal(t, election.CitizenMerkleRoot(), e.CitizenMerkleRoot())
+
+	proof, ballot, err := c.VoteProof(e, choice)
+	require.NoError(t, err)
+	require.ErrorIs(t, e.DoVote(proof, e.CitizenMerkleRoot(), c.VotePaperID, ballot), vote.ErrWrongPhase)
+
+	// the voting window by height
+	require.Error(t, e.OpenVoting(vote.VotingWindow{StartHeight: 10}))
+	require.Error(t, e.OpenVoting(vote.VotingWindow{StartHeight: 20, EndHeight: 10}))
+	require.NoError(t, e.OpenVoting(vote.VotingWindow{StartHeight: 10, EndHeight: 20}))
+	require.ErrorIs(t, e.OpenVoting(vote.VotingWindow{EndHeight: 30}), vote.ErrWrongPhase)
+
+	e.SetHeight(9)
+	require.Equal(t, vote.PhaseFrozen, e.Phase())
+	require.ErrorIs(t, e.DoVote(proof, e.CitizenMerkleRoot(), c.VotePaperID, ballot), vote.ErrWrongPhase)
+
+	e.SetHeight(10)
+	require.Equal(t, vote.PhaseVoting, e.Phase())
+
+	// the root of the roll before all citizens joined is not of the snapshot.
+	stale, err := vote.NewElection("lifecycle", "question", choices, &electionKey.PubKey, keys)
+	require.NoError(t, err)
+	halfRoll, err := rollOf(citizens[:len(citizens)/2])
+	require.NoError(t, err)
+	require.NoError(t, stale.Freeze(halfRoll))
+	require.NotEqual(t, stale.CitizenMerkleRoot(), e.CitizenMerkleRoot())
+	require.ErrorIs(t, e.DoVote(proof, stale.CitizenMerkleRoot(), c.VotePaperID, ballot), vote.ErrNotSnapshotRoot)
+
+	require.NoError(t, e.DoVote(proof, e.CitizenMerkleRoot(), c.VotePaperID, ballot))
+	require.Equal(t, 1, e.GetVotePaperCnt())
+
+	_, err = e.Tally()
+	require.ErrorIs(t, err, vote.ErrWrongPhase)
+	require.ErrorIs(t, e.Seal(electionKey), vote.ErrWrongPhase)
+
+	// the window is closed at the end height.
+	e.SetHeight(20)
+	e.SetHeight(15) // the height never goes back.
+	require.Equal(t, vote.PhaseClosed, e.Phase())
+	require.ErrorIs(t, e.DoVote(proof, e.CitizenMerkleRoot(), c.VotePaperID, ballot), vote.ErrWrongPhase)
+
+	// the sealed tally
+	require.NoError(t, e.Seal(electionKey))
+	require.Equal(t, vote.PhaseSealed, e.Phase())
+	require.ErrorIs(t, e.Seal(electionKey), vote.ErrWrongPhase)
+	require.ErrorIs(t, e.DoVote(proof, e.CitizenMerkleRoot(), c.VotePaperID, ballot), vote.ErrWrongPhase)
+	tally, err := e.Tally()
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 0, 0}, tally)
+}
+
+func TestElection_VotingWindowByTime(t *testing.T) {
+	now := time.Now()
+
+	future, err := vote.NewElection("future", "question", choices, &electionKey.PubKey, keys)
+	require.NoError(t, err)
+	require.NoError(t, future.Freeze(roll))
+	require.NoError(t, future.OpenVoting(vote.VotingWindow{StartTime: now.Add(time.Hour), EndTime: now.Add(2 * time.Hour)}))
+	require.Equal(t, vote.PhaseFrozen, future.Phase())
+
+	past, err := vote.NewElection("past", "question", choices, &electionKey.PubKey, keys)
+	require.NoError(t, err)
+	require.NoError(t, past.Freeze(roll))
+	require.NoError(t, past.OpenVoting(vote.VotingWindow{EndTime: now.Add(-time.Hour)}))
+	require.Equal(t, vote.PhaseClosed, past.Phase())
+	require.NoError(t, past.Seal(electionKey))
+	tally, err := past.Tally()
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 0, 0}, tally)
+
+	// either of the end bounds closes the window.
+	both, err := vote.NewElection("both", "question", choices, &electionKey.PubKey, keys)
+	require.NoError(t, err)
+	require.NoError(t, both.Freeze(roll))
+	require.NoError(t, both.OpenVoting(vote.VotingWindow{EndTime: now.Add(time.Hour), EndHeight: 5}))
+	require.Equal(t, vote.PhaseVoting, both.Phase())
+	both.SetHeight(5)
+	require.Equal(t, vote.PhaseClosed, both.Phase())
+}
+
+// voteAssignment returns the full assignment of the ballot of `c` in `e`.
+func voteAssignment(t *testing.T, c *gov.Citizen, e *vote.Election, choice []byte) *vote.VoteCircuit {
+	idx, proofPath, err := e.CitizenMerkleProof(c.HashDIDPubKey())
+	require.NoError(t, err)
+
+	var assignment vote.VoteCircuit
+	assignment.SetCurveId(utils.CURVEID)
+	assignment.LeafIdx = idx
+	assignment.CitizenMerkleRoot = e.CitizenMerkleRoot()
+	assignment.CitizenMerklePath = make([]frontend.Variable, len(proofPath))
+	for i := 0; i < len(proofPath); i++ {
+		assignment.CitizenMerklePath[i] = proofPath[i]
+	}
+	s0, s1 := c.GetPrvScalar()
+	assignment.S0, assignment.S1 = s0, s1
+	assignment.AssignPubKey(c.DIDPubKey)
+	assignment.ElectionID = e.ID
+	assignment.VotePaperID = c.VotePaperIDOf(e.ID)
+	assignment.Choice = choice
+	assignment.AssignChoices(e.Choices)
+	assignBallot(t, &assignment, e, e.Choices, choice)
+
+	sig, err := c.DIDPrvKey.Sign(choice, utils.MiMCHasher())
+	require.NoError(t, err)
+	assignment.AssignSig(sig)
+	return &assignment
+}
+
+// assignBallot assigns the encryption of the one-hot vector of `choice` among `choices` to `assignment`.
+func assignBallot(t *testing.T, assignment *vote.VoteCircuit, e *vote.Election, choices [][]byte, choice []byte) {
+	ballot, randoms, err := vote.EncryptBallot(e.PubKey(), vote.OneHot(choices, choice))
+	require.NoError(t, err)
+	assignment.AssignBallot(e.PubKey(), ballot)
+	for i, r := range randoms {
+		assignment.Randoms[i] = r
+	}
+}
+
+func TestVoteCircuit_ChoiceOutOfRange(t *testing.T) {
+	var circuit vote.VoteCircuit
+	circuit.SetCurveId(utils.CURVEID)
+	circuit.CitizenMerklePath = make([]frontend.Variable, merkleCitizensDepth)
+
+	c := citizens[0]
+	for _, choice := range choices {
+		require.NoError(t, test.IsSolved(&circuit, voteAssignment(t, c, election, choice), ecc.BN254.ScalarField()))
+	}
+
+	// 0x0 is the padding of the unused slots.
+	for _, choice := range [][]byte{{0x0}, {0x4}, {0xff}, {0x1, 0x1}} {
+		assignment := voteAssignment(t, c, election, choice)
+		require.Error(t, test.IsSolved(&circuit, assignment, ecc.BN254.ScalarField()), "choice=%x", choice)
+
+		// the choice hidden in the slots after NumChoices
+		assignment.Choices[len(choices)] = choice
+		require.Error(t, test.IsSolved(&circuit, assignment, ecc.BN254.ScalarField()), "choice=%x", choice)
+
+		// the choice in the forged list of the choices
+		forged := append(append([][]byte(nil), choices...), choice)
+		assignment.AssignChoices(forged)
+		assignBallot(t, assignment, election, forged, choice)
+		require.NoError(t, test.IsSolved(&circuit, assignment, ecc.BN254.ScalarField()), "choice=%x", choice)
+		assignment.ChoicesHash = election.ChoicesHash()
+		require.Error(t, test.IsSolved(&circuit, assignment, ecc.BN254.ScalarField()), "choice=%x", choice)
+
+		// the number of choices which covers the padding
+		assignment.AssignChoices(election.Choices)
+		assignment.NumChoices = vote.MaxChoices
+		require.Error(t, test.IsSolved(&circuit, assignment, ecc.BN254.ScalarField()), "choice=%x", choice)
+
+		proof, ballot, err := c.VoteProof(election, choice)
 		require.Error(t, err)
 		require.Nil(t, proof)
+		require.Nil(t, ballot)
+	}
+
+	// the election checks its choices fit in the circuit.
+	_, err := vote.NewElection("choices", "", [][]byte{{0x1}, {0x0, 0x1}}, &electionKey.PubKey, keys)
+	require.Error(t, err)
+	_, err = vote.NewElection("choices", "", make([][]byte, vote.MaxChoices+1), &electionKey.PubKey, keys)
+	require.Error(t, err)
+	_, err = vote.NewElection("choices", "", [][]byte{{}}, &electionKey.PubKey, keys)
+	require.Error(t, err)
+	_, err = vote.NewElection("choices", "", [][]byte{make([]byte, 32)}, &electionKey.PubKey, keys)
+	require.Error(t, err)
+}
+
+func TestVoteCircuit_OneHotBallot(t *testing.T) {
+	var circuit vote.VoteCircuit
+	circuit.SetCurveId(utils.CURVEID)
+	circuit.CitizenMerklePath = make([]frontend.Variable, merkleCitizensDepth)
+
+	c := citizens[0]
+	choice := choices[0]
+	assignment := voteAssignment(t, c, election, choice)
+	require.NoError(t, test.IsSolved(&circuit, assignment, ecc.BN254.ScalarField()))
+
+	// the ballots which are not the one-hot vector of `choice`
+	for _, votes := range [][vote.MaxChoices]uint64{
+		{},
+		{1, 1},
+		{2},
+		{0, 1},
+		{1, 0, 0, 1},
+		{1, 0, 0, 0, 0, 0, 0, 1},
+	} {
+		ballot, randoms, err := vote.EncryptBallot(election.PubKey(), votes)
+		require.NoError(t, err)
+		assignment.AssignBallot(election.PubKey(), ballot)
+		for i, r := range randoms {
+			assignment.Randoms[i] = r
+		}
+		require.Error(t, test.IsSolved(&circuit, assignment, ecc.BN254.ScalarField()), "votes=%v", votes)
+	}
+
+	// the ballot encrypted under another key
+	otherKey, err := vote.NewElectionKey()
+	require.NoError(t, err)
+	ballot, randoms, err := vote.EncryptBallot(&otherKey.PubKey, vote.OneHot(choices, choice))
+	require.NoError(t, err)
+	for i, r := range randoms {
+		assignment.Randoms[i] = r
 	}
+	assignment.AssignBallot(&otherKey.PubKey, ballot)
+	require.NoError(t, test.IsSolved(&circuit, assignment, ecc.BN254.ScalarField()))
+	assignment.AssignBallot(election.PubKey(), ballot)
+	require.Error(t, test.IsSolved(&circuit, assignment, ecc.BN254.ScalarField()))
+
+	// the ciphertexts moved to other slots
+	ballot[0], ballot[1] = ballot[1], ballot[0]
+	assignment.AssignBallot(&otherKey.PubKey, ballot)
+	require.Error(t, test.IsSolved(&circuit, assignment, ecc.BN254.ScalarField()))
+}
+
+func TestElGamal_Homomorphic(t *testing.T) {
+	key, err := vote.NewElectionKey()
+	require.NoError(t, err)
+
+	sum := uint64(0)
+	total := vote.Encrypt(&key.PubKey, 0, big.NewInt(0))
+	for v := uint64(0); v < 10; v++ {
+		ballot, _, err := vote.EncryptBallot(&key.PubKey, [vote.MaxChoices]uint64{v})
+		require.NoError(t, err)
+		total.Add(total, &ballot[0])
+		sum += v
 
-	totalChoiceCnt := 0
-	for _, cho := range choices {
-		require.Equal(t, backupChoiceResults[cho[0]-1], choiceResults[cho[0]-1])
-		require.Equal(t, choiceResults[cho[0]-1], vote.GetChoiceCnt(cho))
-		totalChoiceCnt += choiceResults[cho[0]-1]
-		//log.Printf("choice=%x, score=%d\n", cho, choiceResults[cho[0]-1])
+		// v*G is the encryption of v without randomness.
+		require.True(t, key.Decrypt(&ballot[0]).Equal(&vote.Encrypt(&key.PubKey, v, big.NewInt(0)).C2))
 	}
+	require.True(t, key.Decrypt(total).Equal(&vote.Encrypt(&key.PubKey, sum, big.NewInt(0)).C2))
 
-	require.Equal(t, totalChoiceCnt, backupVotePaperCnt)
-	require.Equal(t, backupVotePaperCnt, vote.GetVotePaperCnt())
+	otherKey, err := vote.NewElectionKey()
+	require.NoError(t, err)
+	require.False(t, otherKey.Decrypt(total).Equal(key.Decrypt(total)))
 }